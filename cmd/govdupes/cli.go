@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"govdupes/internal/application"
+	"govdupes/internal/config"
+	"govdupes/internal/health"
+	"govdupes/internal/models"
+	"govdupes/internal/progress"
+	"govdupes/internal/vm/viewmodel"
+)
+
+const cliUsage = `usage: govdupes [command] [flags]
+
+Without a command the GUI is started.
+
+commands:
+  scan [-resume]                   search the starting dirs like the Search
+       [-progress 2s]              button, printing each stage's progress
+                                   to stderr; -resume continues an
+                                   interrupted scan
+  select -rule <name|expression>   print which videos a rule keeps/selects
+         [-ref dir1,dir2]          never select videos in these reference dirs
+  rules list                       list saved rules
+  rules save -name <n> -rule <e>   save a rule
+  rules delete -name <n>           delete a saved rule
+  errors list [-all]               list files that failed to scan, -all
+                                   includes ignored ones
+  errors retry [-all] [path...]    scan the files again on the next search
+  errors ignore <path>...          hide the errors until the files change
+  health check [-full] [-recheck]  decode the videos in the starting dirs and
+                                   report damaged ones, -full decodes every
+                                   frame instead of the start and end
+  health list [-all]               list damaged videos, -all includes OK ones
+  health export -o <file>          write the results as .csv or .json
+`
+
+// runCLI runs one of the non-GUI subcommands.
+func runCLI(a *application.App, args []string) error {
+	switch args[0] {
+	case "scan":
+		return runScan(a, args[1:])
+	case "select":
+		return runSelect(a, args[1:])
+	case "rules":
+		return runRules(a, args[1:])
+	case "errors":
+		return runErrors(a, args[1:])
+	case "health":
+		return runHealth(a, args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Print(cliUsage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, cliUsage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func runScan(a *application.App, args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	resume := fs.Bool("resume", false, "Continue the interrupted scan instead of starting a new one.")
+	interval := fs.Duration("progress", 2*time.Second, "How often progress is printed, 0 only prints the summary.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	tracker := progress.NewTracker()
+	stop := make(chan struct{})
+	printed := make(chan struct{})
+	go func() {
+		defer close(printed)
+		printProgress(tracker, *interval, stop)
+	}()
+	// Ctrl-C stops ffmpeg and leaves the scan to -resume
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	err := a.Search(ctx, viewmodel.NewViewModel(a), *resume, tracker)
+	close(stop)
+	<-printed
+	for _, st := range tracker.Snapshot() {
+		fmt.Fprintln(os.Stderr, st)
+	}
+	if err != nil {
+		return err
+	}
+
+	groups, err := a.GetDuplicateGroups()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d duplicate groups\n", len(groups))
+	return nil
+}
+
+// printProgress prints the stage that is running every interval until stop
+// is closed.
+func printProgress(tracker *progress.Tracker, interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 {
+		<-stop
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			snap := tracker.Snapshot()
+			if len(snap) == 0 {
+				continue
+			}
+			// the first stage still running, writes trail the hashing
+			st := snap[len(snap)-1]
+			for _, s := range snap {
+				if !s.Finished {
+					st = s
+					break
+				}
+			}
+			line := st.String()
+			if len(st.Active) > 0 {
+				line += fmt.Sprintf(", on %s", st.Active[0])
+				if len(st.Active) > 1 {
+					line += fmt.Sprintf(" and %d more", len(st.Active)-1)
+				}
+			}
+			fmt.Fprintln(os.Stderr, line)
+		}
+	}
+}
+
+func runSelect(a *application.App, args []string) error {
+	fs := flag.NewFlagSet("select", flag.ContinueOnError)
+	ruleArg := fs.String("rule", "", "Saved rule name or rule expression.")
+	selectedOnly := fs.Bool("selected", false, "Only print the paths of selected videos.")
+	refDirs := fs.String("ref", "", "Comma separated reference dirs, videos in them are never selected.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *ruleArg == "" {
+		return fmt.Errorf("select: -rule is required")
+	}
+	if *refDirs != "" {
+		a.Config.ReferenceDirs = strings.Split(*refDirs, ",")
+		if err := config.ValidateStartingDirs(a.Config); err != nil {
+			return err
+		}
+	}
+
+	rule, err := a.CompileRule(*ruleArg)
+	if err != nil {
+		return err
+	}
+	groups, err := a.GetDuplicateGroups()
+	if err != nil {
+		return err
+	}
+
+	for gi, group := range groups {
+		selection := rule.Select(group)
+		for i, vd := range group {
+			if a.Config.IsReferencePath(vd.Video.Path) {
+				selection[i] = false
+			}
+		}
+		if !*selectedOnly {
+			fmt.Printf("# group %d\n", gi+1)
+		}
+		for i, vd := range group {
+			switch {
+			case selection[i]:
+				if *selectedOnly {
+					fmt.Println(vd.Video.Path)
+				} else {
+					fmt.Printf("select\t%s\n", vd.Video.Path)
+				}
+			case !*selectedOnly:
+				fmt.Printf("keep\t%s\n", vd.Video.Path)
+			}
+		}
+	}
+	if !*selectedOnly {
+		fmt.Printf("# %s\n", rule.Preview(groups))
+	}
+	return nil
+}
+
+func runRules(a *application.App, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("rules: expected list, save or delete")
+	}
+
+	fs := flag.NewFlagSet("rules "+args[0], flag.ContinueOnError)
+	name := fs.String("name", "", "Rule name.")
+	expr := fs.String("rule", "", "Rule expression.")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "list":
+		saved, err := a.RuleStore.GetRules(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, r := range saved {
+			fmt.Fprintf(w, "%s\t%s\n", r.Name, r.Expression)
+		}
+		return w.Flush()
+	case "save":
+		return a.SaveRule(*name, *expr)
+	case "delete":
+		if *name == "" {
+			return fmt.Errorf("rules delete: -name is required")
+		}
+		return a.RuleStore.DeleteRule(ctx, *name)
+	default:
+		return fmt.Errorf("rules: unknown subcommand %q", args[0])
+	}
+}
+
+func runErrors(a *application.App, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("errors: expected list, retry or ignore")
+	}
+
+	fs := flag.NewFlagSet("errors "+args[0], flag.ContinueOnError)
+	all := fs.Bool("all", false, "list: include ignored errors, retry: retry every error.")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "list":
+		scanErrors, err := a.ScanErrorStore.GetScanErrors(ctx, *all)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, e := range scanErrors {
+			ignored := ""
+			if e.Ignored {
+				ignored = "ignored"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.OccurredAt.Format("2006-01-02 15:04"), e.Stage, e.Path, e.Error, ignored)
+		}
+		return w.Flush()
+	case "retry":
+		if !*all && fs.NArg() == 0 {
+			return fmt.Errorf("errors retry: pass paths or -all")
+		}
+		if *all {
+			return a.RetryScanErrors(nil)
+		}
+		return a.RetryScanErrors(fs.Args())
+	case "ignore":
+		if fs.NArg() == 0 {
+			return fmt.Errorf("errors ignore: pass the paths to ignore")
+		}
+		for _, path := range fs.Args() {
+			if err := a.ScanErrorStore.IgnoreScanError(ctx, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("errors: unknown subcommand %q", args[0])
+	}
+}
+
+func runHealth(a *application.App, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("health: expected check, list or export")
+	}
+
+	fs := flag.NewFlagSet("health "+args[0], flag.ContinueOnError)
+	full := fs.Bool("full", false, "Decode every frame instead of the start and the end.")
+	recheck := fs.Bool("recheck", false, "Check videos again even if they haven't changed.")
+	all := fs.Bool("all", false, "Include videos that are OK.")
+	output := fs.String("o", "", "Export file, .csv or .json.")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	printChecks := func(checks []*models.HealthCheck) error {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, c := range checks {
+			if c.Status == models.HealthOK && !*all {
+				continue
+			}
+			firstLine, _, _ := strings.Cut(c.Details, "\n")
+			fmt.Fprintf(w, "%s\t%s\t%s\n", c.Status, c.Path, firstLine)
+		}
+		return w.Flush()
+	}
+
+	switch args[0] {
+	case "check":
+		ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stopSignals()
+		checks, err := a.CheckHealth(ctx, *full, *recheck, func(done, total int) {
+			fmt.Fprintf(os.Stderr, "\r%d/%d checked", done, total)
+		})
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return err
+		}
+		return printChecks(checks)
+	case "list":
+		checks, err := a.HealthStore.GetHealthChecks(context.Background())
+		if err != nil {
+			return err
+		}
+		return printChecks(checks)
+	case "export":
+		if *output == "" {
+			return fmt.Errorf("health export: -o is required")
+		}
+		checks, err := a.HealthStore.GetHealthChecks(context.Background())
+		if err != nil {
+			return err
+		}
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		if err := health.Export(f, checks, health.FormatFromPath(*output)); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	default:
+		return fmt.Errorf("health: unknown subcommand %q", args[0])
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -28,8 +29,26 @@ func main() {
 	db := sqlite.InitDB(cfg.DatabasePath)
 	vp := videoprocessor.NewFFmpegInstance(&cfg)
 	vs := dbstore.NewVideoStore(db)
+	rs := dbstore.NewRuleStore(db)
+	rvs := dbstore.NewReviewStore(db)
+	ses := dbstore.NewScanErrorStore(db)
+	hs := dbstore.NewHealthStore(db)
+	sjs := dbstore.NewScanJobStore(db)
+
+	a := application.NewApplication(&cfg, vs, rs, rvs, ses, hs, sjs, vp)
+
+	if len(os.Args) > 1 {
+		err := runCLI(a, os.Args[1:])
+		if closeErr := db.Close(); closeErr != nil {
+			slog.Error("Error closing the database", slog.Any("error", closeErr))
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	a := application.NewApplication(&cfg, vs, vp)
 	vm := viewmodel.NewViewModel(a)
 
 	signalChan := make(chan os.Signal, 1)
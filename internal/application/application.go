@@ -2,141 +2,98 @@ package application
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
-	"strconv"
+	"slices"
 	"strings"
 	"sync"
-	"time"
 
 	"govdupes/internal/config"
 	store "govdupes/internal/db"
 	"govdupes/internal/duplicate"
 	"govdupes/internal/filesystem"
-	"govdupes/internal/hash"
 	"govdupes/internal/models"
+	"govdupes/internal/progress"
+	"govdupes/internal/rules"
 	"govdupes/internal/videoprocessor"
-	"govdupes/internal/videoprocessor/ffprobe"
 	"govdupes/internal/vm"
+	"govdupes/internal/workerpool"
 )
 
 type App struct {
 	Config         *config.Config
 	VideoStore     store.VideoStore
+	RuleStore      store.RuleStore
+	ReviewStore    store.ReviewStore
+	ScanErrorStore store.ScanErrorStore
+	HealthStore    store.HealthStore
+	ScanJobStore   store.ScanJobStore
 	VideoProcessor *videoprocessor.FFmpegWrapper
 }
 
-func NewApplication(c *config.Config, vs store.VideoStore, vp *videoprocessor.FFmpegWrapper) *App {
-	return &App{Config: c, VideoStore: vs, VideoProcessor: vp}
+func NewApplication(c *config.Config, vs store.VideoStore, rs store.RuleStore, rvs store.ReviewStore, ses store.ScanErrorStore, hs store.HealthStore, sjs store.ScanJobStore, vp *videoprocessor.FFmpegWrapper) *App {
+	return &App{Config: c, VideoStore: vs, RuleStore: rs, ReviewStore: rvs, ScanErrorStore: ses, HealthStore: hs, ScanJobStore: sjs, VideoProcessor: vp}
 }
 
-func (a *App) Search(vm vm.ViewModel) error {
+// ReloadVideoProcessor rebuilds VideoProcessor from Config, so changed
+// ffmpeg settings apply to the next search or health check.
+func (a *App) ReloadVideoProcessor() {
+	a.VideoProcessor = videoprocessor.NewFFmpegInstance(a.Config)
+}
+
+// Search walks the starting dirs and hashes the videos that aren't stored
+// yet. Walking, probing, hashing and writing run as a pipeline: a video
+// moves on as soon as a stage is done with it and the bounded queues
+// between the stages hold back the faster ones. With resume set it
+// continues the interrupted search instead, see PendingScan. Progress is
+// counted in p, which may be nil. Cancelling ctx stops ffmpeg and ffprobe,
+// the videos left can be resumed.
+func (a *App) Search(ctx context.Context, vm vm.ViewModel, resume bool, p *progress.Tracker) error {
+	if p == nil {
+		p = progress.NewTracker()
+	}
 	dbVideos, err := a.VideoStore.GetAllVideos(context.Background())
 	if err != nil {
 		slog.Error("Error getting videos from DB", slog.Any("error", err))
 		os.Exit(1)
 	}
 
-	fsVideos := filesystem.SearchDirs(a.Config,
-		func(a int) {
-			vm.UpdateFileCount(fmt.Sprintf("%d files found...", a))
-		},
-		func(b int) {
-			vm.UpdateAcceptedFiles(fmt.Sprintf("%d videos accepted...", b))
-		},
-	)
-
-	if len(fsVideos) == 0 {
-		slog.Info("No files found in directory. Exiting!")
-		return nil
-	}
-
-	// Filter out any "files" that are already in DB (based on dev/inode and path)
-	videosNotInDB := reconcileVideosWithDB(fsVideos, dbVideos)
-
-	if len(videosNotInDB) != 0 {
-
-		validVideos := GetFFprobeInfo(videosNotInDB, vm)
-		// Build DB lookups for device/inode and size/xxhash
-		deviceInodeToDBVideo := make(map[[2]uint64]*models.Video, len(dbVideos))
-		sizeHashToDBVideo := make(map[[2]string]*models.Video, len(dbVideos))
-
-		for _, v := range dbVideos {
-			keyDevIno := [2]uint64{v.Device, v.Inode}
-			deviceInodeToDBVideo[keyDevIno] = v
-
-			if v.Size > 0 && v.XXHash != "" {
-				keySizeHash := [2]string{strconv.FormatInt(v.Size, 10), v.XXHash}
-				sizeHashToDBVideo[keySizeHash] = v
-			}
-		}
-
-		// Decide if a video matches an existing DB video or is truly new.
-		// If it matches (hardlink or exact duplicate), reuse that video’s existing phash info.
-		var videosReuseHash []*models.Video
-		var vNotRelatedToDB []*models.Video
-
-		for _, vid := range validVideos {
-			// Check device+inode in DB
-			devInoKey := [2]uint64{vid.Device, vid.Inode}
-			if existingDBVid, ok := deviceInodeToDBVideo[devInoKey]; ok {
-				vid.FKVideoVideohash = existingDBVid.FKVideoVideohash
-				videosReuseHash = append(videosReuseHash, vid)
-				continue
-			}
-
-			// Check size+xxhash in DB
-			sizeHashKey := [2]string{strconv.FormatInt(vid.Size, 10), vid.XXHash}
-			if existingDBVid, ok := sizeHashToDBVideo[sizeHashKey]; ok {
-				vid.FKVideoVideohash = existingDBVid.FKVideoVideohash
-				videosReuseHash = append(videosReuseHash, vid)
-				continue
-			}
-
-			vNotRelatedToDB = append(vNotRelatedToDB, vid)
-		}
-
-		// For new videos that don't match anything in DB by dev/inode
-		// or size/xxhash, if their dev & inode or size & xxhash are =
-		// then group them together so later we can generate one phash
-		// for the group then propogate it to the rest
-		// Assumption: dev & inode = exact dupe, size & xxhash = exact dupe
-		var videosToCreate [][]*models.Video
-		deviceInodeToIndex := make(map[[2]uint64]int)
-		sizeHashToIndex := make(map[[2]string]int)
-
-		for _, vid := range vNotRelatedToDB {
-			devInoKey := [2]uint64{vid.Device, vid.Inode}
-			if i, ok := deviceInodeToIndex[devInoKey]; ok {
-				videosToCreate[i] = append(videosToCreate[i], vid)
-				continue
-			}
-
-			sizeHashKey := [2]string{strconv.FormatInt(vid.Size, 10), vid.XXHash}
-			if i, ok := sizeHashToIndex[sizeHashKey]; ok {
-				videosToCreate[i] = append(videosToCreate[i], vid)
-				continue
-			}
-
-			vid.FKVideoVideohash = 0
-			index := len(videosToCreate)
-			deviceInodeToIndex[devInoKey] = index
-			sizeHashToIndex[sizeHashKey] = index
-			videosToCreate = append(videosToCreate, []*models.Video{vid})
+	var job *models.ScanJob
+	if resume {
+		if job, err = a.PendingScan(); err != nil {
+			slog.Error("Failed to find the interrupted scan", slog.Any("error", err))
 		}
+	}
 
-		// Also append those that matched an existing DB videohash
-		for _, v := range videosReuseHash {
-			videosToCreate = append(videosToCreate, []*models.Video{v})
+	var toProbe <-chan *models.Video
+	if job != nil {
+		toProbe = videoChan(a.resumeScanJob(job, dbVideos, vm))
+	} else {
+		// walk errors are found again on every scan
+		if err := a.ScanErrorStore.DeleteScanErrorsAt(context.Background(), models.StageWalk); err != nil {
+			slog.Error("Failed to clear walk errors", slog.Any("error", err))
 		}
-
-		slog.Info("Starting to generate pHashes!")
-		generatePHashesParallel(videosToCreate, a, func(progress float64) {
-			vm.UpdateGenPHashesProgress(progress)
-		})
-		slog.Info("Done generating pHashes!")
+		job = a.startScanJob()
+		toProbe = a.walk(ctx, vm, job, dbVideos, p)
+	}
+
+	// every stage starts with the first video found, the walk goes on
+	// meanwhile and the first hashes are written while ffprobe still runs
+	probed := GetFFprobeInfo(ctx, a.VideoProcessor, a.probePool(), toProbe, p, func(v *models.Video, err error) {
+		a.recordScanError(v, models.StageFFprobe, err)
+		a.setTaskStage(job, []string{v.Path}, models.TaskFailed)
+	})
+	slog.Info("Starting to generate pHashes!")
+	a.hashVideos(ctx, probed, dbVideos, job, p)
+	if err := ctx.Err(); err != nil {
+		slog.Info("Search cancelled, it can be resumed")
+		return err
 	}
+	slog.Info("Done generating pHashes!")
+	a.finishScanJob(job)
 
 	fVideos, err := a.VideoStore.GetAllVideos(context.Background())
 	if err != nil {
@@ -162,8 +119,23 @@ func (a *App) Search(vm vm.ViewModel) error {
 			slog.Int("hashesCount", len(fHashes)))
 	}
 
+	decisions, err := a.ReviewStore.GetReviewDecisions(context.Background())
+	if err != nil {
+		slog.Error("Error retrieving review decisions", slog.Any("error", err))
+		return err
+	}
+
 	slog.Info("Starting to match hashes")
-	err = duplicate.FindVideoDuplicates(fHashes)
+	p.AddTotal(progress.Match, len(fHashes), 0)
+	options := duplicate.DefaultDuplicateOptions()
+	options.Excluded = duplicate.NewExclusions(decisions)
+	options.MinAgreement = a.Config.MinHashAgreement
+	options.Mode = duplicate.MatchMode(a.Config.MatchMode)
+	options.Temporal.MinMatchSeconds = a.Config.TemporalMinMatchSeconds
+	options.Temporal.MaxFrameDistance = a.Config.TemporalMaxFrameDistance
+	options.Temporal.MinSceneMatch = a.Config.SceneMinMatch
+	options.OnCompared = func() { p.Advance(progress.Match, 1, 0) }
+	matches, err := duplicate.FindVideoDuplicates(fHashes, options)
 	for _, vhash := range fHashes {
 		slog.Info("Videohash", "vhash.ID", vhash.ID, "vhash.bucket", vhash.Bucket)
 	}
@@ -171,13 +143,19 @@ func (a *App) Search(vm vm.ViewModel) error {
 		slog.Error("Error determining duplicates", slog.Any("error", err))
 		os.Exit(1)
 	}
+	p.Finish(progress.Match)
 
 	if err := a.VideoStore.BulkUpdateVideohashes(context.Background(), fHashes); err != nil {
 		slog.Error("Error in BulkUpdateVideohashes", slog.Any("error", err))
 		return err
 	}
 
-	duplicateVideoData, err := a.VideoStore.GetDuplicateVideoData(context.Background())
+	if err := a.VideoStore.ReplaceVideohashMatches(context.Background(), matches); err != nil {
+		slog.Error("Error storing videohash matches", slog.Any("error", err))
+		return err
+	}
+
+	duplicateVideoData, err := a.GetDuplicateGroups()
 	if err != nil {
 		slog.Error("Error getting duplicate video data", slog.Any("error", err))
 		return err
@@ -199,160 +177,171 @@ func (a *App) Search(vm vm.ViewModel) error {
 	return nil
 }
 
+// walkBatch is the most videos the walk records as scan tasks at once.
+const walkBatch = 500
+
+// walk streams the videos in the starting dirs that aren't stored and
+// didn't fail before. They are recorded as tasks of job before they are
+// sent, so a search interrupted during the walk resumes what it found.
+func (a *App) walk(ctx context.Context, vm vm.ViewModel, job *models.ScanJob, dbVideos []*models.Video, p *progress.Tracker) <-chan *models.Video {
+	stored := storedInDB(dbVideos)
+	failed := a.failedBefore()
+	found := filesystem.WalkDirs(a.Config,
+		func(int) {
+			p.Advance(progress.Walk, 1, 0)
+		},
+		func(b int) {
+			vm.UpdateAcceptedFiles(fmt.Sprintf("%d videos accepted...", b))
+		},
+		func(v *models.Video, err error) {
+			a.recordScanError(v, models.StageWalk, err)
+			p.Fail(progress.Walk, v.Path, v.Size)
+		},
+	)
+
+	out := make(chan *models.Video)
+	go func() {
+		defer close(out)
+		var batch []*models.Video
+		flush := func() {
+			a.addScanTasks(job, videoPaths(batch))
+			for _, v := range batch {
+				out <- v
+			}
+			batch = batch[:0]
+		}
+		for v := range found {
+			// once cancelled the walk is only drained
+			if ctx.Err() != nil || stored(v) || failed(v) {
+				continue
+			}
+			batch = append(batch, v)
+			// don't hold videos back while the walk looks for more
+			if len(batch) == walkBatch || len(found) == 0 {
+				flush()
+			}
+		}
+		flush()
+		p.Finish(progress.Walk)
+	}()
+	return out
+}
+
+// videoChan returns a closed channel holding videos.
+func videoChan(videos []*models.Video) <-chan *models.Video {
+	ch := make(chan *models.Video, len(videos))
+	for _, v := range videos {
+		ch <- v
+	}
+	close(ch)
+	return ch
+}
+
 // reconcileVideosWithDB returns a subset of 'videosFromFS' that are not already
 // in DB (based on path + device/inode/size checks).
 func reconcileVideosWithDB(videosFromFS []*models.Video, dbVideos []*models.Video) []*models.Video {
-	dbPathToVideo := make(map[string]models.Video, len(dbVideos))
-	for _, dbv := range dbVideos {
-		dbPathToVideo[dbv.Path] = *dbv
-	}
-
+	stored := storedInDB(dbVideos)
 	var results []*models.Video
 	for _, fsVid := range videosFromFS {
-		if match, exists := dbPathToVideo[fsVid.Path]; exists {
-			sameInodeDevice := (fsVid.Inode == match.Inode) && (fsVid.Device == match.Device)
-			sameSize := (fsVid.Size == match.Size)
-			if sameInodeDevice && sameSize {
-				slog.Info("Skipping filesystem video already in DB",
-					slog.String("path", fsVid.Path))
-				continue
-			}
+		if !stored(fsVid) {
+			results = append(results, fsVid)
 		}
-		results = append(results, fsVid)
 	}
 	return results
 }
 
-func generatePHashesParallel(videosToCreate [][]*models.Video, a *App, UpdatePhashProgress func(progress float64)) {
-	detectionMethod := a.Config.DetectionMethod
-	const workerCount = 5
-	const maxBatchSize = 10
-	const maxRetries = 5
-	const retryBaseDelay = 50 * time.Millisecond
-
-	videoChan := make(chan []*models.Video, len(videosToCreate))
-	progressChan := make(chan float64, len(videosToCreate))
-	writeChan := make(chan *models.VideoData, maxBatchSize*workerCount)
-	var wg sync.WaitGroup
-	var writeWg sync.WaitGroup
-
-	// Writer goroutine
-	writeWg.Add(1)
-	go func() {
-		defer writeWg.Done()
-		var batch []*models.VideoData
-		timer := time.NewTimer(1 * time.Second)
-		defer timer.Stop()
-
-		flushBatch := func() {
-			if len(batch) == 0 {
-				return
-			}
-
-			for retries := range maxRetries {
-				if err := a.VideoStore.BatchCreateVideos(context.Background(), batch); err != nil {
-					if isSQLiteBusyError(err) {
-						time.Sleep(retryBaseDelay * time.Duration(1<<retries))
-						continue
-					}
-					slog.Error("Failed to write batch to DB", slog.Any("error", err))
-					return
-				}
-				break
-			}
+// storedInDB returns a check for videos found on disk that are already in
+// dbVideos with the same path, device/inode and size.
+func storedInDB(dbVideos []*models.Video) func(*models.Video) bool {
+	dbPathToVideo := make(map[string]models.Video, len(dbVideos))
+	for _, dbv := range dbVideos {
+		dbPathToVideo[dbv.Path] = *dbv
+	}
 
-			batch = batch[:0]
+	return func(fsVid *models.Video) bool {
+		match, exists := dbPathToVideo[fsVid.Path]
+		if !exists {
+			return false
 		}
-
-		for {
-			select {
-			case task, ok := <-writeChan:
-				if !ok {
-					flushBatch()
-					return
-				}
-
-				batch = append(batch, task)
-				if len(batch) >= maxBatchSize {
-					flushBatch()
-				}
-			case <-timer.C:
-				flushBatch()
-				timer.Reset(1 * time.Second)
-			}
+		sameInodeDevice := (fsVid.Inode == match.Inode) && (fsVid.Device == match.Device)
+		sameSize := (fsVid.Size == match.Size)
+		if sameInodeDevice && sameSize {
+			slog.Info("Skipping filesystem video already in DB",
+				slog.String("path", fsVid.Path))
+			return true
 		}
-	}()
-
-	// Worker goroutines
-	for range workerCount {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for group := range videoChan {
-				if group[0].FKVideoVideohash != 0 {
-					progressChan <- 1.0 / float64(len(videosToCreate))
-					continue
-				}
-
-				pHash, screenshots, err := hash.Create(a.VideoProcessor, group[0], detectionMethod)
-				if err != nil {
-					slog.Warn("Skipping pHash generation", slog.String("path", group[0].Path), slog.Any("error", err))
-					progressChan <- 1.0 / float64(len(videosToCreate))
-					continue
-				}
+		return false
+	}
+}
 
-				// skip video if pHashes are all solid colours
-				solidColor := true
-				for i := 16; i < len(pHash.HashValue); i += 16 {
-					if !strings.EqualFold(pHash.HashValue[i-16:i], "8000000000000000") && !strings.EqualFold(pHash.HashValue[i-16:i], "0000000000000000") {
-						solidColor = false
-						break
-					}
-				}
+// skipFailedVideos drops the videos that failed in an earlier scan and
+// haven't changed since. Retrying an error deletes it, so it's scanned again.
+func (a *App) skipFailedVideos(videos []*models.Video) []*models.Video {
+	failed := a.failedBefore()
+	results := videos[:0]
+	for _, v := range videos {
+		if !failed(v) {
+			results = append(results, v)
+		}
+	}
+	return results
+}
 
-				if solidColor {
-					slog.Warn("Skipping video with solid color pHash",
-						slog.String("path", group[0].Path),
-						slog.String("pHash", pHash.HashValue))
-					progressChan <- 1.0 / float64(len(videosToCreate))
-					continue
-				}
+// failedBefore returns the check behind skipFailedVideos, with the scan
+// errors as they are now.
+func (a *App) failedBefore() func(*models.Video) bool {
+	scanErrors, err := a.ScanErrorStore.GetScanErrors(context.Background(), true)
+	if err != nil {
+		slog.Error("Failed to load scan errors, retrying every file", slog.Any("error", err))
+	}
+	failed := make(map[string]*models.ScanError, len(scanErrors))
+	for _, e := range scanErrors {
+		failed[e.Path] = e
+	}
 
-				for _, video := range group {
-					writeChan <- &models.VideoData{
-						Video:      *video,
-						Videohash:  *pHash,
-						Screenshot: *screenshots,
-					}
-				}
-				progressChan <- 1.0 / float64(len(videosToCreate))
-			}
-		}()
+	return func(v *models.Video) bool {
+		e, ok := failed[v.Path]
+		if !ok || !e.Unchanged(v) {
+			return false
+		}
+		slog.Info("Skipping video that failed in an earlier scan",
+			slog.String("path", v.Path),
+			slog.String("stage", string(e.Stage)))
+		return true
 	}
+}
 
-	// Distribute work
-	for _, group := range videosToCreate {
-		videoChan <- group
+func (a *App) recordScanError(v *models.Video, stage models.ScanStage, err error) {
+	recordErr := a.ScanErrorStore.RecordScanError(context.Background(), &models.ScanError{
+		Path:       v.Path,
+		Stage:      stage,
+		Error:      err.Error(),
+		Size:       v.Size,
+		ModifiedAt: v.ModifiedAt,
+	})
+	if recordErr != nil {
+		slog.Error("Failed to record scan error", slog.String("path", v.Path), slog.Any("error", recordErr))
 	}
-	close(videoChan)
+}
 
-	// Progress updater goroutine
-	go func() {
-		totalProgress := 0.0
-		for progress := range progressChan {
-			totalProgress += progress
-			UpdatePhashProgress(totalProgress)
+// RetryScanErrors forgets the errors of paths, or of every file when paths
+// is empty, so the next search scans them again.
+func (a *App) RetryScanErrors(paths []string) error {
+	if len(paths) == 0 {
+		scanErrors, err := a.ScanErrorStore.GetScanErrors(context.Background(), true)
+		if err != nil {
+			return err
 		}
-	}()
-
-	wg.Wait()
-	close(writeChan)
-	writeWg.Wait()
-	close(progressChan)
-	UpdatePhashProgress(1.0)
-	slog.Info("All pHash generation workers completed.")
+		for _, e := range scanErrors {
+			paths = append(paths, e.Path)
+		}
+	}
+	return a.ScanErrorStore.DeleteScanErrors(context.Background(), paths)
 }
 
+// errBlankVideo is recorded for videos without a usable frame.
+var errBlankVideo = errors.New("every sampled frame is blank or too plain to hash")
+
 func isSQLiteBusyError(err error) bool {
 	return strings.Contains(err.Error(), "database is locked")
 }
@@ -366,95 +355,217 @@ func (a *App) DeleteVideosByID(ids []int64) error {
 	return nil
 }
 
-func GetFFprobeInfo(videosNotInDB []*models.Video, vm vm.ViewModel) []*models.Video {
-	workerCount := 10
-	validVideos := make([]*models.Video, 0, len(videosNotInDB))
-	inodeDeviceMap := make(map[string]*models.Video)
-	inodeDeviceMutex := sync.Mutex{}
-
-	l := len(videosNotInDB)
-	progressChan := make(chan float64, l)
-	resultChan := make(chan *models.Video, l)
-	taskChan := make(chan *models.Video, l)
-	var wg sync.WaitGroup
+// GetDuplicateGroups returns the duplicate groups, leaving out groups whose
+// videohashes are all covered by one review decision. A group that gains a
+// new member shows up again.
+func (a *App) GetDuplicateGroups() ([][]*models.VideoData, error) {
+	groups, err := a.VideoStore.GetDuplicateVideoData(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	decisions, err := a.ReviewStore.GetReviewDecisions(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("getting review decisions: %w", err)
+	}
+	matches, err := a.VideoStore.GetVideohashMatches(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("getting videohash matches: %w", err)
+	}
 
-	// progress updater to UI
-	go func() {
-		totalProgress := 0.0
-		for progress := range progressChan {
-			totalProgress += progress
-			vm.UpdateGetFileInfoProgress(totalProgress)
+	var visible [][]*models.VideoData
+	for _, group := range groups {
+		if ReviewedBy(group, decisions) == nil {
+			describeMatches(group, matches)
+			visible = append(visible, group)
 		}
-	}()
+	}
+	return visible, nil
+}
 
-	for range workerCount {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for vid := range taskChan {
-				// unique key for inode and device
-				inodeDeviceKey := fmt.Sprintf("%d:%d", vid.Inode, vid.Device)
+// describeMatches fills in Relations for the members of group that are part
+// of a temporal match with another member.
+func describeMatches(group []*models.VideoData, matches []*models.VideohashMatch) {
+	names := make(map[int64]string)
+	for _, vd := range group {
+		if _, ok := names[vd.Videohash.ID]; !ok {
+			names[vd.Videohash.ID] = vd.Video.FileName
+		}
+	}
+	for _, vd := range group {
+		vd.Relations = nil
+		self := vd.Videohash.ID
+		for _, m := range matches {
+			var other int64
+			switch self {
+			case m.FKMatchA:
+				other = m.FKMatchB
+			case m.FKMatchB:
+				other = m.FKMatchA
+			default:
+				continue
+			}
+			if name, ok := names[other]; ok {
+				vd.Relations = append(vd.Relations, m.Describe(self, name))
+			}
+		}
+	}
+}
 
-				// check inode/dev combination has already been processed
-				inodeDeviceMutex.Lock()
-				existingVid, exists := inodeDeviceMap[inodeDeviceKey]
-				inodeDeviceMutex.Unlock()
+// ReviewedBy returns the first decision covering every videohash of group,
+// or nil.
+func ReviewedBy(group []*models.VideoData, decisions []*models.ReviewDecision) *models.ReviewDecision {
+	for _, d := range decisions {
+		covered := make(map[int64]bool, len(d.VideohashIDs))
+		for _, id := range d.VideohashIDs {
+			covered[id] = true
+		}
+		all := true
+		for _, vd := range group {
+			if !covered[vd.Videohash.ID] {
+				all = false
+				break
+			}
+		}
+		if all {
+			return d
+		}
+	}
+	return nil
+}
 
-				if exists {
-					// reuse info for vids with matching inode/dev
-					vid.Size = existingVid.Size
-					vid.Duration = existingVid.Duration
-					vid.BitRate = existingVid.BitRate
-					vid.VideoCodec = existingVid.VideoCodec
-					vid.AudioCodec = existingVid.AudioCodec
-					vid.Width = existingVid.Width
-					vid.Height = existingVid.Height
-					vid.SampleRateAvg = existingVid.SampleRateAvg
-					vid.AvgFrameRate = existingVid.AvgFrameRate
-					slog.Info("Reused video info", slog.String("path", vid.Path))
-				} else {
-					if err := ffprobe.GetVideoInfo(vid); err != nil {
-						vid.Corrupted = true
-						slog.Warn("Skipping corrupted file",
-							slog.String("path", vid.Path),
-							slog.Any("error", err))
-						progressChan <- 1.0 / float64(l) // increment progress for skipped file
-						continue
-					}
+// MarkReviewed stores a decision covering the videohashes of videos. It needs
+// at least two distinct videohashes, identical files can't be told apart.
+func (a *App) MarkReviewed(decision models.ReviewDecisionType, videos []*models.VideoData) (*models.ReviewDecision, error) {
+	d := &models.ReviewDecision{Decision: decision}
+	for _, vd := range videos {
+		if !slices.Contains(d.VideohashIDs, vd.Videohash.ID) {
+			d.VideohashIDs = append(d.VideohashIDs, vd.Videohash.ID)
+		}
+		d.Paths = append(d.Paths, vd.Video.Path)
+	}
+	if len(d.VideohashIDs) < 2 {
+		return nil, fmt.Errorf("the videos are identical files")
+	}
+	if err := a.ReviewStore.CreateReviewDecision(context.Background(), d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
 
-					// store processed inode/device info
-					inodeDeviceMutex.Lock()
-					inodeDeviceMap[inodeDeviceKey] = vid
-					inodeDeviceMutex.Unlock()
-				}
+// CompileRule parses a rule expression, or loads the saved rule with that
+// name when nameOrExpr matches one.
+func (a *App) CompileRule(nameOrExpr string) (*rules.Rule, error) {
+	expr := nameOrExpr
+	saved, err := a.RuleStore.GetRule(context.Background(), nameOrExpr)
+	switch {
+	case err == nil:
+		expr = saved.Expression
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("looking up rule %q: %w", nameOrExpr, err)
+	}
+	return rules.Parse(expr)
+}
 
-				// send valid video
-				resultChan <- vid
-				progressChan <- 1.0 / float64(l)
-			}
-		}()
+// SaveRule validates expr and stores it under name.
+func (a *App) SaveRule(name, expr string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("rule name is empty")
 	}
+	if _, err := rules.Parse(expr); err != nil {
+		return fmt.Errorf("invalid rule: %w", err)
+	}
+	return a.RuleStore.SaveRule(context.Background(), &models.SelectionRule{Name: name, Expression: expr})
+}
 
-	// distribute tasks to workers
+// GetFFprobeInfo probes the videos in parallel as they arrive and sends the
+// readable ones on the returned channel, which is closed once videos is.
+// The others are passed to onError, which is called from several workers
+// at once and must not share state without locking. Once ctx is cancelled
+// the videos left are neither probed nor passed to onError.
+func GetFFprobeInfo(ctx context.Context, vp *videoprocessor.FFmpegWrapper, pool workerpool.Options, videos <-chan *models.Video, p *progress.Tracker, onError func(v *models.Video, err error)) <-chan *models.Video {
+	inodeDeviceMap := make(map[string]*models.Video)
+	inodeDeviceMutex := sync.Mutex{}
+
+	// the total grows as videos arrive
+	counted := make(chan *models.Video)
 	go func() {
-		for _, vid := range videosNotInDB {
-			taskChan <- vid
+		defer close(counted)
+		for vid := range videos {
+			p.AddTotal(progress.Probe, 1, vid.Size)
+			counted <- vid
 		}
-		close(taskChan)
 	}()
 
-	wg.Wait()
-	close(resultChan)
-	close(progressChan)
+	// ffprobe gets this far ahead of a slower next stage
+	validVideos := make(chan *models.Video, walkBatch)
+	go func() {
+		defer close(validVideos)
+		workerpool.Stream(counted, pool, videoDevice, func(vid *models.Video) {
+			if ctx.Err() != nil {
+				return
+			}
+			p.Begin(progress.Probe, vid.Path)
+			// unique key for inode and device
+			inodeDeviceKey := fmt.Sprintf("%d:%d", vid.Inode, vid.Device)
+
+			// check inode/dev combination has already been processed
+			inodeDeviceMutex.Lock()
+			existingVid, exists := inodeDeviceMap[inodeDeviceKey]
+			inodeDeviceMutex.Unlock()
+
+			if exists {
+				// reuse info for vids with matching inode/dev
+				vid.CopyProbeInfo(existingVid)
+				slog.Info("Reused video info", slog.String("path", vid.Path))
+			} else {
+				if err := vp.Probe(ctx, vid); err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					vid.Corrupted = true
+					slog.Warn("Skipping corrupted file",
+						slog.String("path", vid.Path),
+						slog.Any("error", err))
+					onError(vid, err)
+					p.Fail(progress.Probe, vid.Path, vid.Size)
+					return
+				}
 
-	for vid := range resultChan {
-		validVideos = append(validVideos, vid)
-	}
+				// store processed inode/device info
+				inodeDeviceMutex.Lock()
+				inodeDeviceMap[inodeDeviceKey] = vid
+				inodeDeviceMutex.Unlock()
+			}
 
-	vm.UpdateGetFileInfoProgress(1.0)
+			p.Done(progress.Probe, vid.Path, vid.Size)
+			// send valid video
+			validVideos <- vid
+		})
+		p.Finish(progress.Probe)
+	}()
 	return validVideos
 }
 
+// probePool and hashPool are the worker pools of the ffprobe and hashing
+// stages.
+func (a *App) probePool() workerpool.Options {
+	return workerpool.Options{Name: "ffprobe", Workers: a.Config.ProbeWorkers, PerDevice: a.Config.DeviceWorkers}
+}
+
+func (a *App) hashPool() workerpool.Options {
+	return workerpool.Options{Name: "hash", Workers: a.Config.HashWorkers, PerDevice: a.Config.DeviceWorkers}
+}
+
+// healthPool decodes like the hashing stage and shares its worker counts.
+func (a *App) healthPool() workerpool.Options {
+	return workerpool.Options{Name: "health", Workers: a.Config.HashWorkers, PerDevice: a.Config.DeviceWorkers}
+}
+
+func videoDevice(v *models.Video) uint64 {
+	return v.Device
+}
+
 /*
 func generatePHashes(videosToCreate [][]*models.Video, a *App, UpdatePhashProgress func(progress float64)) {
 	videosToCreateLen := len(videosToCreate)
@@ -0,0 +1,27 @@
+package application
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"govdupes/internal/config"
+	"govdupes/internal/models"
+	"govdupes/internal/videoprocessor"
+)
+
+func TestReloadVideoProcessor(t *testing.T) {
+	cfg := &config.Config{}
+	a := &App{Config: cfg, VideoProcessor: videoprocessor.NewFFmpegInstance(cfg)}
+	v := &models.Video{Path: "a.mp4"}
+	if got := a.VideoProcessor.FrameFilter(context.Background(), v); got != "" {
+		t.Fatalf("FrameFilter() = %q, want no filters", got)
+	}
+
+	// settings changed after startup apply once reloaded
+	cfg.CenterCrop = 0.5
+	a.ReloadVideoProcessor()
+	if got := a.VideoProcessor.FrameFilter(context.Background(), v); !strings.Contains(got, "crop=iw*0.5") {
+		t.Errorf("FrameFilter() after reload = %q, want the centre crop", got)
+	}
+}
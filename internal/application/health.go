@@ -0,0 +1,72 @@
+package application
+
+import (
+	"context"
+	"log/slog"
+
+	"govdupes/internal/filesystem"
+	"govdupes/internal/health"
+	"govdupes/internal/models"
+	"govdupes/internal/workerpool"
+)
+
+// CheckHealth decodes every video in the starting dirs and stores the
+// results. Videos whose last check still covers them are skipped unless
+// recheck is set. It returns the checks made by this run. Once ctx is
+// cancelled the videos left aren't checked and ctx's error is returned.
+func (a *App) CheckHealth(ctx context.Context, full, recheck bool, onProgress func(done, total int)) ([]*models.HealthCheck, error) {
+
+	previous, err := a.HealthStore.GetHealthChecks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	checked := make(map[string]*models.HealthCheck, len(previous))
+	for _, c := range previous {
+		checked[c.Path] = c
+	}
+
+	// walk errors are logged by SearchDirs and recorded by the next search
+	videos := filesystem.SearchDirs(a.Config, func(int) {}, func(int) {}, func(*models.Video, error) {})
+	var toCheck []*models.Video
+	for _, v := range videos {
+		if c, ok := checked[v.Path]; ok && !recheck && c.Covers(v, full) {
+			continue
+		}
+		toCheck = append(toCheck, v)
+	}
+	slog.Info("Checking video health",
+		slog.Int("videos", len(toCheck)),
+		slog.Int("unchanged", len(videos)-len(toCheck)),
+		slog.Bool("full", full))
+
+	resultChan := make(chan *models.HealthCheck, max(1, a.Config.HashWorkers))
+	go func() {
+		defer close(resultChan)
+		workerpool.Run(toCheck, a.healthPool(), videoDevice, func(v *models.Video) {
+			if ctx.Err() != nil {
+				return
+			}
+			c := health.Check(ctx, a.VideoProcessor, v, full)
+			// a check cut short by the cancel says nothing about v
+			if ctx.Err() != nil {
+				return
+			}
+			resultChan <- c
+		})
+	}()
+
+	// one writer, so workers never wait on a busy database. Checks that are
+	// done are saved even after a cancel.
+	results := make([]*models.HealthCheck, 0, len(toCheck))
+	for c := range resultChan {
+		if err := a.HealthStore.SaveHealthCheck(context.Background(), c); err != nil {
+			slog.Error("Failed to save health check", slog.String("path", c.Path), slog.Any("error", err))
+		}
+		if c.Status != models.HealthOK {
+			slog.Warn("Damaged video", slog.String("path", c.Path), slog.String("status", string(c.Status)))
+		}
+		results = append(results, c)
+		onProgress(len(results), len(toCheck))
+	}
+	return results, ctx.Err()
+}
@@ -0,0 +1,317 @@
+package application
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"govdupes/internal/duplicate"
+	"govdupes/internal/hash"
+	"govdupes/internal/models"
+	"govdupes/internal/progress"
+	"govdupes/internal/workerpool"
+)
+
+// hashGroup is a video being hashed and its hard links (same device and
+// inode). Only the first video is hashed, the others share its hash.
+type hashGroup struct {
+	first *models.Video
+
+	mu     sync.Mutex
+	videos []*models.Video
+	hashed bool
+	// the result of hashing first, kept for hard links found later. It is
+	// dropped when first has none, see mayHaveCopies.
+	pHash       *models.Videohash
+	screenshots *models.Screenshots
+	stage       models.ScanStage
+	err         error
+}
+
+// join adds v to g, false when g is hashed already and v has to be handled
+// with g's result.
+func (g *hashGroup) join(v *models.Video) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.hashed {
+		return false
+	}
+	g.videos = append(g.videos, v)
+	return true
+}
+
+// finish stores the result of hashing g.first and returns the videos of g.
+// err is the error of a failed stage.
+func (g *hashGroup) finish(pHash *models.Videohash, screenshots *models.Screenshots, stage models.ScanStage, err error) []*models.Video {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.hashed = true
+	g.stage, g.err = stage, err
+	if err == nil && mayHaveCopies(g.first) {
+		g.pHash, g.screenshots = pHash, screenshots
+	}
+	videos := g.videos
+	g.videos = nil
+	return videos
+}
+
+// mayHaveCopies reports whether a hard link of v can turn up later in the
+// walk. Without one its screenshots needn't stay in memory once it is
+// hashed.
+func mayHaveCopies(v *models.Video) bool {
+	return v.NumHardLinks > 1
+}
+
+// hashVideos hashes and stores the probed videos as they arrive: videos
+// that are stored already reuse their hash, copies are grouped and every
+// group is hashed once. It returns when videos is closed and all writes are
+// done. Memory stays bounded by the queues between the stages, a slow stage
+// holds up the ones before it. Videos waiting for a busy device are kept
+// aside without any frames, up to a cap, see workerpool.Stream. Once ctx is
+// cancelled the groups left aren't hashed and stay pending in job, the
+// hashes already made are still written.
+func (a *App) hashVideos(ctx context.Context, videos <-chan *models.Video, dbVideos []*models.Video, job *models.ScanJob, p *progress.Tracker) {
+	writes := make(chan *models.VideoData, max(1, a.Config.DBBatchSize)*runtime.NumCPU())
+	written := make(chan struct{})
+	go func() {
+		a.writeVideos(writes, job, p)
+		close(written)
+	}()
+
+	write := func(videos []*models.Video, pHash *models.Videohash, screenshots *models.Screenshots) {
+		for _, v := range videos {
+			p.AddTotal(progress.Write, 1, v.Size)
+			writes <- &models.VideoData{
+				Video:      *v,
+				Videohash:  *pHash,
+				Screenshot: *screenshots,
+			}
+		}
+	}
+	fail := func(videos []*models.Video, stage models.ScanStage, err error) {
+		for _, v := range videos {
+			a.recordScanError(v, stage, err)
+		}
+		a.setTaskStage(job, videoPaths(videos), models.TaskFailed)
+	}
+
+	groups := make(chan *hashGroup)
+	go func() {
+		defer close(groups)
+		a.groupVideos(videos, dbVideos, job, p, groups, func(g *hashGroup, v *models.Video) {
+			// a hard link of a video that is hashed already, its result
+			// is kept for these, see mayHaveCopies
+			g.mu.Lock()
+			pHash, screenshots, stage, err := g.pHash, g.screenshots, g.stage, g.err
+			g.mu.Unlock()
+			if err != nil {
+				fail([]*models.Video{v}, stage, err)
+				return
+			}
+			write([]*models.Video{v}, pHash, screenshots)
+		})
+	}()
+
+	detectionMethod := a.Config.DetectionMethod
+	extraHashers, err := hash.ExtraHashers(a.Config.HashAlgorithms)
+	if err != nil {
+		slog.Error("Invalid hash algorithms, only computing phash", slog.Any("error", err))
+		extraHashers = nil
+	}
+	hashOptions := hash.Options{
+		Extra:          extraHashers,
+		Transforms:     a.Config.MatchTransforms,
+		SceneThreshold: a.Config.SceneThreshold,
+	}
+	withAudio := duplicate.MatchMode(a.Config.MatchMode).UsesAudio()
+
+	// hard links share a hash, so a group runs on the device of its first video
+	firstDevice := func(g *hashGroup) uint64 { return g.first.Device }
+	workerpool.Stream(groups, a.hashPool(), firstDevice, func(g *hashGroup) {
+		if ctx.Err() != nil {
+			return
+		}
+		first := g.first
+		p.Begin(progress.Hash, first.Path)
+
+		pHash, screenshots, err := hash.Create(ctx, a.VideoProcessor, first, detectionMethod, hashOptions)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Warn("Skipping pHash generation", slog.String("path", first.Path), slog.Any("error", err))
+			fail(g.finish(nil, nil, models.StageFFmpeg, err), models.StageFFmpeg, err)
+			p.Fail(progress.Hash, first.Path, first.Size)
+			return
+		}
+
+		if withAudio {
+			pHash.AudioFingerprint, err = hash.CreateAudioFingerprint(ctx, a.VideoProcessor, first)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				slog.Warn("Skipping audio fingerprint", slog.String("path", first.Path), slog.Any("error", err))
+			}
+		}
+
+		// blank frames were already resampled, a video that is still
+		// blank everywhere can't be matched unless the audio can
+		if pHash.IsBlank() && len(pHash.AudioFingerprint) == 0 {
+			slog.Warn("Skipping video with only blank frames",
+				slog.String("path", first.Path),
+				slog.String("pHash", pHash.HashValue))
+			fail(g.finish(nil, nil, models.StageHash, errBlankVideo), models.StageHash, errBlankVideo)
+			p.Fail(progress.Hash, first.Path, first.Size)
+			return
+		}
+
+		write(g.finish(pHash, screenshots, "", nil), pHash, screenshots)
+		p.Done(progress.Hash, first.Path, first.Size)
+	})
+	p.Finish(progress.Hash)
+
+	close(writes)
+	<-written
+	p.Finish(progress.Write)
+	slog.Info("All pHash generation workers completed.")
+}
+
+// groupVideos sends a group to hash for every video that isn't a copy of
+// one stored or seen before. Copies of stored videos reuse their hash, hard
+// links of a group join it or, once it is hashed, go to onHashed. The same
+// file found twice is skipped. XXHash isn't computed while scanning, so
+// copies with their own inode are hashed on their own.
+func (a *App) groupVideos(videos <-chan *models.Video, dbVideos []*models.Video, job *models.ScanJob, p *progress.Tracker, groups chan<- *hashGroup, onHashed func(*hashGroup, *models.Video)) {
+	// Build DB lookups for device/inode and size/xxhash
+	deviceInodeToDBVideo := make(map[[2]uint64]*models.Video, len(dbVideos))
+	sizeHashToDBVideo := make(map[[2]string]*models.Video, len(dbVideos))
+	for _, v := range dbVideos {
+		keyDevIno := [2]uint64{v.Device, v.Inode}
+		deviceInodeToDBVideo[keyDevIno] = v
+
+		if v.Size > 0 && v.XXHash != "" {
+			keySizeHash := [2]string{strconv.FormatInt(v.Size, 10), v.XXHash}
+			sizeHashToDBVideo[keySizeHash] = v
+		}
+	}
+
+	// Assumption: dev & inode = the same file
+	deviceInodeToGroup := make(map[[2]uint64]*hashGroup)
+
+	var probed []string
+	for vid := range videos {
+		probed = append(probed, vid.Path)
+		// record what is probed whenever ffprobe has nothing else ready
+		if len(probed) == walkBatch || len(videos) == 0 {
+			a.setTaskStage(job, probed, models.TaskProbed)
+			probed = probed[:0]
+		}
+
+		devInoKey := [2]uint64{vid.Device, vid.Inode}
+		sizeHashKey := [2]string{strconv.FormatInt(vid.Size, 10), vid.XXHash}
+		hasXXHash := vid.Size > 0 && vid.XXHash != ""
+
+		// If it matches a stored video (hardlink or exact duplicate), reuse
+		// that video's existing phash info.
+		existingDBVid, ok := deviceInodeToDBVideo[devInoKey]
+		if !ok && hasXXHash {
+			existingDBVid, ok = sizeHashToDBVideo[sizeHashKey]
+		}
+		if ok {
+			vid.FKVideoVideohash = existingDBVid.FKVideoVideohash
+			p.AddTotal(progress.Hash, 1, vid.Size)
+			a.setTaskStage(job, []string{vid.Path}, models.TaskDone)
+			p.Advance(progress.Hash, 1, vid.Size)
+			continue
+		}
+
+		if g, ok := deviceInodeToGroup[devInoKey]; ok {
+			switch {
+			case !mayHaveCopies(g.first):
+				// not a hard link, the same file through overlapping dirs
+				slog.Info("Skipping video found twice", slog.String("path", vid.Path))
+				a.setTaskStage(job, []string{vid.Path}, models.TaskDone)
+			case !g.join(vid):
+				onHashed(g, vid)
+			}
+			continue
+		}
+
+		vid.FKVideoVideohash = 0
+		g := &hashGroup{first: vid, videos: []*models.Video{vid}}
+		deviceInodeToGroup[devInoKey] = g
+		p.AddTotal(progress.Hash, 1, vid.Size)
+		groups <- g
+	}
+	a.setTaskStage(job, probed, models.TaskProbed)
+}
+
+// writeVideos stores the hashed videos DBBatchSize at a time until videos
+// is closed, retrying while the database is busy. The videos of a batch that
+// can't be stored are recorded as scan errors.
+func (a *App) writeVideos(videos <-chan *models.VideoData, job *models.ScanJob, p *progress.Tracker) {
+	maxBatchSize := max(1, a.Config.DBBatchSize)
+	maxRetries := max(1, a.Config.DBRetries)
+	const retryBaseDelay = 50 * time.Millisecond
+
+	var batch []*models.VideoData
+	timer := time.NewTimer(1 * time.Second)
+	defer timer.Stop()
+
+	flushBatch := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		var err error
+		for retries := range maxRetries {
+			err = a.VideoStore.BatchCreateVideos(context.Background(), batch)
+			if err == nil || !isSQLiteBusyError(err) {
+				break
+			}
+			time.Sleep(retryBaseDelay * time.Duration(1<<retries))
+		}
+
+		paths := make([]string, len(batch))
+		var bytes int64
+		for i, vd := range batch {
+			paths[i] = vd.Video.Path
+			bytes += vd.Video.Size
+		}
+		if err != nil {
+			slog.Error("Failed to write batch to DB", slog.Int("videos", len(batch)), slog.Any("error", err))
+			for _, vd := range batch {
+				a.recordScanError(&vd.Video, models.StageWrite, err)
+				p.Fail(progress.Write, vd.Video.Path, vd.Video.Size)
+			}
+			a.setTaskStage(job, paths, models.TaskFailed)
+		} else {
+			a.setTaskStage(job, paths, models.TaskDone)
+			p.Advance(progress.Write, len(batch), bytes)
+		}
+
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case task, ok := <-videos:
+			if !ok {
+				flushBatch()
+				return
+			}
+
+			batch = append(batch, task)
+			if len(batch) >= maxBatchSize {
+				flushBatch()
+			}
+		case <-timer.C:
+			flushBatch()
+			timer.Reset(1 * time.Second)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package application
+
+import (
+	"slices"
+	"testing"
+
+	"govdupes/internal/models"
+	"govdupes/internal/progress"
+)
+
+func TestGroupVideos(t *testing.T) {
+	video := func(path string, inode, links uint64) *models.Video {
+		return &models.Video{Path: path, Device: 1, Inode: inode, NumHardLinks: links}
+	}
+	videos := make(chan *models.Video)
+	hashed := make(chan struct{})
+	go func() {
+		defer close(videos)
+		videos <- video("/a", 1, 1)
+		videos <- video("/overlap/a", 1, 1)
+		videos <- video("/link1", 2, 2)
+		videos <- video("/link2", 2, 2)
+		// groups are sent in order, so /link2 joined once /b is received
+		videos <- video("/b", 3, 1)
+		<-hashed
+		videos <- video("/link3", 2, 2)
+		videos <- video("/overlap/b", 3, 1)
+	}()
+
+	groups := make(chan *hashGroup)
+	var late []string
+	go func() {
+		defer close(groups)
+		a := &App{}
+		a.groupVideos(videos, nil, nil, progress.NewTracker(), groups, func(g *hashGroup, v *models.Video) {
+			late = append(late, v.Path)
+		})
+	}()
+
+	var received []*hashGroup
+	var stored [][]string
+	for g := range groups {
+		received = append(received, g)
+		if g.first.Path != "/b" {
+			continue
+		}
+		for _, g := range received {
+			stored = append(stored, videoPaths(g.finish(&models.Videohash{}, &models.Screenshots{}, "", nil)))
+		}
+		close(hashed)
+	}
+
+	want := [][]string{{"/a"}, {"/link1", "/link2"}, {"/b"}}
+	if !slices.EqualFunc(stored, want, slices.Equal) {
+		t.Errorf("groups = %v, want %v", stored, want)
+	}
+	// the same file found twice is skipped however late it turns up
+	if !slices.Equal(late, []string{"/link3"}) {
+		t.Errorf("late copies = %v, want only the hard link /link3", late)
+	}
+}
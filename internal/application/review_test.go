@@ -0,0 +1,48 @@
+package application
+
+import (
+	"path/filepath"
+	"testing"
+
+	"govdupes/internal/db/dbstore"
+	"govdupes/internal/db/sqlite"
+	"govdupes/internal/models"
+)
+
+func TestMarkReviewed(t *testing.T) {
+	db := sqlite.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if db == nil {
+		t.Fatal("InitDB failed")
+	}
+	defer db.Close()
+	a := &App{ReviewStore: dbstore.NewReviewStore(db)}
+
+	video := func(path string, hashID int64) *models.VideoData {
+		return &models.VideoData{Video: models.Video{Path: path}, Videohash: models.Videohash{ID: hashID}}
+	}
+	for _, id := range []int64{1, 2, 3} {
+		if _, err := db.Exec(`INSERT INTO videohash (id, hashValue, hashType, duration) VALUES (?, 'p:0', 'phash', 60);`, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// hard links share a videohash and can't be reviewed against each other
+	if _, err := a.MarkReviewed(models.ReviewNotDuplicate, []*models.VideoData{video("/a", 1), video("/a2", 1)}); err == nil {
+		t.Error("MarkReviewed of identical files err = nil, want error")
+	}
+
+	pair := []*models.VideoData{video("/a", 1), video("/b", 2)}
+	d, err := a.MarkReviewed(models.ReviewNotDuplicate, pair)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decisions := []*models.ReviewDecision{d}
+	if got := ReviewedBy(pair, decisions); got != d {
+		t.Errorf("ReviewedBy(pair) = %v, want the new decision", got)
+	}
+	// a group with a video the decision doesn't cover needs a new review
+	if got := ReviewedBy(append(pair, video("/c", 3)), decisions); got != nil {
+		t.Errorf("ReviewedBy(group with a new video) = %v, want nil", got)
+	}
+}
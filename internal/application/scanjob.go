@@ -0,0 +1,129 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"govdupes/internal/filesystem"
+	"govdupes/internal/models"
+	"govdupes/internal/vm"
+)
+
+// PendingScan returns the search that was interrupted before it finished,
+// nil when there is none. A search of other starting dirs can't be resumed,
+// the next search starts over.
+func (a *App) PendingScan() (*models.ScanJob, error) {
+	job, err := a.ScanJobStore.GetUnfinishedScanJob(context.Background())
+	if err != nil || job == nil || job.Remaining == 0 {
+		return nil, err
+	}
+	if dirs := strings.Join(a.Config.StartingDirs, "\n"); job.Dirs != dirs {
+		slog.Info("Interrupted scan searched other dirs, not resuming it",
+			slog.Int64("job", job.ID), slog.String("jobDirs", job.Dirs), slog.String("dirs", dirs))
+		return nil, nil
+	}
+	return job, nil
+}
+
+// startScanJob finishes interrupted jobs and starts a new one, the walk
+// adds its tasks with addScanTasks. Without a job the search still runs, it
+// just can't resume.
+func (a *App) startScanJob() *models.ScanJob {
+	for {
+		old, err := a.ScanJobStore.GetUnfinishedScanJob(context.Background())
+		if err != nil || old == nil {
+			break
+		}
+		slog.Info("Discarding interrupted scan", slog.Int64("job", old.ID), slog.Int("remaining", old.Remaining))
+		if err := a.ScanJobStore.FinishScanJob(context.Background(), old.ID); err != nil {
+			slog.Error("Failed to discard interrupted scan", slog.Int64("job", old.ID), slog.Any("error", err))
+			break
+		}
+	}
+
+	job := &models.ScanJob{Dirs: strings.Join(a.Config.StartingDirs, "\n")}
+	if err := a.ScanJobStore.CreateScanJob(context.Background(), job, nil); err != nil {
+		slog.Error("Failed to record scan job, it can't be resumed", slog.Any("error", err))
+		return nil
+	}
+	return job
+}
+
+// addScanTasks records paths as tasks of job, a nil job does nothing.
+func (a *App) addScanTasks(job *models.ScanJob, paths []string) {
+	if job == nil || len(paths) == 0 {
+		return
+	}
+	if err := a.ScanJobStore.AddScanTasks(context.Background(), job.ID, paths); err != nil {
+		slog.Error("Failed to record scan tasks, they can't be resumed", slog.Any("error", err))
+	}
+}
+
+// resumeScanJob returns the videos job still has to scan. Files that are
+// gone are recorded as walk errors, files stored or failed since are done.
+func (a *App) resumeScanJob(job *models.ScanJob, dbVideos []*models.Video, vm vm.ViewModel) []*models.Video {
+	tasks, err := a.ScanJobStore.GetPendingScanTasks(context.Background(), job.ID)
+	if err != nil {
+		slog.Error("Failed to load the interrupted scan", slog.Any("error", err))
+		return nil
+	}
+	paths := make([]string, len(tasks))
+	for i, t := range tasks {
+		paths[i] = t.Path
+	}
+	slog.Info("Resuming scan", slog.Int64("job", job.ID), slog.Int("remaining", len(paths)))
+	vm.UpdateFileCount(fmt.Sprintf("%d files left from the previous scan...", len(paths)))
+
+	var gone []string
+	videos := filesystem.LoadVideos(a.Config, paths, func(v *models.Video, err error) {
+		a.recordScanError(v, models.StageWalk, err)
+		gone = append(gone, v.Path)
+	})
+	a.setTaskStage(job, gone, models.TaskFailed)
+
+	pending := reconcileVideosWithDB(videos, dbVideos)
+	pending = a.skipFailedVideos(pending)
+	vm.UpdateAcceptedFiles(fmt.Sprintf("%d videos accepted...", len(pending)))
+
+	left := make(map[string]bool, len(pending))
+	for _, v := range pending {
+		left[v.Path] = true
+	}
+	var done []string
+	for _, v := range videos {
+		if !left[v.Path] {
+			done = append(done, v.Path)
+		}
+	}
+	a.setTaskStage(job, done, models.TaskDone)
+	return pending
+}
+
+// setTaskStage moves the tasks of paths forward, a nil job does nothing.
+func (a *App) setTaskStage(job *models.ScanJob, paths []string, stage models.ScanTaskStage) {
+	if job == nil || len(paths) == 0 {
+		return
+	}
+	if err := a.ScanJobStore.SetScanTaskStage(context.Background(), job.ID, paths, stage); err != nil {
+		slog.Error("Failed to update scan tasks", slog.String("stage", string(stage)), slog.Any("error", err))
+	}
+}
+
+func (a *App) finishScanJob(job *models.ScanJob) {
+	if job == nil {
+		return
+	}
+	if err := a.ScanJobStore.FinishScanJob(context.Background(), job.ID); err != nil {
+		slog.Error("Failed to finish scan job", slog.Int64("job", job.ID), slog.Any("error", err))
+	}
+}
+
+func videoPaths(videos []*models.Video) []string {
+	paths := make([]string, len(videos))
+	for i, v := range videos {
+		paths[i] = v.Path
+	}
+	return paths
+}
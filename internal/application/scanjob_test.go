@@ -0,0 +1,34 @@
+package application
+
+import (
+	"path/filepath"
+	"testing"
+
+	"govdupes/internal/config"
+	"govdupes/internal/db/dbstore"
+	"govdupes/internal/db/sqlite"
+)
+
+func TestPendingScanDirs(t *testing.T) {
+	db := sqlite.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if db == nil {
+		t.Fatal("InitDB failed")
+	}
+	defer db.Close()
+	a := &App{Config: &config.Config{StartingDirs: []string{"/videos", "/more"}}, ScanJobStore: dbstore.NewScanJobStore(db)}
+
+	job := a.startScanJob()
+	if job == nil {
+		t.Fatal("startScanJob() = nil")
+	}
+	a.addScanTasks(job, []string{"/videos/a.mp4", "/more/b.mp4"})
+
+	if got, err := a.PendingScan(); err != nil || got == nil || got.ID != job.ID {
+		t.Fatalf("PendingScan() = %v, %v, want job %d", got, err, job.ID)
+	}
+	// a search of other dirs must not pick up the old tasks
+	a.Config.StartingDirs = []string{"/videos"}
+	if got, err := a.PendingScan(); err != nil || got != nil {
+		t.Errorf("PendingScan() with other dirs = %v, %v, want nil", got, err)
+	}
+}
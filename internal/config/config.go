@@ -7,6 +7,8 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 )
 
 // changes here also have to be done to ConvertConfigToFormStruct / config UI
@@ -26,6 +28,75 @@ type Config struct {
 	SkipSymbolicLinks   bool
 	SilentFFmpeg        bool
 	DetectionMethod     string
+	// ReferenceDirs are starting dirs whose videos are never auto-selected;
+	// their duplicates elsewhere are selected instead.
+	ReferenceDirs           []string
+	HideReferenceOnlyGroups bool
+	// weights of the quality score used to pick the best copy in a group
+	QualityCodecWeight      float64
+	QualityResolutionWeight float64
+	QualityBitrateWeight    float64
+	QualityFrameRateWeight  float64
+	QualityDurationWeight   float64
+	QualityAudioWeight      float64
+	// TemporalPhash alignment: the shortest shared section in seconds and
+	// the most differing bits for two frames to match
+	TemporalMinMatchSeconds  int
+	TemporalMaxFrameDistance int
+	// HashAlgorithms are the image hashes stored per video, phash is always
+	// computed. MinHashAgreement is how many of them must match for two
+	// FastPhash/SlowPhash videos to be duplicates, phash always being one:
+	// 2 needs phash and another hash when there is one.
+	HashAlgorithms   []string
+	MinHashAgreement int
+	// MatchMode is "video", "audio", "both" or "either": which of the video
+	// hashes and audio fingerprints must match. Anything but "video" also
+	// fingerprints the audio while hashing.
+	MatchMode string
+	// MatchTransforms also hashes flipped and rotated frames so mirrored and
+	// rotated re-uploads match
+	MatchTransforms bool
+	// SceneThreshold is the ffmpeg scene score (0-1) that starts a new scene
+	// for ScenePhash, lower finds more cuts. SceneMinMatch is the fraction of
+	// scenes (above 0, up to 1) two ScenePhash videos must share.
+	SceneThreshold float64
+	SceneMinMatch  float64
+	// frame preprocessing before hashing: AutoCrop removes black bars,
+	// CenterCrop keeps that fraction of the frame around the centre (1 = all)
+	// and MaskCorners ("tl", "tr", "bl", "br") are blacked out over MaskSize
+	// of the width and height, e.g. for channel logos
+	AutoCrop    bool
+	CenterCrop  float64
+	MaskCorners []string
+	MaskSize    float64
+	// FFmpegPath and FFprobePath are the binaries to run, names are looked
+	// up in PATH
+	FFmpegPath  string
+	FFprobePath string
+	// ProcessTimeout is how many seconds one ffmpeg or ffprobe call may take,
+	// 0 disables it. MaxProcesses is how many run at once across the probe
+	// and hashing stages.
+	ProcessTimeout int
+	MaxProcesses   int
+	// ProcessNice is added to the niceness of ffmpeg and ffprobe (0-19),
+	// ProcessIONiceClass is their ionice class (2 best-effort, 3 idle), 0
+	// leaves the priority alone
+	ProcessNice        int
+	ProcessIONiceClass int
+	// WalkWorkers is how many directories are read at once while searching,
+	// more helps on network shares with high latency
+	WalkWorkers int
+	// ProbeWorkers and HashWorkers are how many videos are probed and hashed
+	// at once, 0 tunes the count to the observed throughput. DeviceWorkers
+	// limits both to that many per disk (1 suits spinning disks), 0 is no
+	// limit.
+	ProbeWorkers  int
+	HashWorkers   int
+	DeviceWorkers int
+	// DBBatchSize is how many hashed videos are written per transaction,
+	// DBRetries how often a write is retried while the database is busy
+	DBBatchSize int
+	DBRetries   int
 }
 
 // "3gp", "3g2", "mpeg", "mpg", "ts", "m2ts", "mts", "vob", "rm", "rmvb", "asf", "ogv", "ogm", "mxf", "divx", "dv", "xvid", "f4v"
@@ -48,9 +119,55 @@ func (c *Config) SetDefaults() {
 	c.SilentFFmpeg = true
 	c.FilesizeCutoff = 0
 	c.DetectionMethod = "FastPhash"
+	c.ReferenceDirs = []string{}
+	c.HideReferenceOnlyGroups = false
+	c.QualityCodecWeight = 1
+	c.QualityResolutionWeight = 3
+	c.QualityBitrateWeight = 2
+	c.QualityFrameRateWeight = 1
+	c.QualityDurationWeight = 2
+	c.QualityAudioWeight = 0.5
+	c.TemporalMinMatchSeconds = 10
+	c.TemporalMaxFrameDistance = 10
+	c.HashAlgorithms = []string{"phash"}
+	c.MinHashAgreement = 2
+	c.MatchMode = "video"
+	c.MatchTransforms = false
+	c.SceneThreshold = 0.3
+	c.SceneMinMatch = 0.6
+	c.AutoCrop = false
+	c.CenterCrop = 1
+	c.MaskCorners = []string{}
+	c.MaskSize = 0.15
+	c.FFmpegPath = "ffmpeg"
+	c.FFprobePath = "ffprobe"
+	c.ProcessTimeout = 600
+	c.MaxProcesses = runtime.NumCPU()
+	c.ProcessNice = 0
+	c.ProcessIONiceClass = 0
+	c.WalkWorkers = 8
+	c.ProbeWorkers = 10
+	c.HashWorkers = 5
+	c.DeviceWorkers = 0
+	c.DBBatchSize = 10
+	c.DBRetries = 5
 	ValidateStartingDirs(c)
 }
 
+// IsReferencePath reports whether path is inside one of the reference dirs.
+func (c *Config) IsReferencePath(path string) bool {
+	for _, dir := range c.ReferenceDirs {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			continue
+		}
+		if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+			return true
+		}
+	}
+	return false
+}
+
 // validateStartingDirs ensures starting directories exist and are actually dirs
 func ValidateStartingDirs(c *Config) error {
 	for i, dir := range c.StartingDirs {
@@ -89,6 +206,14 @@ func ValidateStartingDirs(c *Config) error {
 			return fmt.Errorf("path is not a valid directory: %s", dir)
 		}
 	}
+
+	for i, dir := range c.ReferenceDirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path for %s: %w", dir, err)
+		}
+		c.ReferenceDirs[i] = abs
+	}
 	return nil
 }
 
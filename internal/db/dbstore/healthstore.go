@@ -0,0 +1,47 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"govdupes/internal/models"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+
+	store "govdupes/internal/db"
+)
+
+type healthRepo struct {
+	db *sql.DB
+}
+
+func NewHealthStore(DB *sql.DB) store.HealthStore {
+	return &healthRepo{
+		db: DB,
+	}
+}
+
+// SaveHealthCheck stores the check, replacing the last one of the path.
+func (r *healthRepo) SaveHealthCheck(ctx context.Context, check *models.HealthCheck) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO health_check (path, status, details, full, size, modifiedAt, checkedAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?);
+	`, check.Path, check.Status, check.Details, check.Full, check.Size, check.ModifiedAt, check.CheckedAt)
+	if err != nil {
+		return fmt.Errorf("save health check %s: %w", check.Path, err)
+	}
+	return nil
+}
+
+func (r *healthRepo) GetHealthChecks(ctx context.Context) ([]*models.HealthCheck, error) {
+	var checks []*models.HealthCheck
+	if err := sqlscan.Select(ctx, r.db, &checks, `
+		SELECT *
+		FROM health_check
+		ORDER BY path;
+	`); err != nil {
+		return nil, fmt.Errorf("querying health checks: %w", err)
+	}
+	return checks, nil
+}
@@ -0,0 +1,121 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"govdupes/internal/models"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+
+	store "govdupes/internal/db"
+)
+
+type reviewRepo struct {
+	db *sql.DB
+}
+
+func NewReviewStore(DB *sql.DB) store.ReviewStore {
+	return &reviewRepo{
+		db: DB,
+	}
+}
+
+// CreateReviewDecision inserts the decision and the videohashes it covers.
+func (r *reviewRepo) CreateReviewDecision(ctx context.Context, decision *models.ReviewDecision) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if decision.CreatedAt.IsZero() {
+		decision.CreatedAt = time.Now()
+	}
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO review_decision (decision, createdAt) VALUES (?, ?);`,
+		decision.Decision, decision.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert review decision: %w", err)
+	}
+	decision.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("retrieve review decision ID: %w", err)
+	}
+
+	for _, hashID := range decision.VideohashIDs {
+		_, err = tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO review_decision_videohash (FK_decision, FK_videohash) VALUES (?, ?);`,
+			decision.ID, hashID,
+		)
+		if err != nil {
+			return fmt.Errorf("insert review decision videohash %d: %w", hashID, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetReviewDecisions returns every decision with its videohash IDs and the
+// paths of the videos currently referencing them.
+func (r *reviewRepo) GetReviewDecisions(ctx context.Context) ([]*models.ReviewDecision, error) {
+	var decisions []*models.ReviewDecision
+	if err := sqlscan.Select(ctx, r.db, &decisions, `
+		SELECT id, decision, createdAt
+		FROM review_decision
+		ORDER BY id;
+	`); err != nil {
+		return nil, fmt.Errorf("querying review decisions: %w", err)
+	}
+
+	var rows []struct {
+		DecisionID  int64          `db:"FK_decision"`
+		VideohashID int64          `db:"FK_videohash"`
+		Path        sql.NullString `db:"path"`
+	}
+	if err := sqlscan.Select(ctx, r.db, &rows, `
+		SELECT m.FK_decision, m.FK_videohash, v.path
+		FROM review_decision_videohash m
+		LEFT JOIN video v ON v.FK_video_videohash = m.FK_videohash
+		ORDER BY m.FK_decision, m.FK_videohash;
+	`); err != nil {
+		return nil, fmt.Errorf("querying review decision videohashes: %w", err)
+	}
+
+	byID := make(map[int64]*models.ReviewDecision, len(decisions))
+	for _, d := range decisions {
+		byID[d.ID] = d
+	}
+	for _, row := range rows {
+		d, ok := byID[row.DecisionID]
+		if !ok {
+			continue
+		}
+		if !slices.Contains(d.VideohashIDs, row.VideohashID) {
+			d.VideohashIDs = append(d.VideohashIDs, row.VideohashID)
+		}
+		if row.Path.Valid {
+			d.Paths = append(d.Paths, row.Path.String)
+		}
+	}
+	return decisions, nil
+}
+
+func (r *reviewRepo) DeleteReviewDecision(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM review_decision WHERE id = ?", id)
+	return err
+}
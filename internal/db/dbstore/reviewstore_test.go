@@ -0,0 +1,94 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"govdupes/internal/db/sqlite"
+	"govdupes/internal/models"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := sqlite.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if db == nil {
+		t.Fatal("InitDB failed")
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// insertVideohash stores a videohash and a video at path using it.
+func insertVideohash(t *testing.T, db *sql.DB, path string) int64 {
+	t.Helper()
+	result, err := db.Exec(`INSERT INTO videohash (hashValue, hashType, duration) VALUES ('p:0', 'phash', 60);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO video (xxhash, path, fileName, duration, size, FK_video_videohash)
+		VALUES ('', ?, ?, 60, 1, ?);
+	`, path, filepath.Base(path), id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestReviewDecisions(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	store := NewReviewStore(db)
+	a, b, c := insertVideohash(t, db, "/a.mp4"), insertVideohash(t, db, "/b.mp4"), insertVideohash(t, db, "/c.mp4")
+
+	notDup := &models.ReviewDecision{Decision: models.ReviewNotDuplicate, VideohashIDs: []int64{a, b}}
+	keepAll := &models.ReviewDecision{Decision: models.ReviewKeepAll, VideohashIDs: []int64{b, c, c}}
+	for _, d := range []*models.ReviewDecision{notDup, keepAll} {
+		if err := store.CreateReviewDecision(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	decisions, err := store.GetReviewDecisions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decisions) != 2 {
+		t.Fatalf("got %d decisions, want 2", len(decisions))
+	}
+	if got := decisions[0]; got.Decision != models.ReviewNotDuplicate || !slices.Equal(got.VideohashIDs, []int64{a, b}) || !slices.Equal(got.Paths, []string{"/a.mp4", "/b.mp4"}) {
+		t.Errorf("first decision = %+v, want not_duplicate of %d and %d", got, a, b)
+	}
+	if got := decisions[1]; !slices.Equal(got.VideohashIDs, []int64{b, c}) {
+		t.Errorf("keep_all covers %v, want %v", got.VideohashIDs, []int64{b, c})
+	}
+
+	// deleting a videohash drops it from the decisions covering it
+	if _, err := db.ExecContext(ctx, `DELETE FROM videohash WHERE id = ?;`, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteReviewDecision(ctx, keepAll.ID); err != nil {
+		t.Fatal(err)
+	}
+	decisions, err = store.GetReviewDecisions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decisions) != 1 || !slices.Equal(decisions[0].VideohashIDs, []int64{a}) {
+		t.Errorf("after deleting videohash %d and the keep_all decision got %+v", b, decisions)
+	}
+
+	var rows int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM review_decision_videohash;`).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Errorf("%d review_decision_videohash rows left, want 1", rows)
+	}
+}
@@ -0,0 +1,75 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"govdupes/internal/models"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+
+	store "govdupes/internal/db"
+)
+
+type ruleRepo struct {
+	db *sql.DB
+}
+
+func NewRuleStore(DB *sql.DB) store.RuleStore {
+	return &ruleRepo{
+		db: DB,
+	}
+}
+
+// SaveRule inserts a rule, replacing the expression of an existing rule
+// with the same name.
+func (r *ruleRepo) SaveRule(ctx context.Context, rule *models.SelectionRule) error {
+	if rule.CreatedAt.IsZero() {
+		rule.CreatedAt = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO selection_rule (name, expression, createdAt)
+		VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET expression = excluded.expression;
+	`, rule.Name, rule.Expression, rule.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("save rule %q: %w", rule.Name, err)
+	}
+	return nil
+}
+
+func (r *ruleRepo) GetRules(ctx context.Context) ([]*models.SelectionRule, error) {
+	var rules []*models.SelectionRule
+	err := sqlscan.Select(ctx, r.db, &rules, `
+		SELECT *
+		FROM selection_rule
+		ORDER BY name;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (r *ruleRepo) GetRule(ctx context.Context, name string) (*models.SelectionRule, error) {
+	var rule models.SelectionRule
+	err := sqlscan.Get(ctx, r.db, &rule, `
+		SELECT *
+		FROM selection_rule
+		WHERE name = ?;
+	`, name)
+	if err != nil {
+		if sqlscan.NotFound(err) {
+			return nil, fmt.Errorf("rule not found: %s: %w", name, sql.ErrNoRows)
+		}
+		return nil, fmt.Errorf("error retrieving rule: %w", err)
+	}
+	return &rule, nil
+}
+
+func (r *ruleRepo) DeleteRule(ctx context.Context, name string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM selection_rule WHERE name = ?", name)
+	return err
+}
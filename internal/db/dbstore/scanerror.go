@@ -0,0 +1,100 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"govdupes/internal/models"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+
+	store "govdupes/internal/db"
+)
+
+type scanErrorRepo struct {
+	db *sql.DB
+}
+
+func NewScanErrorStore(DB *sql.DB) store.ScanErrorStore {
+	return &scanErrorRepo{
+		db: DB,
+	}
+}
+
+// RecordScanError stores the failure, replacing an earlier one for the same
+// path. An ignored error stays ignored while the file hasn't changed.
+func (r *scanErrorRepo) RecordScanError(ctx context.Context, e *models.ScanError) error {
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO scan_error (path, stage, error, size, modifiedAt, occurredAt, ignored)
+		VALUES (?, ?, ?, ?, ?, ?, 0)
+		ON CONFLICT(path) DO UPDATE SET
+			stage = excluded.stage,
+			error = excluded.error,
+			occurredAt = excluded.occurredAt,
+			ignored = scan_error.ignored AND scan_error.size = excluded.size AND scan_error.modifiedAt = excluded.modifiedAt,
+			size = excluded.size,
+			modifiedAt = excluded.modifiedAt;
+	`, e.Path, e.Stage, e.Error, e.Size, e.ModifiedAt, e.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("record scan error %s: %w", e.Path, err)
+	}
+	return nil
+}
+
+// GetScanErrors lists the errors of files that haven't been hashed since,
+// the ignored ones only when includeIgnored is set.
+func (r *scanErrorRepo) GetScanErrors(ctx context.Context, includeIgnored bool) ([]*models.ScanError, error) {
+	var errs []*models.ScanError
+	if err := sqlscan.Select(ctx, r.db, &errs, `
+		SELECT *
+		FROM scan_error
+		WHERE path NOT IN (SELECT path FROM video)
+		AND (? OR ignored = 0)
+		ORDER BY path;
+	`, includeIgnored); err != nil {
+		return nil, fmt.Errorf("querying scan errors: %w", err)
+	}
+	return errs, nil
+}
+
+// DeleteScanErrors forgets the errors of paths, so the next scan retries them.
+func (r *scanErrorRepo) DeleteScanErrors(ctx context.Context, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	args := make([]any, len(paths))
+	for i, p := range paths {
+		args[i] = p
+	}
+	query := fmt.Sprintf("DELETE FROM scan_error WHERE path IN (%s);", strings.TrimSuffix(strings.Repeat("?,", len(paths)), ","))
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("delete scan errors: %w", err)
+	}
+	return nil
+}
+
+// DeleteScanErrorsAt forgets the errors recorded in stage that aren't ignored.
+func (r *scanErrorRepo) DeleteScanErrorsAt(ctx context.Context, stage models.ScanStage) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM scan_error WHERE stage = ? AND ignored = 0;", stage); err != nil {
+		return fmt.Errorf("delete %s scan errors: %w", stage, err)
+	}
+	return nil
+}
+
+// IgnoreScanError hides the error of path until the file changes.
+func (r *scanErrorRepo) IgnoreScanError(ctx context.Context, path string) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE scan_error SET ignored = 1 WHERE path = ?;", path)
+	if err != nil {
+		return fmt.Errorf("ignore scan error %s: %w", path, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("no scan error for %s", path)
+	}
+	return nil
+}
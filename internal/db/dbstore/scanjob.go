@@ -0,0 +1,193 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"govdupes/internal/models"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+
+	store "govdupes/internal/db"
+)
+
+type scanJobRepo struct {
+	db *sql.DB
+}
+
+func NewScanJobStore(DB *sql.DB) store.ScanJobStore {
+	return &scanJobRepo{
+		db: DB,
+	}
+}
+
+// CreateScanJob stores job with a pending task for every path and sets its ID.
+func (r *scanJobRepo) CreateScanJob(ctx context.Context, job *models.ScanJob, paths []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+	job.Total, job.Remaining = len(paths), len(paths)
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO scan_job (dirs, total, finished, createdAt)
+		VALUES (?, ?, 0, ?);
+	`, job.Dirs, job.Total, job.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert scan job: %w", err)
+	}
+	if job.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("retrieve scan job ID: %w", err)
+	}
+
+	if err = insertScanTasks(ctx, tx, job.ID, paths); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// AddScanTasks adds a pending task for every path to the job, for paths
+// found after it was created.
+func (r *scanJobRepo) AddScanTasks(ctx context.Context, jobID int64, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = insertScanTasks(ctx, tx, jobID, paths); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE scan_job SET total = total + ? WHERE id = ?;`, len(paths), jobID); err != nil {
+		return fmt.Errorf("update total of scan job %d: %w", jobID, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+func insertScanTasks(ctx context.Context, tx *sql.Tx, jobID int64, paths []string) error {
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT OR IGNORE INTO scan_task (FK_task_job, path, stage)
+		VALUES (?, ?, ?);
+	`)
+	if err != nil {
+		return fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+	for _, path := range paths {
+		if _, err := stmt.ExecContext(ctx, jobID, path, models.TaskPending); err != nil {
+			return fmt.Errorf("insert scan task %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// GetUnfinishedScanJob returns the latest job that was never finished, nil
+// when there is none.
+func (r *scanJobRepo) GetUnfinishedScanJob(ctx context.Context) (*models.ScanJob, error) {
+	var job models.ScanJob
+	err := sqlscan.Get(ctx, r.db, &job, `
+		SELECT j.*, (
+			SELECT COUNT(*) FROM scan_task t
+			WHERE t.FK_task_job = j.id AND t.stage NOT IN (?, ?)
+		) AS remaining
+		FROM scan_job j
+		WHERE j.finished = 0
+		ORDER BY j.id DESC
+		LIMIT 1;
+	`, models.TaskDone, models.TaskFailed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying unfinished scan job: %w", err)
+	}
+	return &job, nil
+}
+
+// GetPendingScanTasks returns the tasks of the job that are neither done
+// nor failed.
+func (r *scanJobRepo) GetPendingScanTasks(ctx context.Context, jobID int64) ([]*models.ScanTask, error) {
+	var tasks []*models.ScanTask
+	if err := sqlscan.Select(ctx, r.db, &tasks, `
+		SELECT *
+		FROM scan_task
+		WHERE FK_task_job = ? AND stage NOT IN (?, ?)
+		ORDER BY path;
+	`, jobID, models.TaskDone, models.TaskFailed); err != nil {
+		return nil, fmt.Errorf("querying scan tasks of job %d: %w", jobID, err)
+	}
+	return tasks, nil
+}
+
+// SetScanTaskStage moves the tasks of paths in the job to stage.
+func (r *scanJobRepo) SetScanTaskStage(ctx context.Context, jobID int64, paths []string, stage models.ScanTaskStage) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		UPDATE scan_task SET stage = ?
+		WHERE FK_task_job = ? AND path = ?;
+	`)
+	if err != nil {
+		return fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+	for _, path := range paths {
+		if _, err = stmt.ExecContext(ctx, stage, jobID, path); err != nil {
+			return fmt.Errorf("update scan task %s: %w", path, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// FinishScanJob marks the job finished and drops its tasks, they are only
+// needed to resume it.
+func (r *scanJobRepo) FinishScanJob(ctx context.Context, jobID int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM scan_task WHERE FK_task_job = ?;`, jobID); err != nil {
+		return fmt.Errorf("delete tasks of scan job %d: %w", jobID, err)
+	}
+	if _, err := r.db.ExecContext(ctx, `UPDATE scan_job SET finished = 1 WHERE id = ?;`, jobID); err != nil {
+		return fmt.Errorf("finish scan job %d: %w", jobID, err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"govdupes/internal/models"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+)
+
+// insertSignatures stores the extra hashes of a videohash.
+func insertSignatures(ctx context.Context, tx *sql.Tx, videohashID int64, signatures []models.Signature) error {
+	for _, sig := range signatures {
+		_, err := tx.ExecContext(ctx, `
+			INSERT OR REPLACE INTO videohash_signature (FK_signature_videohash, algorithm, words, hashes)
+			VALUES (?, ?, ?, ?);
+		`, videohashID, sig.Algorithm, sig.Words, sig.Hashes)
+		if err != nil {
+			return fmt.Errorf("insert %s signature: %w", sig.Algorithm, err)
+		}
+	}
+	return nil
+}
+
+// attachSignatures loads the extra hashes of every videohash in hashes.
+func (r *videoRepo) attachSignatures(ctx context.Context, hashes []*models.Videohash) error {
+	var signatures []*models.Signature
+	if err := sqlscan.Select(ctx, r.db, &signatures, `
+		SELECT FK_signature_videohash, algorithm, words, hashes
+		FROM videohash_signature
+		ORDER BY FK_signature_videohash, algorithm;
+	`); err != nil {
+		return fmt.Errorf("querying signatures: %w", err)
+	}
+
+	byID := make(map[int64]*models.Videohash, len(hashes))
+	for _, vh := range hashes {
+		vh.Signatures = nil
+		byID[vh.ID] = vh
+	}
+	for _, sig := range signatures {
+		if vh, ok := byID[sig.FKSignatureVideohash]; ok {
+			vh.Signatures = append(vh.Signatures, *sig)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package dbstore
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"govdupes/internal/models"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+)
+
+// ReplaceVideohashMatches swaps the stored temporal and transform matches for
+// matches, they are recomputed on every search.
+func (r *videoRepo) ReplaceVideohashMatches(ctx context.Context, matches []*models.VideohashMatch) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM videohash_match;`); err != nil {
+		return fmt.Errorf("clear videohash matches: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO videohash_match (FK_match_a, FK_match_b, matchType, startA, endA, startB, endB, speed, similarity, transform)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+	`)
+	if err != nil {
+		return fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, m := range matches {
+		result, execErr := stmt.ExecContext(ctx,
+			m.FKMatchA, m.FKMatchB, m.MatchType,
+			m.StartA, m.EndA, m.StartB, m.EndB,
+			m.Speed, m.Similarity, m.Transform,
+		)
+		if execErr != nil {
+			err = execErr
+			return fmt.Errorf("insert videohash match %d-%d: %w", m.FKMatchA, m.FKMatchB, err)
+		}
+		if m.ID, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("retrieve videohash match ID: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	slog.Info("Stored videohash matches", slog.Int("count", len(matches)))
+	return nil
+}
+
+func (r *videoRepo) GetVideohashMatches(ctx context.Context) ([]*models.VideohashMatch, error) {
+	var matches []*models.VideohashMatch
+	if err := sqlscan.Select(ctx, r.db, &matches, `SELECT * FROM videohash_match ORDER BY id;`); err != nil {
+		return nil, fmt.Errorf("querying videohash matches: %w", err)
+	}
+	return matches, nil
+}
@@ -125,6 +125,9 @@ func (r *videoRepo) GetVideosByVideohashIDs(ctx context.Context, hashIDs []int64
 	if err := sqlscan.Select(ctx, r.db, &videos, query, args...); err != nil {
 		return nil, fmt.Errorf("querying videos by videohash IDs: %w", err)
 	}
+	if err := r.attachStreams(ctx, videos); err != nil {
+		return nil, err
+	}
 
 	// Group videos by their FK_video_videohash
 	videosByHashID := make(map[int64][]*models.Video)
@@ -241,8 +244,8 @@ func (r *videoRepo) CreateVideo(ctx context.Context, video *models.Video, hash *
 	err = tx.QueryRowContext(ctx, `
 		SELECT id
 		FROM videohash
-		WHERE hashValue = ? AND hashType = ? AND duration = ?;
-	`, hash.HashValue, hash.HashType, hash.Duration).Scan(&existingHashID)
+		WHERE hashValue = ? AND hashType = ? AND duration = ? AND frameHashes IS ? AND audioFingerprint IS ?;
+	`, hash.HashValue, hash.HashType, hash.Duration, hash.FrameHashes, hash.AudioFingerprint).Scan(&existingHashID)
 
 	if err != nil && err != sql.ErrNoRows {
 		return fmt.Errorf("error checking for existing hash: %w", err)
@@ -256,8 +259,8 @@ func (r *videoRepo) CreateVideo(ctx context.Context, video *models.Video, hash *
 		}
 
 		hashInsert := `
-			INSERT INTO videohash (hashValue, hashType, duration, neighbours, bucket)
-			VALUES (?, ?, ?, ?, ?);
+			INSERT INTO videohash (hashValue, hashType, duration, neighbours, bucket, frameHashes, audioFingerprint)
+			VALUES (?, ?, ?, ?, ?, ?, ?);
 		`
 		hashResult, err := tx.ExecContext(ctx, hashInsert,
 			hash.HashValue,
@@ -265,6 +268,8 @@ func (r *videoRepo) CreateVideo(ctx context.Context, video *models.Video, hash *
 			hash.Duration,
 			string(neighboursJSON),
 			hash.Bucket,
+			hash.FrameHashes,
+			hash.AudioFingerprint,
 		)
 		if err != nil {
 			return fmt.Errorf("insert hash: %w", err)
@@ -273,6 +278,9 @@ func (r *videoRepo) CreateVideo(ctx context.Context, video *models.Video, hash *
 		if err != nil {
 			return fmt.Errorf("retrieve hash ID: %w", err)
 		}
+		if err := insertSignatures(ctx, tx, existingHashID, hash.Signatures); err != nil {
+			return err
+		}
 	}
 
 	// Now attach the existing/new videohash to the video
@@ -297,6 +305,9 @@ func (r *videoRepo) CreateVideo(ctx context.Context, video *models.Video, hash *
 		return fmt.Errorf("retrieve video ID: %w", err)
 	}
 	video.ID = videoID
+	if err := insertStreams(ctx, tx, videoID, video.Streams); err != nil {
+		return err
+	}
 
 	// Insert screenshot referencing the same videohash
 	base64Images, err := sc.EncodeImages()
@@ -348,8 +359,8 @@ func (r *videoRepo) BatchCreateVideos(ctx context.Context, videos []*models.Vide
 	*/
 
 	videohashInsertQuery := `
-		INSERT INTO videohash (hashValue, hashType, duration, neighbours, bucket)
-		VALUES (?, ?, ?, ?, ?);
+		INSERT INTO videohash (hashValue, hashType, duration, neighbours, bucket, frameHashes, audioFingerprint)
+		VALUES (?, ?, ?, ?, ?, ?, ?);
 	`
 
 	screenshotInsertQuery := `
@@ -370,7 +381,8 @@ func (r *videoRepo) BatchCreateVideos(ctx context.Context, videos []*models.Vide
 
 		hashResult, err := tx.ExecContext(ctx, videohashInsertQuery,
 			videohash.HashValue, videohash.HashType, videohash.Duration,
-			string(neighboursJSON), videohash.Bucket,
+			string(neighboursJSON), videohash.Bucket, videohash.FrameHashes,
+			videohash.AudioFingerprint,
 		)
 		if err != nil {
 			return fmt.Errorf("insert videohash: %w", err)
@@ -379,6 +391,9 @@ func (r *videoRepo) BatchCreateVideos(ctx context.Context, videos []*models.Vide
 		if err != nil {
 			return fmt.Errorf("retrieve hash ID: %w", err)
 		}
+		if err := insertSignatures(ctx, tx, existingHashID, videohash.Signatures); err != nil {
+			return err
+		}
 
 		// Attach hash ID to video
 		video.FKVideoVideohash = existingHashID
@@ -402,6 +417,9 @@ func (r *videoRepo) BatchCreateVideos(ctx context.Context, videos []*models.Vide
 			return fmt.Errorf("retrieve video ID: %w", err)
 		}
 		video.ID = videoID
+		if err := insertStreams(ctx, tx, videoID, video.Streams); err != nil {
+			return err
+		}
 		// Insert screenshots
 		base64Images, err := screenshots.EncodeImages()
 		if err != nil {
@@ -486,6 +504,9 @@ func (r *videoRepo) GetAllVideoHashes(ctx context.Context) ([]*models.Videohash,
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving all video hashes: %w", err)
 	}
+	if err := r.attachSignatures(ctx, hashes); err != nil {
+		return nil, err
+	}
 	return hashes, nil
 }
 
@@ -681,8 +702,8 @@ func buildInsertQueryAndValues(v any) ([]string, []string, []any, error) {
 		fieldType := rt.Field(i)
 		dbTag := fieldType.Tag.Get("db")
 
-		// Skip untagged fields
-		if dbTag == "" {
+		// Skip untagged fields and the ones stored in other tables
+		if dbTag == "" || dbTag == "-" {
 			continue
 		}
 		// Skip the primary key if it's auto-increment
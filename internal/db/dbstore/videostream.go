@@ -0,0 +1,67 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"govdupes/internal/models"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+)
+
+// insertStreams stores the probed streams of a video.
+func insertStreams(ctx context.Context, tx *sql.Tx, videoID int64, streams []models.VideoStream) error {
+	for _, s := range streams {
+		_, err := tx.ExecContext(ctx, `
+			INSERT OR REPLACE INTO video_stream (
+				FK_stream_video, streamIndex, codecType, codecName, profile, width, height,
+				pixFmt, bitRate, sampleRate, channels, language, isDefault, attachedPic
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+		`, videoID, s.Index, s.CodecType, s.CodecName, s.Profile, s.Width, s.Height,
+			s.PixFmt, s.BitRate, s.SampleRate, s.Channels, s.Language, s.IsDefault, s.AttachedPic)
+		if err != nil {
+			return fmt.Errorf("insert stream %d: %w", s.Index, err)
+		}
+	}
+	return nil
+}
+
+// streamQueryBatch is how many videos attachStreams queries at once, well
+// below SQLite's limit on query parameters.
+const streamQueryBatch = 500
+
+// attachStreams loads the streams of every video in videos.
+func (r *videoRepo) attachStreams(ctx context.Context, videos []*models.Video) error {
+	byID := make(map[int64]*models.Video, len(videos))
+	for _, v := range videos {
+		v.Streams = nil
+		byID[v.ID] = v
+	}
+
+	for batch := range slices.Chunk(videos, streamQueryBatch) {
+		args := make([]any, len(batch))
+		for i, v := range batch {
+			args[i] = v.ID
+		}
+		query := fmt.Sprintf(`
+			SELECT *
+			FROM video_stream
+			WHERE FK_stream_video IN (%s)
+			ORDER BY FK_stream_video, streamIndex;
+		`, strings.TrimSuffix(strings.Repeat("?,", len(batch)), ","))
+
+		var streams []*models.VideoStream
+		if err := sqlscan.Select(ctx, r.db, &streams, query, args...); err != nil {
+			return fmt.Errorf("querying video streams: %w", err)
+		}
+		for _, s := range streams {
+			if v, ok := byID[s.FKStreamVideo]; ok {
+				v.Streams = append(v.Streams, *s)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package dbstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"govdupes/internal/models"
+)
+
+func TestAttachStreams(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	store := NewVideoStore(db)
+
+	var batch []*models.VideoData
+	for _, path := range []string{"/a.mkv", "/b.mkv"} {
+		batch = append(batch, &models.VideoData{
+			Video: models.Video{Path: path, FileName: path[1:], CreatedAt: time.Now(), ModifiedAt: time.Now(), Streams: []models.VideoStream{
+				{Index: 1, CodecType: "audio", CodecName: "aac", Language: path},
+				{Index: 0, CodecType: "video", CodecName: "h264"},
+			}},
+			Videohash: models.Videohash{HashValue: "p:0", HashType: models.HashTypePHash, Duration: 60},
+		})
+	}
+	if err := store.BatchCreateVideos(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+	var a int64
+	if err := db.QueryRow(`SELECT FK_video_videohash FROM video WHERE path = '/a.mkv';`).Scan(&a); err != nil {
+		t.Fatal(err)
+	}
+
+	videos, err := store.GetVideosByVideohashIDs(ctx, []int64{a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 1 || len(videos[a]) != 1 {
+		t.Fatalf("GetVideosByVideohashIDs() = %v, want only /a.mkv", videos)
+	}
+	streams := videos[a][0].Streams
+	if len(streams) != 2 || streams[0].Index != 0 || streams[1].Language != "/a.mkv" {
+		t.Errorf("streams of /a.mkv = %+v, want its own two in index order", streams)
+	}
+}
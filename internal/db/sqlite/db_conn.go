@@ -2,14 +2,22 @@ package sqlite
 
 import (
 	"database/sql"
+	"fmt"
 	"log/slog"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
 
 func InitDB(dbPath string) *sql.DB {
 	slog.Info("Initializing database connection", slog.String("Path", dbPath))
-	db, err := sql.Open("sqlite", dbPath)
+	// foreign_keys is a per connection setting, only the DSN reaches every
+	// connection of the pool
+	dsn := dbPath + "?_pragma=foreign_keys(1)"
+	if strings.Contains(dbPath, "?") {
+		dsn = dbPath + "&_pragma=foreign_keys(1)"
+	}
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		slog.Error("Error opening SQLite database connection", slog.String("Path", dbPath), slog.Any("error", err))
 		return db
@@ -21,10 +29,6 @@ func InitDB(dbPath string) *sql.DB {
 		return nil
 	}
 
-	_, err = db.Exec("PRAGMA foreign_keys = ON;")
-	if err != nil {
-		slog.Error("Error setting PRAGMA foreign_keys", slog.Any("error", err))
-	}
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS video (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -48,6 +52,22 @@ func InitDB(dbPath string) *sql.DB {
 			device INTEGER,
 			sampleRateAvg INTEGER,
 			avgFrameRate REAL,
+			container TEXT NOT NULL DEFAULT '',
+			mediaCreatedAt DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+			rotation INTEGER NOT NULL DEFAULT 0,
+			pixFmt TEXT NOT NULL DEFAULT '',
+			videoProfile TEXT NOT NULL DEFAULT '',
+			videoLevel INTEGER NOT NULL DEFAULT 0,
+			colorTransfer TEXT NOT NULL DEFAULT '',
+			colorPrimaries TEXT NOT NULL DEFAULT '',
+			audioChannels INTEGER NOT NULL DEFAULT 0,
+			audioLanguages TEXT NOT NULL DEFAULT '',
+			subtitleLanguages TEXT NOT NULL DEFAULT '',
+			videoStreams INTEGER NOT NULL DEFAULT 0,
+			audioStreams INTEGER NOT NULL DEFAULT 0,
+			subtitleTracks INTEGER NOT NULL DEFAULT 0,
+			videoStreamIndex INTEGER NOT NULL DEFAULT -1,
+			audioStreamIndex INTEGER NOT NULL DEFAULT -1,
 			FK_video_videohash INTEGER,
 			FOREIGN KEY (FK_video_videohash) REFERENCES videohash (id) ON DELETE CASCADE
 		);
@@ -55,6 +75,27 @@ func InitDB(dbPath string) *sql.DB {
 	if err != nil {
 		slog.Error("Error creating the video table", slog.Any("error", err))
 	}
+	for _, col := range [][2]string{
+		{"container", "TEXT NOT NULL DEFAULT ''"},
+		// the zero time, sqlscan can't scan NULL into a time.Time
+		{"mediaCreatedAt", "DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00+00:00'"},
+		{"rotation", "INTEGER NOT NULL DEFAULT 0"},
+		{"pixFmt", "TEXT NOT NULL DEFAULT ''"},
+		{"videoProfile", "TEXT NOT NULL DEFAULT ''"},
+		{"videoLevel", "INTEGER NOT NULL DEFAULT 0"},
+		{"colorTransfer", "TEXT NOT NULL DEFAULT ''"},
+		{"colorPrimaries", "TEXT NOT NULL DEFAULT ''"},
+		{"audioChannels", "INTEGER NOT NULL DEFAULT 0"},
+		{"audioLanguages", "TEXT NOT NULL DEFAULT ''"},
+		{"subtitleLanguages", "TEXT NOT NULL DEFAULT ''"},
+		{"videoStreams", "INTEGER NOT NULL DEFAULT 0"},
+		{"audioStreams", "INTEGER NOT NULL DEFAULT 0"},
+		{"subtitleTracks", "INTEGER NOT NULL DEFAULT 0"},
+		{"videoStreamIndex", "INTEGER NOT NULL DEFAULT -1"},
+		{"audioStreamIndex", "INTEGER NOT NULL DEFAULT -1"},
+	} {
+		addColumnIfMissing(db, "video", col[0], col[1])
+	}
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS videohash (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -68,6 +109,8 @@ func InitDB(dbPath string) *sql.DB {
 	if err != nil {
 		slog.Error("Error creating the videohash table", slog.Any("error", err))
 	}
+	addColumnIfMissing(db, "videohash", "frameHashes", "BLOB")
+	addColumnIfMissing(db, "videohash", "audioFingerprint", "BLOB")
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS screenshot (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -79,6 +122,148 @@ func InitDB(dbPath string) *sql.DB {
 	if err != nil {
 		slog.Error("Error creating the screenshot table", slog.Any("error", err))
 	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS selection_rule (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			expression TEXT NOT NULL,
+			createdAt DATETIME
+		);
+	`)
+	if err != nil {
+		slog.Error("Error creating the selection_rule table", slog.Any("error", err))
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS videohash_signature (
+			FK_signature_videohash INTEGER NOT NULL,
+			algorithm TEXT NOT NULL,
+			words INTEGER NOT NULL,
+			hashes BLOB,
+			PRIMARY KEY (FK_signature_videohash, algorithm),
+			FOREIGN KEY (FK_signature_videohash) REFERENCES videohash (id) ON DELETE CASCADE
+		);
+	`)
+	if err != nil {
+		slog.Error("Error creating the videohash_signature table", slog.Any("error", err))
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS videohash_match (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			FK_match_a INTEGER NOT NULL,
+			FK_match_b INTEGER NOT NULL,
+			matchType TEXT NOT NULL,
+			startA REAL,
+			endA REAL,
+			startB REAL,
+			endB REAL,
+			speed REAL,
+			similarity REAL,
+			transform TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY (FK_match_a) REFERENCES videohash (id) ON DELETE CASCADE,
+			FOREIGN KEY (FK_match_b) REFERENCES videohash (id) ON DELETE CASCADE
+		);
+	`)
+	if err != nil {
+		slog.Error("Error creating the videohash_match table", slog.Any("error", err))
+	}
+	addColumnIfMissing(db, "videohash_match", "transform", "TEXT NOT NULL DEFAULT ''")
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS review_decision (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			decision TEXT NOT NULL,
+			createdAt DATETIME
+		);
+	`)
+	if err != nil {
+		slog.Error("Error creating the review_decision table", slog.Any("error", err))
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS review_decision_videohash (
+			FK_decision INTEGER NOT NULL,
+			FK_videohash INTEGER NOT NULL,
+			PRIMARY KEY (FK_decision, FK_videohash),
+			FOREIGN KEY (FK_decision) REFERENCES review_decision (id) ON DELETE CASCADE,
+			FOREIGN KEY (FK_videohash) REFERENCES videohash (id) ON DELETE CASCADE
+		);
+	`)
+	if err != nil {
+		slog.Error("Error creating the review_decision_videohash table", slog.Any("error", err))
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS scan_error (
+			path TEXT PRIMARY KEY,
+			stage TEXT NOT NULL,
+			error TEXT NOT NULL,
+			size INTEGER,
+			modifiedAt DATETIME,
+			occurredAt DATETIME,
+			ignored INTEGER NOT NULL DEFAULT 0
+		);
+	`)
+	if err != nil {
+		slog.Error("Error creating the scan_error table", slog.Any("error", err))
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS video_stream (
+			FK_stream_video INTEGER NOT NULL,
+			streamIndex INTEGER NOT NULL,
+			codecType TEXT NOT NULL,
+			codecName TEXT NOT NULL DEFAULT '',
+			profile TEXT NOT NULL DEFAULT '',
+			width INTEGER NOT NULL DEFAULT 0,
+			height INTEGER NOT NULL DEFAULT 0,
+			pixFmt TEXT NOT NULL DEFAULT '',
+			bitRate INTEGER NOT NULL DEFAULT 0,
+			sampleRate INTEGER NOT NULL DEFAULT 0,
+			channels INTEGER NOT NULL DEFAULT 0,
+			language TEXT NOT NULL DEFAULT '',
+			isDefault INTEGER NOT NULL DEFAULT 0,
+			attachedPic INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (FK_stream_video, streamIndex),
+			FOREIGN KEY (FK_stream_video) REFERENCES video (id) ON DELETE CASCADE
+		);
+	`)
+	if err != nil {
+		slog.Error("Error creating the video_stream table", slog.Any("error", err))
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS health_check (
+			path TEXT PRIMARY KEY,
+			status TEXT NOT NULL,
+			details TEXT NOT NULL DEFAULT '',
+			full INTEGER NOT NULL DEFAULT 0,
+			size INTEGER,
+			modifiedAt DATETIME,
+			checkedAt DATETIME
+		);
+	`)
+	if err != nil {
+		slog.Error("Error creating the health_check table", slog.Any("error", err))
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS scan_job (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			dirs TEXT NOT NULL DEFAULT '',
+			total INTEGER NOT NULL DEFAULT 0,
+			finished INTEGER NOT NULL DEFAULT 0,
+			createdAt DATETIME
+		);
+	`)
+	if err != nil {
+		slog.Error("Error creating the scan_job table", slog.Any("error", err))
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS scan_task (
+			FK_task_job INTEGER NOT NULL,
+			path TEXT NOT NULL,
+			stage TEXT NOT NULL,
+			PRIMARY KEY (FK_task_job, path),
+			FOREIGN KEY (FK_task_job) REFERENCES scan_job (id) ON DELETE CASCADE
+		);
+	`)
+	if err != nil {
+		slog.Error("Error creating the scan_task table", slog.Any("error", err))
+	}
 
 	slog.Info("Database initialized successfully")
 	return db
@@ -87,3 +272,35 @@ func InitDB(dbPath string) *sql.DB {
 func Close(db *sql.DB) error {
 	return db.Close()
 }
+
+// addColumnIfMissing adds a column to tables created by an older version.
+func addColumnIfMissing(db *sql.DB, table, column, definition string) {
+	rows, err := db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s');", table))
+	if err != nil {
+		slog.Error("Error reading table info", slog.String("table", table), slog.Any("error", err))
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			slog.Error("Error scanning table info", slog.String("table", table), slog.Any("error", err))
+			return
+		}
+		if name == column {
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("Error reading table info", slog.String("table", table), slog.Any("error", err))
+		return
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition))
+	if err != nil {
+		slog.Error("Error adding column", slog.String("table", table), slog.String("column", column), slog.Any("error", err))
+		return
+	}
+	slog.Info("Added column", slog.String("table", table), slog.String("column", column))
+}
@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestForeignKeysOnEveryConnection(t *testing.T) {
+	ctx := context.Background()
+	db := InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if db == nil {
+		t.Fatal("InitDB failed")
+	}
+	defer db.Close()
+
+	// hold several connections at once so the pool has to open new ones
+	var conns []*sql.Conn
+	for range 3 {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	for i, conn := range conns {
+		var on int
+		if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys;").Scan(&on); err != nil {
+			t.Fatal(err)
+		}
+		if on != 1 {
+			t.Errorf("connection %d has foreign_keys = %d, want 1", i, on)
+		}
+	}
+}
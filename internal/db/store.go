@@ -19,4 +19,41 @@ type VideoStore interface {
 	GetDuplicateVideoData(ctx context.Context) ([][]*models.VideoData, error)
 	GetVideosByVideohashIDs(ctx context.Context, hashIDs []int64) (map[int64][]*models.Video, error)
 	DeleteVideoByID(ctx context.Context, videoID int64) error
+	ReplaceVideohashMatches(ctx context.Context, matches []*models.VideohashMatch) error
+	GetVideohashMatches(ctx context.Context) ([]*models.VideohashMatch, error)
+}
+
+type RuleStore interface {
+	SaveRule(ctx context.Context, rule *models.SelectionRule) error
+	GetRules(ctx context.Context) ([]*models.SelectionRule, error)
+	GetRule(ctx context.Context, name string) (*models.SelectionRule, error)
+	DeleteRule(ctx context.Context, name string) error
+}
+
+type ReviewStore interface {
+	CreateReviewDecision(ctx context.Context, decision *models.ReviewDecision) error
+	GetReviewDecisions(ctx context.Context) ([]*models.ReviewDecision, error)
+	DeleteReviewDecision(ctx context.Context, id int64) error
+}
+
+type ScanErrorStore interface {
+	RecordScanError(ctx context.Context, e *models.ScanError) error
+	GetScanErrors(ctx context.Context, includeIgnored bool) ([]*models.ScanError, error)
+	DeleteScanErrors(ctx context.Context, paths []string) error
+	DeleteScanErrorsAt(ctx context.Context, stage models.ScanStage) error
+	IgnoreScanError(ctx context.Context, path string) error
+}
+
+type HealthStore interface {
+	SaveHealthCheck(ctx context.Context, check *models.HealthCheck) error
+	GetHealthChecks(ctx context.Context) ([]*models.HealthCheck, error)
+}
+
+type ScanJobStore interface {
+	CreateScanJob(ctx context.Context, job *models.ScanJob, paths []string) error
+	AddScanTasks(ctx context.Context, jobID int64, paths []string) error
+	GetUnfinishedScanJob(ctx context.Context) (*models.ScanJob, error)
+	GetPendingScanTasks(ctx context.Context, jobID int64) ([]*models.ScanTask, error)
+	SetScanTaskStage(ctx context.Context, jobID int64, paths []string, stage models.ScanTaskStage) error
+	FinishScanJob(ctx context.Context, jobID int64) error
 }
@@ -0,0 +1,86 @@
+package duplicate
+
+import (
+	"math"
+	"math/bits"
+
+	"govdupes/internal/models"
+)
+
+// SignatureSimilarity compares two signatures of the same algorithm like
+// SequenceSimilarity, hash i of the shorter against the same relative
+// position in the longer with one hash of slack. Hashes match within
+// maxDistance differing bits per 64-bit word, the same as the pHash. A single
+// hash (FastPhash) gives 0 or 1. Hashes that are all zero couldn't be
+// computed and are skipped.
+func SignatureSimilarity(a, b *models.Signature, maxDistance int) float64 {
+	if a.Words != b.Words {
+		return 0
+	}
+	if a.Len() > b.Len() {
+		a, b = b, a
+	}
+	if a.Len() == 0 {
+		return 0
+	}
+
+	maxDistance *= a.Words
+	scale := 0.0
+	if a.Len() > 1 {
+		scale = float64(b.Len()-1) / float64(a.Len()-1)
+	}
+	matched, compared := 0, 0
+	for i := range a.Len() {
+		j := int(math.Round(float64(i) * scale))
+		if isZero(a.At(i)) || isZero(b.At(j)) {
+			continue
+		}
+		compared++
+		for k := max(0, j-1); k <= min(b.Len()-1, j+1); k++ {
+			if wordsDistance(a.At(i), b.At(k)) <= maxDistance {
+				matched++
+				break
+			}
+		}
+	}
+	if compared == 0 {
+		return 0
+	}
+	return float64(matched) / float64(compared)
+}
+
+// signatureVotes counts the signatures both hashes have and how many of
+// them match. Variants of transformed frames don't vote, see transformMatch.
+func signatureVotes(a, b *models.Videohash, maxDistance int, minSimilarity float64) (votes, shared int) {
+	for i := range a.Signatures {
+		if a.Signatures[i].Transform() != models.TransformNone {
+			continue
+		}
+		other := b.Signature(a.Signatures[i].Algorithm)
+		if other == nil {
+			continue
+		}
+		shared++
+		if SignatureSimilarity(&a.Signatures[i], other, maxDistance) >= minSimilarity {
+			votes++
+		}
+	}
+	return votes, shared
+}
+
+func wordsDistance(a, b []uint64) int {
+	distance := 0
+	for i := range a {
+		distance += bits.OnesCount64(a[i] ^ b[i])
+	}
+	return distance
+}
+
+func isZero(words []uint64) bool {
+	for _, w := range words {
+		if w != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package duplicate
+
+import (
+	"math/bits"
+)
+
+// MatchMode decides which signals make two videos duplicates.
+type MatchMode string
+
+const (
+	// MatchVideo only compares the video hashes.
+	MatchVideo MatchMode = "video"
+	// MatchAudio only compares the audio fingerprints, finding the same
+	// audio under different video.
+	MatchAudio MatchMode = "audio"
+	// MatchBoth needs the video and, when both have one, the audio to match.
+	// It rules out dubs and commentary tracks over the same video.
+	MatchBoth MatchMode = "both"
+	// MatchEither accepts a match of the video or the audio, catching
+	// re-encodes whose frames went black.
+	MatchEither MatchMode = "either"
+)
+
+// UsesAudio reports whether audio fingerprints are needed.
+func (m MatchMode) UsesAudio() bool {
+	return m == MatchAudio || m == MatchBoth || m == MatchEither
+}
+
+const (
+	// audioMaxOffset is how far, in sub-fingerprints, one track may be
+	// shifted against the other (about 10 seconds).
+	audioMaxOffset = 108
+	// minAudioOverlap is the fewest non-silent sub-fingerprints compared for
+	// a similarity to count (about 5 seconds).
+	minAudioOverlap = 54
+)
+
+// AudioSimilarity returns one minus the bit error rate of a and b at the
+// offset, within audioMaxOffset, where they agree best. Unrelated audio is
+// around 0.5. Silent sub-fingerprints are skipped.
+func AudioSimilarity(a, b []uint32) float64 {
+	best := 0.0
+	for offset := -audioMaxOffset; offset <= audioMaxOffset; offset++ {
+		errors, compared := 0, 0
+		for i := max(0, -offset); i < len(a) && i+offset < len(b); i++ {
+			x, y := a[i], b[i+offset]
+			if x == 0 || y == 0 {
+				continue
+			}
+			errors += bits.OnesCount32(x ^ y)
+			compared++
+		}
+		if compared < minAudioOverlap {
+			continue
+		}
+		similarity := 1 - float64(errors)/float64(32*compared)
+		if similarity > best {
+			best = similarity
+		}
+	}
+	return best
+}
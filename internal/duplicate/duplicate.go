@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"log/slog"
 	"math"
+	"math/bits"
+	"slices"
+	"sort"
 
 	"govdupes/internal/models"
 )
@@ -11,12 +14,77 @@ import (
 type DuplicateOptions struct {
 	MaxDurationDiff int
 	MaxHashDistance int
+	// MaxFrameDistance and MinFrameMatch compare SlowPhash sequences: the
+	// fraction of frames within MaxFrameDistance bits must reach MinFrameMatch
+	MaxFrameDistance int
+	MinFrameMatch    float64
+	// MinAgreement is how many hashes, the pHash and each shared signature,
+	// must match for two videos to be neighbours. The pHash always has to be
+	// one of them, with fewer shared signatures all of them have to match.
+	MinAgreement int
+	// Mode combines the video and audio matches, MinAudioSimilarity is the
+	// lowest AudioSimilarity for the audio to match
+	Mode               MatchMode
+	MinAudioSimilarity float64
+	// Excluded pairs are never neighbours
+	Excluded Exclusions
+	// Temporal compares TemporalPhash hashes, its MinSceneMatch is the
+	// lowest SceneSimilarity for ScenePhash hashes
+	Temporal TemporalOptions
+	// OnCompared, which may be nil, is called as each hash has been
+	// compared with the others
+	OnCompared func()
+	// temporalNeighbours maps the position of a temporal hash to the
+	// positions of the hashes it aligned with
+	temporalNeighbours map[int][]int
+	// frames holds the SlowPhash frame hashes and the non-blank ScenePhash
+	// ones by position
+	frames [][]uint64
+	// transforms records pairs of positions that matched as flipped or
+	// rotated copies, hashes[b] is hashes[a] transformed
+	transforms map[[2]int]models.Transform
 }
 
-func FindVideoDuplicates(hashes []*models.Videohash) error {
-	options := DuplicateOptions{MaxDurationDiff: 5, MaxHashDistance: 4}
+func DefaultDuplicateOptions() DuplicateOptions {
+	return DuplicateOptions{
+		MaxDurationDiff:    5,
+		MaxHashDistance:    4,
+		MaxFrameDistance:   10,
+		MinFrameMatch:      0.8,
+		MinAgreement:       1,
+		Mode:               MatchVideo,
+		MinAudioSimilarity: 0.65,
+		Temporal:           DefaultTemporalOptions(),
+	}
+}
+
+// FindVideoDuplicates assigns a bucket to every hash. Pairs in
+// options.Excluded are never neighbours, but can still share a bucket
+// through a third video that matches both. Temporal hashes are neighbours
+// when they align, the aligned sections are returned. FastPhash and
+// SlowPhash hashes match when at least options.MinAgreement of their hashes
+// match, options.Mode decides how the audio counts.
+func FindVideoDuplicates(hashes []*models.Videohash, options DuplicateOptions) ([]*models.VideohashMatch, error) {
+	options.MinAgreement = max(1, options.MinAgreement)
+	if options.Mode == "" {
+		options.Mode = MatchVideo
+	}
 	initializeBuckets(hashes)
 
+	// parse the frame sequences once, not for every pair
+	options.frames = make([][]uint64, len(hashes))
+	for i, h := range hashes {
+		switch h.Kind() {
+		case models.HashTypeSlow:
+			options.frames[i] = h.Frames()
+		case models.HashTypeScene:
+			options.frames[i] = nonBlankFrames(h.Frames())
+		}
+	}
+
+	matches := findTemporalNeighbours(hashes, &options)
+	options.transforms = make(map[[2]int]models.Transform)
+
 	for i, video := range hashes {
 		if video.Bucket == -1 {
 			slog.Info("Video has no bucket, assigning to a bucket", slog.Int("index", i))
@@ -25,10 +93,69 @@ func FindVideoDuplicates(hashes []*models.Videohash) error {
 		} else {
 			slog.Debug("Video already has a bucket", slog.Int("index", i), slog.Any("video", video))
 		}
+		if options.OnCompared != nil {
+			options.OnCompared()
+		}
 	}
 
 	logBuckets(hashes)
-	return nil
+	return append(matches, transformMatches(hashes, options)...), nil
+}
+
+// transformMatches turns the recorded transform matches into matches, once
+// per pair.
+func transformMatches(hashes []*models.Videohash, options DuplicateOptions) []*models.VideohashMatch {
+	var matches []*models.VideohashMatch
+	seen := make(map[[2]int64]bool)
+	for pair, t := range options.transforms {
+		a, b := hashes[pair[0]], hashes[pair[1]]
+		key := [2]int64{min(a.ID, b.ID), max(a.ID, b.ID)}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		matches = append(matches, &models.VideohashMatch{
+			FKMatchA:   a.ID,
+			FKMatchB:   b.ID,
+			MatchType:  models.MatchDuplicate,
+			Speed:      1,
+			Similarity: 1,
+			Transform:  t,
+		})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].FKMatchA != matches[j].FKMatchA {
+			return matches[i].FKMatchA < matches[j].FKMatchA
+		}
+		return matches[i].FKMatchB < matches[j].FKMatchB
+	})
+	return matches
+}
+
+// findTemporalNeighbours aligns the temporal hashes and records which
+// positions in hashes matched each other.
+func findTemporalNeighbours(hashes []*models.Videohash, options *DuplicateOptions) []*models.VideohashMatch {
+	var temporalHashes []*models.Videohash
+	positions := make(map[int64]int)
+	for i, h := range hashes {
+		if h.HashType == models.HashTypeTemporal {
+			temporalHashes = append(temporalHashes, h)
+			positions[h.ID] = i
+		}
+	}
+	if len(temporalHashes) < 2 {
+		return nil
+	}
+
+	slog.Info("Aligning temporal hashes", slog.Int("count", len(temporalHashes)))
+	matches := FindTemporalMatches(temporalHashes, options.Temporal, options.Excluded)
+	options.temporalNeighbours = make(map[int][]int)
+	for _, m := range matches {
+		a, b := positions[m.FKMatchA], positions[m.FKMatchB]
+		options.temporalNeighbours[a] = append(options.temporalNeighbours[a], b)
+		options.temporalNeighbours[b] = append(options.temporalNeighbours[b], a)
+	}
+	return matches
 }
 
 func initializeBuckets(hashes []*models.Videohash) {
@@ -86,28 +213,250 @@ func findNeighbors(index int, hashes []*models.Videohash, options DuplicateOptio
 			continue
 		}
 
-		durationDiff := math.Abs(float64(currentVideo.Duration - neighbor.Duration))
-		slog.Debug("Duration difference", slog.Int("video1", index), slog.Int("video2", i), slog.Float64("difference", durationDiff))
-
-		if int(durationDiff) > options.MaxDurationDiff {
-			slog.Debug("Skipping video due to duration difference", slog.Int("video", i), slog.Float64("difference", durationDiff))
+		if options.Excluded.Contains(currentVideo.ID, neighbor.ID) {
+			slog.Debug("Skipping pair marked as not duplicates", slog.Int("video1", index), slog.Int("video2", i))
 			continue
 		}
 
-		hashDistance, _ := calcHammingDistance(currentVideo.HashValue, neighbor.HashValue)
-		slog.Debug("Hash distance", slog.Int("video1", index), slog.Int("video2", i), slog.Int("distance", hashDistance))
+		videoMatch := false
+		if options.Mode != MatchAudio {
+			videoMatch = videoMatches(index, i, hashes, options)
+		}
+		transform := models.TransformNone
+		if !videoMatch && options.Mode != MatchAudio {
+			transform, videoMatch = transformMatch(index, i, hashes, options)
+		}
+
+		// audio is only compared for videos of about the same length
+		hasAudio := len(currentVideo.AudioFingerprint) > 0 && len(neighbor.AudioFingerprint) > 0 &&
+			durationMatches(currentVideo, neighbor, options)
+		audioMatch := false
+		if hasAudio && options.Mode.UsesAudio() {
+			similarity := AudioSimilarity(currentVideo.AudioFingerprint, neighbor.AudioFingerprint)
+			slog.Debug("Audio similarity", slog.Int("video1", index), slog.Int("video2", i), slog.Float64("similarity", similarity))
+			audioMatch = similarity >= options.MinAudioSimilarity
+		}
 
-		if hashDistance <= options.MaxHashDistance {
+		var match bool
+		switch options.Mode {
+		case MatchAudio:
+			match = audioMatch
+		case MatchBoth:
+			match = videoMatch && (audioMatch || !hasAudio)
+		case MatchEither:
+			match = videoMatch || audioMatch
+		default:
+			match = videoMatch
+		}
+
+		if match {
+			if transform != models.TransformNone {
+				options.transforms[[2]int{index, i}] = transform
+			}
 			neighbors = append(neighbors, i)
-			slog.Info("Neighbor found", slog.Int("video1", index), slog.Int("video2", i))
-		} else {
-			slog.Debug("Hash distance too high", slog.Int("video1", index), slog.Int("video2", i), slog.Int("threshold", options.MaxHashDistance))
+			slog.Info("Neighbor found", slog.Int("video1", index), slog.Int("video2", i),
+				slog.Bool("video", videoMatch), slog.Bool("audio", audioMatch), slog.String("transform", string(transform)))
 		}
 	}
 
 	return neighbors
 }
 
+func durationMatches(a, b *models.Videohash, options DuplicateOptions) bool {
+	durationDiff := math.Abs(float64(a.Duration - b.Duration))
+	return int(durationDiff) <= options.MaxDurationDiff
+}
+
+// videoMatches compares the video hashes of two positions. Only hashes of
+// the same kind are compared, temporal hashes match when they aligned and
+// scene hashes when enough scenes are shared, whatever their durations.
+func videoMatches(index, i int, hashes []*models.Videohash, options DuplicateOptions) bool {
+	currentVideo, neighbor := hashes[index], hashes[i]
+	kind := currentVideo.Kind()
+	// blank hashes are only kept for their audio
+	if neighbor.Kind() != kind || currentVideo.IsBlank() || neighbor.IsBlank() {
+		return false
+	}
+	if kind == models.HashTypeTemporal {
+		return slices.Contains(options.temporalNeighbours[index], i)
+	}
+	if kind == models.HashTypeScene {
+		match := scenesMatch(options.frames[index], options.frames[i], options.MaxFrameDistance, options.Temporal.MinSceneMatch)
+		slog.Debug("Scene match", slog.Int("video1", index), slog.Int("video2", i), slog.Bool("match", match))
+		return match
+	}
+
+	if !durationMatches(currentVideo, neighbor, options) {
+		slog.Debug("Skipping video due to duration difference", slog.Int("video", i))
+		return false
+	}
+
+	// the pHash always has to match, the other hashes can only confirm it
+	if kind == models.HashTypeSlow {
+		similarity := SequenceSimilarity(options.frames[index], options.frames[i], options.MaxFrameDistance)
+		slog.Debug("Sequence similarity", slog.Int("video1", index), slog.Int("video2", i), slog.Float64("similarity", similarity))
+		if similarity < options.MinFrameMatch {
+			return false
+		}
+	} else {
+		hashDistance, err := calcHammingDistance(currentVideo.HashValue, neighbor.HashValue)
+		if err != nil {
+			slog.Debug("Skipping video with a different hash length", slog.Int("video1", index), slog.Int("video2", i))
+			return false
+		}
+		slog.Debug("Hash distance", slog.Int("video1", index), slog.Int("video2", i), slog.Int("distance", hashDistance))
+		if hashDistance > options.MaxHashDistance {
+			return false
+		}
+	}
+	votes, shared := signatureVotes(currentVideo, neighbor, options.MaxHashDistance, options.MinFrameMatch)
+
+	// videos hashed before an algorithm was added can't agree on it
+	required := min(options.MinAgreement, 1+shared)
+	if 1+votes < required {
+		slog.Debug("Not enough hashes agree", slog.Int("video1", index), slog.Int("video2", i), slog.Int("agreeing", 1+votes), slog.Int("required", required))
+		return false
+	}
+	return true
+}
+
+// transformMatch compares the variants of one hash with the pHash of the
+// other, in both directions. The transform returned turns hashes[index] into
+// hashes[i]. Variants are compared on their own, the agreement of other
+// algorithms isn't needed.
+func transformMatch(index, i int, hashes []*models.Videohash, options DuplicateOptions) (models.Transform, bool) {
+	a, b := hashes[index], hashes[i]
+	kind := a.Kind()
+	if (kind != models.HashTypePHash && kind != models.HashTypeSlow) || b.Kind() != kind || a.IsBlank() || b.IsBlank() ||
+		!durationMatches(a, b, options) {
+		return models.TransformNone, false
+	}
+
+	for _, t := range models.Transforms {
+		if variantMatches(a, t, b, i, options) {
+			return t, true
+		}
+		if variantMatches(b, t, a, index, options) {
+			return t.Inverse(), true
+		}
+	}
+	return models.TransformNone, false
+}
+
+// variantMatches reports whether the variant t of from matches the pHash of
+// hashes[to].
+func variantMatches(from *models.Videohash, t models.Transform, to *models.Videohash, toIndex int, options DuplicateOptions) bool {
+	variant := from.Signature(models.VariantAlgorithm(t))
+	if variant == nil || variant.Len() == 0 {
+		return false
+	}
+	if from.Kind() == models.HashTypeSlow {
+		return SequenceSimilarity(variant.Hashes, options.frames[toIndex], options.MaxFrameDistance) >= options.MinFrameMatch
+	}
+	distance, err := calcHammingDistance(fmt.Sprintf("p:%016x", variant.Hashes[0]), to.HashValue)
+	return err == nil && distance <= options.MaxHashDistance
+}
+
+// SequenceSimilarity returns the fraction of frames of the shorter sequence
+// that are within maxDistance bits of the frame at the same relative position
+// in the other, allowing one frame of slack either side. SlowPhash samples
+// proportionally to duration, so the positions line up for videos of slightly
+// different length. Pairs where either frame is blank are not counted.
+func SequenceSimilarity(a, b []uint64, maxDistance int) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(a) == 0 {
+		return 0
+	}
+
+	scale := 0.0
+	if len(a) > 1 {
+		scale = float64(len(b)-1) / float64(len(a)-1)
+	}
+	matched, compared := 0, 0
+	for i, frame := range a {
+		j := int(math.Round(float64(i) * scale))
+		if models.IsBlankFrame(frame) || models.IsBlankFrame(b[j]) {
+			continue
+		}
+		compared++
+		if frameMatches(frame, b, j, maxDistance) {
+			matched++
+		}
+	}
+	if compared == 0 {
+		return 0
+	}
+	return float64(matched) / float64(compared)
+}
+
+// SceneSimilarity pairs every scene of a with a different scene of b within
+// maxDistance bits and returns the pairs found over the scene count of the
+// video with more scenes, so a clip of a few scenes doesn't match the whole.
+// Blank scenes are not counted.
+func SceneSimilarity(a, b []uint64, maxDistance int) float64 {
+	a, b = nonBlankFrames(a), nonBlankFrames(b)
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	return float64(pairScenes(a, b, maxDistance, 0)) / float64(max(len(a), len(b)))
+}
+
+// scenesMatch reports whether SceneSimilarity of the non-blank scenes a and
+// b reaches minSimilarity. Every pair compares all scenes of both, so the
+// scene counts are checked first: there can't be more pairs than scenes in
+// the video with fewer.
+func scenesMatch(a, b []uint64, maxDistance int, minSimilarity float64) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	// the fewest pairs for minSimilarity, without float noise rounding up,
+	// and at least one whatever minSimilarity is
+	need := max(1, int(math.Ceil(minSimilarity*float64(max(len(a), len(b)))-1e-9)))
+	if min(len(a), len(b)) < need {
+		return false
+	}
+	return pairScenes(a, b, maxDistance, need) >= need
+}
+
+// pairScenes pairs every scene of a with a different scene of b within
+// maxDistance bits and returns the pairs found. It gives up with -1 as soon
+// as fewer than need pairs are left to find.
+func pairScenes(a, b []uint64, maxDistance, need int) int {
+	used := make([]bool, len(b))
+	matched := 0
+	for k, x := range a {
+		if matched+len(a)-k < need {
+			return -1
+		}
+		best, bestDistance := -1, maxDistance+1
+		for j, y := range b {
+			if used[j] {
+				continue
+			}
+			if d := bits.OnesCount64(x ^ y); d < bestDistance {
+				best, bestDistance = j, d
+			}
+		}
+		if best >= 0 {
+			used[best] = true
+			matched++
+		}
+	}
+	return matched
+}
+
+func nonBlankFrames(frames []uint64) []uint64 {
+	var out []uint64
+	for _, f := range frames {
+		if !models.IsBlankFrame(f) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
 func propagateBucket(bucket int, neighbors []int, hashes []*models.Videohash) {
 	for _, neighborIndex := range neighbors {
 		neighbor := hashes[neighborIndex]
@@ -0,0 +1,216 @@
+package duplicate
+
+import (
+	"math/rand"
+	"testing"
+
+	"govdupes/internal/models"
+)
+
+func TestSequenceSimilarity(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	source := randomFrames(r, 100)
+
+	// the same video 3% longer, sampled at the same relative positions
+	longer := make([]uint64, 103)
+	for j := range longer {
+		longer[j] = source[j*99/102]
+	}
+
+	tests := []struct {
+		name string
+		a, b []uint64
+		min  float64
+		max  float64
+	}{
+		{"identical", source, source, 1, 1},
+		{"re-encode", source, noisy(r, source), 1, 1},
+		{"different length", source, noisy(r, longer), 0.95, 1},
+		{"unrelated", source, randomFrames(r, 100), 0, 0.05},
+		{"blank", source, make([]uint64, 100), 0, 0},
+	}
+	for _, tt := range tests {
+		got := SequenceSimilarity(tt.a, tt.b, 10)
+		if got < tt.min || got > tt.max {
+			t.Errorf("%s: SequenceSimilarity() = %.2f, want %.2f–%.2f", tt.name, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestSignatureSimilarity(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	frames := randomFrames(r, 40)
+	sig := func(hashes []uint64, words int) *models.Signature {
+		return &models.Signature{Algorithm: "dhash", Words: words, Hashes: hashes}
+	}
+
+	tests := []struct {
+		name string
+		a, b *models.Signature
+		min  float64
+		max  float64
+	}{
+		{"identical", sig(frames, 1), sig(frames, 1), 1, 1},
+		{"re-encode", sig(frames, 1), sig(noisy(r, frames), 1), 1, 1},
+		{"unrelated", sig(frames, 1), sig(randomFrames(r, 40), 1), 0, 0.1},
+		{"multi word", sig(frames, 4), sig(noisy(r, frames), 4), 1, 1},
+		{"word mismatch", sig(frames, 1), sig(frames, 4), 0, 0},
+		{"not computed", sig(frames, 1), sig(make([]uint64, 40), 1), 0, 0},
+	}
+	for _, tt := range tests {
+		got := SignatureSimilarity(tt.a, tt.b, 4)
+		if got < tt.min || got > tt.max {
+			t.Errorf("%s: SignatureSimilarity() = %.2f, want %.2f–%.2f", tt.name, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestHashAgreement(t *testing.T) {
+	hash := func(id int64, pHash string, dHash uint64) *models.Videohash {
+		v := &models.Videohash{ID: id, HashType: models.HashTypePHash, HashValue: pHash, Duration: 60}
+		if dHash != 0 {
+			v.Signatures = []models.Signature{{Algorithm: "dhash", Words: 1, Hashes: models.FrameHashes{dHash}}}
+		}
+		return v
+	}
+	tests := []struct {
+		name string
+		a, b *models.Videohash
+		want bool
+	}{
+		{"both agree", hash(1, "p:c3a1f00e12345678", 0x5a5a5a5a5a5a5a5a), hash(2, "p:c3a1f00e12345679", 0x5a5a5a5a5a5a5a5b), true},
+		{"dhash disagrees", hash(1, "p:c3a1f00e12345678", 0x5a5a5a5a5a5a5a5a), hash(2, "p:c3a1f00e12345678", 0xa5a5a5a5a5a5a5a5), false},
+		{"pHash disagrees", hash(1, "p:c3a1f00e12345678", 0x5a5a5a5a5a5a5a5a), hash(2, "p:3c1a0fe021436587", 0x5a5a5a5a5a5a5a5a), false},
+		// hashed before dhash was added
+		{"no dhash", hash(1, "p:c3a1f00e12345678", 0x5a5a5a5a5a5a5a5a), hash(2, "p:c3a1f00e12345678", 0), true},
+	}
+	for _, tt := range tests {
+		if _, err := FindVideoDuplicates([]*models.Videohash{tt.a, tt.b}, matchOptions(Exclusions{}, 2, MatchVideo)); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := tt.a.Bucket == tt.b.Bucket; got != tt.want {
+			t.Errorf("%s: same bucket = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func matchOptions(excluded Exclusions, minAgreement int, mode MatchMode) DuplicateOptions {
+	options := DefaultDuplicateOptions()
+	options.Excluded = excluded
+	options.MinAgreement = minAgreement
+	options.Mode = mode
+	return options
+}
+
+func randomAudio(r *rand.Rand, n int) []uint32 {
+	fp := make([]uint32, n)
+	for i := range fp {
+		fp[i] = r.Uint32() | 1
+	}
+	return fp
+}
+
+func TestMatchModes(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	audio := randomAudio(r, 600)
+	// the same track starting 40 sub-fingerprints later, with a few bit errors
+	shifted := append(randomAudio(r, 40), audio[:560]...)
+	for i := range shifted {
+		shifted[i] ^= 1 << r.Intn(32)
+	}
+	if got := AudioSimilarity(audio, shifted); got < 0.9 {
+		t.Errorf("AudioSimilarity(shifted) = %.2f, want >= 0.90", got)
+	}
+	if got := AudioSimilarity(audio, randomAudio(r, 600)); got > 0.6 {
+		t.Errorf("AudioSimilarity(unrelated) = %.2f, want <= 0.60", got)
+	}
+
+	fast := func(id int64, value string, fp []uint32) *models.Videohash {
+		return &models.Videohash{ID: id, HashType: models.HashTypePHash, HashValue: value, Duration: 60, AudioFingerprint: fp}
+	}
+	tests := []struct {
+		name string
+		mode MatchMode
+		a, b *models.Videohash
+		want bool
+	}{
+		{"video", MatchVideo, fast(1, "p:c3a1f00e12345678", audio), fast(2, "p:c3a1f00e12345678", randomAudio(r, 600)), true},
+		{"dub", MatchBoth, fast(1, "p:c3a1f00e12345678", audio), fast(2, "p:c3a1f00e12345678", randomAudio(r, 600)), false},
+		{"both", MatchBoth, fast(1, "p:c3a1f00e12345678", audio), fast(2, "p:c3a1f00e12345678", shifted), true},
+		{"both without audio", MatchBoth, fast(1, "p:c3a1f00e12345678", audio), fast(2, "p:c3a1f00e12345678", nil), true},
+		{"same audio", MatchAudio, fast(1, "p:c3a1f00e12345678", audio), fast(2, "p:0f0f0f0f87654321", shifted), true},
+		{"black frames", MatchEither, fast(1, "p:c3a1f00e12345678", audio), fast(2, "p:0000000000000000", shifted), true},
+		{"blank video", MatchEither, fast(1, "p:0000000000000000", audio), fast(2, "p:0000000000000000", randomAudio(r, 600)), false},
+	}
+	for _, tt := range tests {
+		hashes := []*models.Videohash{tt.a, tt.b}
+		if _, err := FindVideoDuplicates(hashes, matchOptions(Exclusions{}, 1, tt.mode)); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := tt.a.Bucket == tt.b.Bucket; got != tt.want {
+			t.Errorf("%s: same bucket = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTransformMatch(t *testing.T) {
+	a := &models.Videohash{ID: 1, HashType: models.HashTypePHash, HashValue: "p:c3a1f00e12345678", Duration: 60,
+		Signatures: []models.Signature{{Algorithm: models.VariantAlgorithm(models.TransformRot90), Words: 1, Hashes: models.FrameHashes{0x0f0f0f0f87654321}}}}
+	// a re-upload rotated clockwise, hashed without variants
+	b := &models.Videohash{ID: 2, HashType: models.HashTypePHash, HashValue: "p:0f0f0f0f87654321", Duration: 60}
+	c := &models.Videohash{ID: 3, HashType: models.HashTypePHash, HashValue: "p:5a5a5a5a5a5a5a5a", Duration: 60}
+
+	matches, err := FindVideoDuplicates([]*models.Videohash{b, a, c}, matchOptions(Exclusions{}, 1, MatchVideo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Bucket != b.Bucket || a.Bucket == c.Bucket {
+		t.Fatalf("buckets = %d, %d, %d, want the rotated copy with the original only", a.Bucket, b.Bucket, c.Bucket)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("got %d matches, want 1", len(matches))
+	}
+	// b was compared first, so it is A, and a is b rotated anticlockwise
+	m := matches[0]
+	if m.FKMatchA != 2 || m.FKMatchB != 1 || m.Transform != models.TransformRot270 {
+		t.Errorf("match = %d -> %d %q, want 2 -> 1 rot270", m.FKMatchA, m.FKMatchB, m.Transform)
+	}
+	if got, want := m.Describe(1, "b.mp4"), "same as b.mp4, rotated 90° anticlockwise"; got != want {
+		t.Errorf("Describe() = %q, want %q", got, want)
+	}
+}
+
+func TestSceneSimilarity(t *testing.T) {
+	r := rand.New(rand.NewSource(11))
+	scenes := randomFrames(r, 40)
+
+	tests := []struct {
+		name string
+		b    []uint64
+		min  float64
+		max  float64
+	}{
+		{"re-encode", noisy(r, scenes), 1, 1},
+		{"intro and outro trimmed", noisy(r, scenes[3:37]), 0.85, 0.85},
+		{"extra cut found", append(noisy(r, scenes), randomFrames(r, 2)...), 0.95, 0.96},
+		{"short clip", scenes[10:16], 0.15, 0.15},
+		{"unrelated", randomFrames(r, 40), 0, 0.05},
+		{"blank", make([]uint64, 40), 0, 0},
+	}
+	for _, tt := range tests {
+		got := SceneSimilarity(scenes, tt.b, 10)
+		if got < tt.min || got > tt.max {
+			t.Errorf("%s: SceneSimilarity() = %.2f, want %.2f–%.2f", tt.name, got, tt.min, tt.max)
+		}
+		// the prefilter and the early exit must not change the outcome
+		for _, minSimilarity := range []float64{0.15, 0.6, 0.85, 1} {
+			if match := scenesMatch(nonBlankFrames(scenes), nonBlankFrames(tt.b), 10, minSimilarity); match != (got >= minSimilarity) {
+				t.Errorf("%s: scenesMatch(%.2f) = %v with a similarity of %.2f", tt.name, minSimilarity, match, got)
+			}
+		}
+	}
+	// a minimum of 0 still needs a shared scene
+	if scenesMatch(scenes, randomFrames(r, 40), 10, 0) {
+		t.Error("scenesMatch(0) matched unrelated scenes")
+	}
+}
@@ -0,0 +1,35 @@
+package duplicate
+
+import "govdupes/internal/models"
+
+// Exclusions holds the videohash ID pairs the user marked as not duplicates.
+type Exclusions map[[2]int64]struct{}
+
+// NewExclusions collects every pair covered by a not_duplicate decision.
+func NewExclusions(decisions []*models.ReviewDecision) Exclusions {
+	e := Exclusions{}
+	for _, d := range decisions {
+		if d.Decision != models.ReviewNotDuplicate {
+			continue
+		}
+		for i, a := range d.VideohashIDs {
+			for _, b := range d.VideohashIDs[i+1:] {
+				e[pairKey(a, b)] = struct{}{}
+			}
+		}
+	}
+	return e
+}
+
+// Contains reports whether a and b must not be treated as neighbours.
+func (e Exclusions) Contains(a, b int64) bool {
+	_, ok := e[pairKey(a, b)]
+	return ok
+}
+
+func pairKey(a, b int64) [2]int64 {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]int64{a, b}
+}
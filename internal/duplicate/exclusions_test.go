@@ -0,0 +1,62 @@
+package duplicate
+
+import (
+	"slices"
+	"testing"
+
+	"govdupes/internal/models"
+)
+
+func TestExclusions(t *testing.T) {
+	excluded := NewExclusions([]*models.ReviewDecision{
+		{Decision: models.ReviewNotDuplicate, VideohashIDs: []int64{3, 1, 2}},
+		{Decision: models.ReviewKeepAll, VideohashIDs: []int64{4, 5}},
+		{Decision: models.ReviewNotDuplicate, VideohashIDs: []int64{6}},
+	})
+
+	tests := []struct {
+		a, b int64
+		want bool
+	}{
+		{1, 2, true},
+		{2, 1, true},
+		{1, 3, true},
+		{3, 2, true},
+		// keep_all only hides the group, the pair still matches
+		{4, 5, false},
+		{1, 4, false},
+		{6, 6, false},
+	}
+	for _, tt := range tests {
+		if got := excluded.Contains(tt.a, tt.b); got != tt.want {
+			t.Errorf("Contains(%d, %d) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if len(excluded) != 3 {
+		t.Errorf("got %d excluded pairs, want 3", len(excluded))
+	}
+}
+
+func TestFindVideoDuplicatesExcluded(t *testing.T) {
+	hash := func(id int64) *models.Videohash {
+		return &models.Videohash{ID: id, HashType: models.HashTypePHash, HashValue: "p:c3a1f00e12345678", Duration: 60}
+	}
+	a, b, c := hash(1), hash(2), hash(3)
+	excluded := NewExclusions([]*models.ReviewDecision{
+		{Decision: models.ReviewNotDuplicate, VideohashIDs: []int64{1, 2}},
+	})
+	if _, err := FindVideoDuplicates([]*models.Videohash{a, b, c}, matchOptions(excluded, 1, MatchVideo)); err != nil {
+		t.Fatal(err)
+	}
+	// neighbours are positions in hashes
+	if slices.Contains(a.Neighbours, 1) || slices.Contains(b.Neighbours, 0) {
+		t.Errorf("excluded pair are neighbours: 1 has %v, 2 has %v", a.Neighbours, b.Neighbours)
+	}
+	if !slices.Contains(a.Neighbours, 2) || !slices.Contains(b.Neighbours, 2) {
+		t.Errorf("neighbours of 1 = %v and 2 = %v, want both to have 3", a.Neighbours, b.Neighbours)
+	}
+	// the third video still joins them in one bucket
+	if a.Bucket != c.Bucket || b.Bucket != c.Bucket {
+		t.Errorf("buckets = %d, %d, %d, want one", a.Bucket, b.Bucket, c.Bucket)
+	}
+}
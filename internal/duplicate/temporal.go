@@ -0,0 +1,299 @@
+package duplicate
+
+import (
+	"log/slog"
+	"math"
+	"math/bits"
+	"sort"
+
+	"govdupes/internal/models"
+)
+
+// TemporalOptions tunes the alignment of temporal hashes.
+type TemporalOptions struct {
+	// MaxFrameDistance is the largest number of differing bits for two frames to match.
+	MaxFrameDistance int
+	// MinMatchSeconds is the shortest aligned section that is reported.
+	MinMatchSeconds int
+	// MaxGap is how many non-matching frames in a row a section may contain.
+	MaxGap int
+	// MinSimilarity is the lowest fraction of matching frames in a section.
+	MinSimilarity float64
+	// Coverage is the fraction of a video a section must span to count as
+	// all of it.
+	Coverage float64
+	// MinSceneMatch is the lowest SceneSimilarity for two ScenePhash
+	// hashes, which are compared without their durations too.
+	MinSceneMatch float64
+}
+
+func DefaultTemporalOptions() TemporalOptions {
+	return TemporalOptions{
+		MaxFrameDistance: 10,
+		MinMatchSeconds:  10,
+		MaxGap:           3,
+		MinSimilarity:    0.6,
+		Coverage:         0.9,
+		MinSceneMatch:    0.6,
+	}
+}
+
+// speeds are the playback speed ratios tried, B position = speed * A position
+// + offset. They cover PAL/film conversions and common speed-ups.
+var speeds = []float64{1, 24.0 / 25, 25.0 / 24, 0.8, 1.25, 2.0 / 3, 1.5, 0.5, 2}
+
+const (
+	numBands = 4
+	// maxPostings drops band values shared by more frames than this, they
+	// come from black frames, logos and credits and only add noise.
+	maxPostings = 256
+	// candidateOffsets is how many of the most voted offsets are scanned.
+	candidateOffsets = 3
+)
+
+type posting struct {
+	video int32
+	frame int32
+}
+
+type hit struct {
+	i, j int32
+}
+
+func band(h uint64, b int) uint32 {
+	return uint32(b)<<16 | uint32(h>>(16*b)&0xffff)
+}
+
+// FindTemporalMatches aligns every pair of temporal hashes. Candidate frame
+// pairs are found through a band index (two frames within a few bits share at
+// least one 16 bit band), each pair of videos then votes on the time offset
+// per speed and the best offsets are scanned for the longest aligned section.
+func FindTemporalMatches(hashes []*models.Videohash, options TemporalOptions, excluded Exclusions) []*models.VideohashMatch {
+	seqs := make([][]uint64, len(hashes))
+	index := make(map[uint32][]posting)
+	for v, h := range hashes {
+		seqs[v] = h.Frames()
+		for f, frame := range seqs[v] {
+			if models.IsBlankFrame(frame) {
+				continue
+			}
+			for b := range numBands {
+				key := band(frame, b)
+				index[key] = append(index[key], posting{int32(v), int32(f)})
+			}
+		}
+	}
+
+	hits := make(map[[2]int][]hit)
+	for v, seq := range seqs {
+		for f, frame := range seq {
+			if models.IsBlankFrame(frame) {
+				continue
+			}
+			for b := range numBands {
+				postings := index[band(frame, b)]
+				if len(postings) > maxPostings {
+					continue
+				}
+				for _, p := range postings {
+					if int(p.video) <= v {
+						continue
+					}
+					other := seqs[p.video][p.frame]
+					// count each frame pair once, at its lowest shared band
+					if sharesLowerBand(frame, other, b) {
+						continue
+					}
+					if bits.OnesCount64(frame^other) > options.MaxFrameDistance {
+						continue
+					}
+					key := [2]int{v, int(p.video)}
+					hits[key] = append(hits[key], hit{int32(f), p.frame})
+				}
+			}
+		}
+	}
+
+	minHits := max(3, options.MinMatchSeconds/4)
+	var matches []*models.VideohashMatch
+	for key, pairHits := range hits {
+		a, b := hashes[key[0]], hashes[key[1]]
+		if len(pairHits) < minHits || a.ID == b.ID || excluded.Contains(a.ID, b.ID) {
+			continue
+		}
+		m := alignPair(seqs[key[0]], seqs[key[1]], pairHits, options)
+		if m == nil {
+			continue
+		}
+		m.FKMatchA, m.FKMatchB = a.ID, b.ID
+		classify(m, len(seqs[key[0]]), len(seqs[key[1]]), options)
+		slog.Info("Temporal match found",
+			slog.Int64("a", m.FKMatchA), slog.Int64("b", m.FKMatchB),
+			slog.String("type", string(m.MatchType)), slog.Float64("speed", m.Speed),
+			slog.Float64("similarity", m.Similarity))
+		matches = append(matches, m)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].FKMatchA != matches[j].FKMatchA {
+			return matches[i].FKMatchA < matches[j].FKMatchA
+		}
+		return matches[i].FKMatchB < matches[j].FKMatchB
+	})
+	return matches
+}
+
+func sharesLowerBand(x, y uint64, b int) bool {
+	for lower := range b {
+		if band(x, lower) == band(y, lower) {
+			return true
+		}
+	}
+	return false
+}
+
+// alignPair returns the longest aligned section of a and b over all speeds,
+// or nil when none is long and similar enough.
+func alignPair(a, b []uint64, hits []hit, options TemporalOptions) *models.VideohashMatch {
+	var best *run
+	var bestSpeed, bestOffset float64
+	for _, speed := range speeds {
+		for _, offset := range topOffsets(hits, speed) {
+			r := scanRun(a, b, speed, offset, options)
+			if r != nil && (best == nil || r.matched > best.matched) {
+				best, bestSpeed, bestOffset = r, speed, offset
+			}
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	return &models.VideohashMatch{
+		StartA:     float64(best.start) * models.TemporalSampleInterval,
+		EndA:       float64(best.end+1) * models.TemporalSampleInterval,
+		StartB:     (bestSpeed*float64(best.start) + bestOffset) * models.TemporalSampleInterval,
+		EndB:       (bestSpeed*float64(best.end+1) + bestOffset) * models.TemporalSampleInterval,
+		Speed:      bestSpeed,
+		Similarity: float64(best.matched) / float64(best.compared),
+	}
+}
+
+// topOffsets returns the offsets most voted for by hits at speed.
+func topOffsets(hits []hit, speed float64) []float64 {
+	votes := make(map[int]int)
+	for _, h := range hits {
+		votes[int(math.Round(float64(h.j)-speed*float64(h.i)))]++
+	}
+	offsets := make([]int, 0, len(votes))
+	for off, n := range votes {
+		if n >= 2 {
+			offsets = append(offsets, off)
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		if votes[offsets[i]] != votes[offsets[j]] {
+			return votes[offsets[i]] > votes[offsets[j]]
+		}
+		return offsets[i] < offsets[j]
+	})
+	result := make([]float64, 0, candidateOffsets)
+	for _, off := range offsets[:min(len(offsets), candidateOffsets)] {
+		result = append(result, float64(off))
+	}
+	return result
+}
+
+type run struct {
+	start, end int // frame positions in a, inclusive
+	matched    int
+	compared   int
+}
+
+// scanRun walks a, mapping each frame to speed*i+offset in b, and returns the
+// run with the most matched frames. Blank frames neither match nor break a run.
+func scanRun(a, b []uint64, speed, offset float64, options TemporalOptions) *run {
+	var best, cur *run
+	misses := 0
+	closeRun := func() {
+		if cur != nil && cur.matched > 0 && (best == nil || cur.matched > best.matched) {
+			best = cur
+		}
+		cur = nil
+		misses = 0
+	}
+
+	for i, frame := range a {
+		j := int(math.Round(speed*float64(i) + offset))
+		if j < 0 || j >= len(b) {
+			closeRun()
+			continue
+		}
+		if models.IsBlankFrame(frame) || models.IsBlankFrame(b[j]) {
+			continue
+		}
+
+		if frameMatches(frame, b, j, options.MaxFrameDistance) {
+			if cur == nil {
+				cur = &run{start: i}
+			}
+			cur.compared += misses + 1
+			cur.matched++
+			cur.end = i
+			misses = 0
+			continue
+		}
+
+		if cur != nil {
+			misses++
+			if misses > options.MaxGap {
+				closeRun()
+			}
+		}
+	}
+	closeRun()
+
+	if best == nil {
+		return nil
+	}
+	length := float64(best.end-best.start+1) * models.TemporalSampleInterval
+	if length < float64(options.MinMatchSeconds) ||
+		float64(best.matched)/float64(best.compared) < options.MinSimilarity {
+		return nil
+	}
+	return best
+}
+
+// frameMatches allows one frame of slack either side of j for rounding.
+func frameMatches(frame uint64, b []uint64, j, maxDistance int) bool {
+	for k := max(0, j-1); k <= min(len(b)-1, j+1); k++ {
+		if bits.OnesCount64(frame^b[k]) <= maxDistance {
+			return true
+		}
+	}
+	return false
+}
+
+// classify sets the match type from how much of each video the section
+// spans and swaps A and B so that A is the contained video.
+func classify(m *models.VideohashMatch, framesA, framesB int, options TemporalOptions) {
+	durationA := float64(framesA) * models.TemporalSampleInterval
+	durationB := float64(framesB) * models.TemporalSampleInterval
+	coversA := (m.EndA - m.StartA) >= options.Coverage*durationA
+	coversB := (m.EndB - m.StartB) >= options.Coverage*durationB
+
+	switch {
+	case coversA && coversB:
+		m.MatchType = models.MatchDuplicate
+	case coversA:
+		m.MatchType = models.MatchContained
+	case coversB:
+		m.MatchType = models.MatchContained
+		m.FKMatchA, m.FKMatchB = m.FKMatchB, m.FKMatchA
+		m.StartA, m.StartB = m.StartB, m.StartA
+		m.EndA, m.EndB = m.EndB, m.EndA
+		m.Speed = 1 / m.Speed
+	default:
+		m.MatchType = models.MatchOverlap
+	}
+}
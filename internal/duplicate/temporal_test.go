@@ -0,0 +1,100 @@
+package duplicate
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"govdupes/internal/models"
+)
+
+func randomFrames(r *rand.Rand, n int) []uint64 {
+	frames := make([]uint64, n)
+	for i := range frames {
+		frames[i] = r.Uint64()
+	}
+	return frames
+}
+
+// noisy flips a couple of bits per frame, like a re-encode would.
+func noisy(r *rand.Rand, frames []uint64) []uint64 {
+	out := make([]uint64, len(frames))
+	for i, f := range frames {
+		out[i] = f ^ 1<<r.Intn(64) ^ 1<<r.Intn(64)
+	}
+	return out
+}
+
+func temporalHash(id int64, frames []uint64) *models.Videohash {
+	return &models.Videohash{ID: id, HashType: models.HashTypeTemporal, FrameHashes: frames}
+}
+
+func TestFindTemporalMatches(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	source := randomFrames(r, 600)
+
+	// every 1.25 seconds of the source make one second of the sped up copy
+	var fast []uint64
+	for i := 0; int(math.Round(float64(i)*1.25)) < 400; i++ {
+		fast = append(fast, source[int(math.Round(float64(i)*1.25))])
+	}
+
+	tests := []struct {
+		name      string
+		other     []uint64
+		wantType  models.MatchType
+		wantA     int64
+		wantStart float64 // in the source
+		wantEnd   float64
+		wantSpeed float64
+	}{
+		{"clip", noisy(r, source[120:300]), models.MatchContained, 2, 120, 300, 1},
+		{"trimmed intro", noisy(r, source[30:]), models.MatchDuplicate, 1, 30, 600, 1},
+		{"long intro cut", noisy(r, source[150:]), models.MatchContained, 2, 150, 600, 1},
+		{"re-encode", noisy(r, source), models.MatchDuplicate, 1, 0, 600, 1},
+		{"sped up clip", noisy(r, fast), models.MatchContained, 2, 0, 400, 1.25},
+		{"shared middle", append(randomFrames(r, 100), source[500:]...), models.MatchOverlap, 1, 500, 600, 1},
+		{"unrelated", randomFrames(r, 300), "", 0, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		hashes := []*models.Videohash{temporalHash(1, source), temporalHash(2, tt.other)}
+		matches := FindTemporalMatches(hashes, DefaultTemporalOptions(), nil)
+		if tt.wantType == "" {
+			if len(matches) != 0 {
+				t.Errorf("%s: got %d matches, want none", tt.name, len(matches))
+			}
+			continue
+		}
+		if len(matches) != 1 {
+			t.Errorf("%s: got %d matches, want 1", tt.name, len(matches))
+			continue
+		}
+		m := matches[0]
+		if m.MatchType != tt.wantType || m.FKMatchA != tt.wantA {
+			t.Errorf("%s: got %s with A=%d, want %s with A=%d", tt.name, m.MatchType, m.FKMatchA, tt.wantType, tt.wantA)
+		}
+		start, end := m.StartA, m.EndA
+		if m.FKMatchB == 1 {
+			start, end = m.StartB, m.EndB
+		}
+		if math.Abs(start-tt.wantStart) > 2 || math.Abs(end-tt.wantEnd) > 2 {
+			t.Errorf("%s: source section %.0f–%.0f, want %.0f–%.0f", tt.name, start, end, tt.wantStart, tt.wantEnd)
+		}
+		if math.Abs(m.Speed-tt.wantSpeed) > 0.01 {
+			t.Errorf("%s: speed %.2f, want %.2f", tt.name, m.Speed, tt.wantSpeed)
+		}
+	}
+}
+
+func TestFindTemporalMatchesExcluded(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	source := randomFrames(r, 200)
+	hashes := []*models.Videohash{temporalHash(1, source), temporalHash(2, noisy(r, source))}
+	excluded := NewExclusions([]*models.ReviewDecision{
+		{Decision: models.ReviewNotDuplicate, VideohashIDs: []int64{2, 1}},
+	})
+	if matches := FindTemporalMatches(hashes, DefaultTemporalOptions(), excluded); len(matches) != 0 {
+		t.Errorf("got %d matches for an excluded pair, want none", len(matches))
+	}
+}
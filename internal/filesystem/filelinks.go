@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sync"
 	"syscall"
 
 	"govdupes/internal/config"
@@ -20,7 +21,10 @@ type FileIdentity struct {
 	Device         uint64
 }
 
+// FileTracker remembers the files it has seen to spot hard links. It is
+// safe for concurrent use.
 type FileTracker struct {
+	mu   sync.Mutex
 	seen map[FileIdentity]struct{}
 }
 
@@ -38,6 +42,8 @@ func (ft *FileTracker) CheckHardLink(path string, info fs.FileInfo) (*FileIdenti
 
 	fileID := FileIdentity{NumHardLinks: stat.Nlink, Inode: stat.Ino, Device: stat.Dev}
 
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
 	if _, exists := ft.seen[fileID]; exists {
 		fileID.IsHardLink = true
 		return &fileID, nil
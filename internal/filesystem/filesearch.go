@@ -11,31 +11,12 @@ import (
 	"govdupes/internal/models"
 )
 
-var (
-	fileCount     int
-	acceptedFiles int
-)
-
-func SearchDirs(c *config.Config, onFileFound func(int), onFileAccepted func(int)) []*models.Video {
-	slog.Info("Searching directories")
-	fileCount = 0
-	acceptedFiles = 0
+// SearchDirs walks the starting dirs for videos, see WalkDirs, and returns
+// them all once the walk is done.
+func SearchDirs(c *config.Config, onFileFound func(int), onFileAccepted func(int), onError func(v *models.Video, err error)) []*models.Video {
 	videos := make([]*models.Video, 0)
-
-	for _, dir := range c.StartingDirs {
-		dir = filepath.Clean(strings.TrimSuffix(dir, "/"))
-		slog.Info("Searching recursively", slog.String("starting_dir", dir))
-		info, err := os.Stat(dir)
-		if err != nil {
-			slog.Error("Error accessing directory", slog.String("dir", dir), slog.Any("error", err))
-			continue
-		}
-		if !info.IsDir() {
-			slog.Warn("Skipping because it's not a directory", slog.String("dir", dir))
-			continue
-		}
-		fileSystem := os.DirFS(dir)
-		videos = append(videos, getVideosFromFS(fileSystem, c, dir, onFileFound, onFileAccepted)...)
+	for v := range WalkDirs(c, onFileFound, onFileAccepted, onError) {
+		videos = append(videos, v)
 	}
 
 	if len(videos) == 0 {
@@ -45,79 +26,79 @@ func SearchDirs(c *config.Config, onFileFound func(int), onFileAccepted func(int
 	return videos
 }
 
-// check if file ext is in ignoreext, if so ignore
-// check if file ext is in includeext, if so consider the file
-// check if file name is in ignorestr, if so ignore
-// check if filename is in includestr, if so include consider the file
-// if both includeext/includestr agree then include the file
-func getVideosFromFS(fileSystem fs.FS, c *config.Config, root string, onFileFound func(int), onFileAccepted func(int)) []*models.Video {
-	slog.Info("Processing root directory", slog.String("root", root))
-	videos := make([]*models.Video, 0)
-	fileTracker := NewFileTracker()
-
-	walkDirErr := fs.WalkDir(
-		fileSystem,
-		".",
-		func(path string, d fs.DirEntry, err error) error {
-			fileCount++
-			onFileFound(fileCount)
-
-			slog.Info("Processing file", "file", path)
-			if err != nil {
-				slog.Error("Error walking through filesystem", slog.Any("error", err))
-				return err
-			}
-			if d.IsDir() {
-				return nil
-			}
-			if !validExt(path, c) || !validFileName(d, c) {
-				return nil
-			}
-
-			path = filepath.Join(root, path)
+// failedFile is the video onError gets for path: only the path, plus the
+// size and modification time when info is known, so a recorded error can
+// tell whether the file changed since.
+func failedFile(path string, info fs.FileInfo) *models.Video {
+	v := &models.Video{Path: path}
+	if info != nil {
+		v.Size, v.ModifiedAt = info.Size(), info.ModTime()
+	}
+	return v
+}
 
-			fileInfo, err := d.Info()
-			if err != nil {
-				slog.Error("Error getting file info", slog.String("path", path), slog.Any("error", err))
-				return nil
-			}
+// acceptFile returns the video at path, which the walk found as d, or nil
+// when the config leaves it out.
+func acceptFile(c *config.Config, fileTracker *FileTracker, path string, d fs.DirEntry, onError func(v *models.Video, err error)) *models.Video {
+	if !validExt(path, c) || !validFileName(d, c) {
+		return nil
+	}
 
-			if fileInfo.Size() < c.FilesizeCutoff {
-				slog.Info("Skipping file due to size cutoff",
-					slog.String("path", path),
-					slog.Int64("size", fileInfo.Size()),
-					slog.Int64("cutoff", c.FilesizeCutoff))
-				return nil
-			}
+	fileInfo, err := d.Info()
+	if err != nil {
+		slog.Error("Error getting file info", slog.String("path", path), slog.Any("error", err))
+		onError(failedFile(path, nil), err)
+		return nil
+	}
 
-			fileID, err := fileTracker.FindFileLinks(path, *c)
-			if err != nil {
-				slog.Error("Error detecting symbolic/hard link", slog.String("path", path), slog.Any("error", err))
-				return nil
-			}
-			if fileID.IsSymbolicLink && c.SkipSymbolicLinks {
-				slog.Info("Skipping symbolic link", slog.String("path", path))
-				return nil
-			}
+	if fileInfo.Size() < c.FilesizeCutoff {
+		slog.Info("Skipping file due to size cutoff",
+			slog.String("path", path),
+			slog.Int64("size", fileInfo.Size()),
+			slog.Int64("cutoff", c.FilesizeCutoff))
+		return nil
+	}
 
-			if !checkValidVideo(path, fileInfo) {
-				slog.Warn("Invalid video file", slog.String("path", path))
-				return nil
-			}
+	fileID, err := fileTracker.FindFileLinks(path, *c)
+	if err != nil {
+		slog.Error("Error detecting symbolic/hard link", slog.String("path", path), slog.Any("error", err))
+		onError(failedFile(path, fileInfo), err)
+		return nil
+	}
+	if fileID.IsSymbolicLink && c.SkipSymbolicLinks {
+		slog.Info("Skipping symbolic link", slog.String("path", path))
+		return nil
+	}
 
-			video := CreateVideo(path, fileInfo, *fileID)
-			videos = append(videos, &video)
-			acceptedFiles++
-			onFileAccepted(acceptedFiles)
+	if !checkValidVideo(path, fileInfo) {
+		slog.Warn("Invalid video file", slog.String("path", path))
+		return nil
+	}
 
-			return nil
-		},
-	)
+	video := CreateVideo(path, fileInfo, *fileID)
+	return &video
+}
 
-	if walkDirErr != nil {
-		slog.Error("Error walking through directories", slog.Any("error", walkDirErr))
+// LoadVideos stats paths found by an earlier walk again, to resume a scan
+// without walking the dirs. Paths that are gone or unreadable are passed to
+// onError, see failedFile.
+func LoadVideos(c *config.Config, paths []string, onError func(v *models.Video, err error)) []*models.Video {
+	videos := make([]*models.Video, 0, len(paths))
+	fileTracker := NewFileTracker()
+	for _, path := range paths {
+		fileInfo, err := os.Lstat(path)
+		if err != nil {
+			onError(failedFile(path, nil), err)
+			continue
+		}
+		fileID, err := fileTracker.FindFileLinks(path, *c)
+		if err != nil {
+			onError(failedFile(path, fileInfo), err)
+			continue
+		}
+		video := CreateVideo(path, fileInfo, *fileID)
+		videos = append(videos, &video)
 	}
-	slog.Info("Finished searching directories")
 	return videos
 }
 
@@ -133,6 +114,9 @@ func CreateVideo(path string, fileInfo os.FileInfo, fileID FileIdentity) models.
 		IsHardLink:     fileID.IsHardLink,
 		Inode:          fileID.Inode,
 		Device:         fileID.Device,
+		// unknown until probed
+		VideoStreamIndex: -1,
+		AudioStreamIndex: -1,
 	}
 	return video
 }
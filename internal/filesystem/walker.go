@@ -0,0 +1,160 @@
+package filesystem
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"govdupes/internal/config"
+	"govdupes/internal/models"
+)
+
+// walkBuffer is how many found videos wait for the next stage before the
+// walk pauses.
+const walkBuffer = 256
+
+// WalkDirs walks the starting dirs with up to c.WalkWorkers directories
+// read at once and sends the videos it accepts on the returned channel,
+// which is closed when the walk is done. Paths that can't be read are
+// passed to onError, see failedFile, and the walk carries on without them.
+// Their paths are absolute like the starting dirs. The callbacks
+// are called from several goroutines and get running totals.
+func WalkDirs(c *config.Config, onFileFound func(int), onFileAccepted func(int), onError func(v *models.Video, err error)) <-chan *models.Video {
+	out := make(chan *models.Video, walkBuffer)
+	w := &walker{
+		c:              c,
+		fileTracker:    NewFileTracker(),
+		out:            out,
+		onFileFound:    onFileFound,
+		onFileAccepted: onFileAccepted,
+		onError:        onError,
+	}
+	w.cond = sync.NewCond(&w.mu)
+
+	slog.Info("Searching directories")
+	for _, dir := range c.StartingDirs {
+		dir = filepath.Clean(strings.TrimSuffix(dir, "/"))
+		info, err := os.Stat(dir)
+		if err != nil {
+			slog.Error("Error accessing directory", slog.String("dir", dir), slog.Any("error", err))
+			onError(failedFile(dir, nil), err)
+			continue
+		}
+		if !info.IsDir() {
+			slog.Warn("Skipping because it's not a directory", slog.String("dir", dir))
+			continue
+		}
+		slog.Info("Searching recursively", slog.String("starting_dir", dir))
+		w.push(dir)
+	}
+
+	workers := c.WalkWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				dir, ok := w.pop()
+				if !ok {
+					return
+				}
+				w.readDir(dir)
+				w.doneDir()
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+		slog.Info("Finished searching directories",
+			slog.Int64("files", w.found.Load()),
+			slog.Int64("videos", w.accepted.Load()))
+	}()
+	return out
+}
+
+// walker shares a queue of directories between the workers. A directory
+// stays pending until it has been read, so the walk ends when the queue is
+// empty and nothing is pending.
+type walker struct {
+	c           *config.Config
+	fileTracker *FileTracker
+	out         chan<- *models.Video
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []string
+	pending int
+
+	found, accepted atomic.Int64
+	onFileFound     func(int)
+	onFileAccepted  func(int)
+	onError         func(v *models.Video, err error)
+}
+
+func (w *walker) push(dir string) {
+	w.mu.Lock()
+	w.queue = append(w.queue, dir)
+	w.pending++
+	w.mu.Unlock()
+	w.cond.Signal()
+}
+
+// pop waits for a directory, false once the walk is done.
+func (w *walker) pop() (string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.queue) == 0 && w.pending > 0 {
+		w.cond.Wait()
+	}
+	if len(w.queue) == 0 {
+		return "", false
+	}
+	// last in first out keeps the queue as short as a depth-first walk
+	dir := w.queue[len(w.queue)-1]
+	w.queue = w.queue[:len(w.queue)-1]
+	return dir, true
+}
+
+func (w *walker) doneDir() {
+	w.mu.Lock()
+	w.pending--
+	done := w.pending == 0
+	w.mu.Unlock()
+	if done {
+		w.cond.Broadcast()
+	}
+}
+
+func (w *walker) readDir(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		// skip the unreadable dir instead of ending the walk
+		slog.Error("Error walking through filesystem", slog.String("dir", dir), slog.Any("error", err))
+		w.onError(failedFile(dir, nil), err)
+		return
+	}
+
+	for _, d := range entries {
+		w.onFileFound(int(w.found.Add(1)))
+		path := filepath.Join(dir, d.Name())
+		if d.IsDir() {
+			w.push(path)
+			continue
+		}
+		slog.Debug("Processing file", "file", path)
+		video := acceptFile(w.c, w.fileTracker, path, d, w.onError)
+		if video == nil {
+			continue
+		}
+		w.onFileAccepted(int(w.accepted.Add(1)))
+		w.out <- video
+	}
+}
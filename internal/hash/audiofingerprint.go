@@ -0,0 +1,133 @@
+package hash
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/cmplx"
+
+	"govdupes/internal/models"
+	"govdupes/internal/videoprocessor"
+)
+
+// The fingerprint follows Haitsma and Kalker: overlapping frames of mono
+// audio are split into 33 logarithmic bands between 300 and 2000 Hz and each
+// of the 32 bits is the sign of the energy difference between neighbouring
+// bands, taken relative to the previous frame. That keeps it stable under
+// re-encoding, volume changes and equalisation.
+const (
+	AudioSampleRate = 5512
+	audioFrameSize  = 2048
+	// audioHop gives about 10.8 sub-fingerprints per second
+	audioHop        = 512
+	audioBands      = 33
+	audioMinFreq    = 300.0
+	audioMaxFreq    = 2000.0
+	audioMaxSeconds = 120
+)
+
+// AudioFramesPerSecond is the number of sub-fingerprints per second of audio.
+const AudioFramesPerSecond = float64(AudioSampleRate) / audioHop
+
+// CreateAudioFingerprint fingerprints the first two minutes of the audio of
+// v. Videos without audio get no fingerprint and no error.
+func CreateAudioFingerprint(ctx context.Context, vp *videoprocessor.FFmpegWrapper, v *models.Video) (models.AudioFingerprint, error) {
+	samples, err := vp.AudioPCM(ctx, v, AudioSampleRate, audioMaxSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("decoding audio of %s: %w", v.Path, err)
+	}
+	fp := AudioFingerprint(samples)
+	if len(fp) == 0 {
+		slog.Debug("No audio to fingerprint", slog.String("path", v.Path))
+		return nil, nil
+	}
+	slog.Info("File has audio fingerprint", slog.String("file", v.FileName), slog.Int("length", len(fp)))
+	return fp, nil
+}
+
+// AudioFingerprint computes the sub-fingerprints of mono samples at
+// AudioSampleRate. Silence gives zero sub-fingerprints.
+func AudioFingerprint(samples []int16) models.AudioFingerprint {
+	if len(samples) < audioFrameSize {
+		return nil
+	}
+
+	window := make([]float64, audioFrameSize)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(audioFrameSize-1))
+	}
+	edges := bandEdges()
+
+	numFrames := (len(samples)-audioFrameSize)/audioHop + 1
+	fp := make(models.AudioFingerprint, 0, numFrames-1)
+	buf := make([]complex128, audioFrameSize)
+	prev := make([]float64, audioBands)
+	cur := make([]float64, audioBands)
+	for n := range numFrames {
+		frame := samples[n*audioHop : n*audioHop+audioFrameSize]
+		for i, s := range frame {
+			buf[i] = complex(float64(s)*window[i], 0)
+		}
+		fft(buf)
+
+		for b := range audioBands {
+			energy := 0.0
+			for k := edges[b]; k < edges[b+1]; k++ {
+				re, im := real(buf[k]), imag(buf[k])
+				energy += re*re + im*im
+			}
+			cur[b] = energy
+		}
+
+		if n > 0 {
+			var sub uint32
+			for m := range audioBands - 1 {
+				if (cur[m]-cur[m+1])-(prev[m]-prev[m+1]) > 0 {
+					sub |= 1 << m
+				}
+			}
+			fp = append(fp, sub)
+		}
+		prev, cur = cur, prev
+	}
+	return fp
+}
+
+// bandEdges returns the FFT bin where each band starts, the last entry ends
+// the last band.
+func bandEdges() []int {
+	edges := make([]int, audioBands+1)
+	binWidth := float64(AudioSampleRate) / audioFrameSize
+	for b := range edges {
+		freq := audioMinFreq * math.Pow(audioMaxFreq/audioMinFreq, float64(b)/audioBands)
+		edges[b] = int(math.Round(freq / binWidth))
+	}
+	return edges
+}
+
+// fft is an in-place iterative radix-2 FFT, len(x) must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := range size / 2 {
+				a, b := x[start+k], x[start+k+size/2]*w
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package hash
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+	"testing"
+)
+
+// tones makes a melody of random tones, a new one every quarter second.
+func tones(r *rand.Rand, seconds int) []float64 {
+	out := make([]float64, seconds*AudioSampleRate)
+	freq := 0.0
+	for i := range out {
+		if i%(AudioSampleRate/4) == 0 {
+			freq = 300 + r.Float64()*1700
+		}
+		t := float64(i) / AudioSampleRate
+		out[i] = math.Sin(2*math.Pi*freq*t) + 0.5*math.Sin(2*math.Pi*freq*1.5*t)
+	}
+	return out
+}
+
+func pcm(signal []float64, gain float64, r *rand.Rand, noise float64) []int16 {
+	out := make([]int16, len(signal))
+	for i, s := range signal {
+		v := s*gain*8000 + r.NormFloat64()*noise
+		out[i] = int16(max(math.MinInt16, min(math.MaxInt16, v)))
+	}
+	return out
+}
+
+func bitErrorRate(a, b []uint32) float64 {
+	errors := 0
+	for i := range min(len(a), len(b)) {
+		errors += bits.OnesCount32(a[i] ^ b[i])
+	}
+	return float64(errors) / float64(32*min(len(a), len(b)))
+}
+
+func TestAudioFingerprint(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	signal := tones(r, 30)
+	original := AudioFingerprint(pcm(signal, 1, r, 0))
+	if want := 30*AudioSampleRate/audioHop - 4; len(original) < want {
+		t.Fatalf("got %d sub-fingerprints, want at least %d", len(original), want)
+	}
+
+	// audio matches at a similarity of 0.65, a bit error rate of 0.35
+	quieter := AudioFingerprint(pcm(signal, 0.4, r, 200))
+	if ber := bitErrorRate(original, quieter); ber > 0.3 {
+		t.Errorf("quieter noisy copy: bit error rate %.2f, want <= 0.30", ber)
+	}
+
+	other := AudioFingerprint(pcm(tones(r, 30), 1, r, 0))
+	if ber := bitErrorRate(original, other); ber < 0.4 {
+		t.Errorf("different audio: bit error rate %.2f, want >= 0.40", ber)
+	}
+
+	for _, sub := range AudioFingerprint(make([]int16, 5*AudioSampleRate)) {
+		if sub != 0 {
+			t.Fatalf("silence gave sub-fingerprint %032b, want 0", sub)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package hash
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// minFrameStdDev is the lowest standard deviation of the luma, out of
+	// 255, for a frame to be worth hashing. Solid colours, fades and the
+	// noise of a black frame stay below it.
+	minFrameStdDev = 6.0
+	// minFrameEntropy is the lowest entropy of the luma histogram in bits.
+	// It catches frames of two flat colours, like a title card.
+	minFrameEntropy = 1.0
+)
+
+// IsLowInformation reports whether img is too plain for its hash to tell
+// videos apart.
+func IsLowInformation(img image.Image) bool {
+	if img == nil {
+		return true
+	}
+	var histogram [256]int
+	n := 0
+	switch m := img.(type) {
+	case *image.Gray:
+		for _, p := range m.Pix {
+			histogram[p]++
+		}
+		n = len(m.Pix)
+	case *image.RGBA:
+		for i := 0; i+2 < len(m.Pix); i += 4 {
+			histogram[luma(m.Pix[i], m.Pix[i+1], m.Pix[i+2])]++
+		}
+		n = len(m.Pix) / 4
+	default:
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, bl, _ := img.At(x, y).RGBA()
+				histogram[luma(uint8(r>>8), uint8(g>>8), uint8(bl>>8))]++
+			}
+		}
+		n = b.Dx() * b.Dy()
+	}
+	if n == 0 {
+		return true
+	}
+
+	var mean, entropy float64
+	for v, count := range histogram {
+		mean += float64(v * count)
+	}
+	mean /= float64(n)
+	var variance float64
+	for v, count := range histogram {
+		if count == 0 {
+			continue
+		}
+		d := float64(v) - mean
+		variance += d * d * float64(count)
+		p := float64(count) / float64(n)
+		entropy -= p * math.Log2(p)
+	}
+	variance /= float64(n)
+	return math.Sqrt(variance) < minFrameStdDev || entropy < minFrameEntropy
+}
+
+func luma(r, g, b uint8) uint8 {
+	return uint8((299*int(r) + 587*int(g) + 114*int(b)) / 1000)
+}
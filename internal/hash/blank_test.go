@@ -0,0 +1,49 @@
+package hash
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func TestIsLowInformation(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	fill := func(f func(x, y int) uint8) *image.Gray {
+		img := image.NewGray(image.Rect(0, 0, 64, 64))
+		for y := range 64 {
+			for x := range 64 {
+				img.SetGray(x, y, color.Gray{Y: f(x, y)})
+			}
+		}
+		return img
+	}
+
+	grey := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for i := range grey.Pix {
+		grey.Pix[i] = 128
+	}
+
+	tests := []struct {
+		name string
+		img  image.Image
+		want bool
+	}{
+		{"black", fill(func(x, y int) uint8 { return 0 }), true},
+		{"noisy black", fill(func(x, y int) uint8 { return uint8(r.Intn(8)) }), true},
+		{"grey", grey, true},
+		{"title card", fill(func(x, y int) uint8 {
+			if x > 28 && x < 36 && y > 30 && y < 34 {
+				return 255
+			}
+			return 0
+		}), true},
+		{"gradient", fill(func(x, y int) uint8 { return uint8(x*2 + y) }), false},
+		{"scene", fill(func(x, y int) uint8 { return uint8(r.Intn(256)) }), false},
+	}
+	for _, tt := range tests {
+		if got := IsLowInformation(tt.img); got != tt.want {
+			t.Errorf("%s: IsLowInformation() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
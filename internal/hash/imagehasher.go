@@ -0,0 +1,186 @@
+package hash
+
+import (
+	"fmt"
+	"image"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/corona10/goimagehash"
+	xdraw "golang.org/x/image/draw"
+)
+
+// ImageHasher turns an image into a perceptual hash made of 64-bit words.
+type ImageHasher interface {
+	// Name identifies the algorithm in the config and the database.
+	Name() string
+	// Words is the number of 64-bit words Hash returns.
+	Words() int
+	Hash(img image.Image) ([]uint64, error)
+}
+
+// PrimaryHasher is the algorithm stored in the videohash itself, every other
+// configured algorithm is stored as an extra signature.
+const PrimaryHasher = "phash"
+
+var imageHashers = map[string]ImageHasher{
+	"phash":    pHasher{},
+	"dhash":    dHasher{},
+	"ahash":    aHasher{},
+	"whash":    wHasher{},
+	"extphash": extPHasher{},
+}
+
+// HasherNames lists the available algorithms.
+func HasherNames() []string {
+	names := make([]string, 0, len(imageHashers))
+	for name := range imageHashers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExtraHashers returns the hashers for names, leaving out the primary one.
+func ExtraHashers(names []string) ([]ImageHasher, error) {
+	var hashers []ImageHasher
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == PrimaryHasher || name == "" {
+			continue
+		}
+		h, ok := imageHashers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown hash algorithm %q, expected one of %s", name, strings.Join(HasherNames(), ", "))
+		}
+		if !slices.Contains(hashers, h) {
+			hashers = append(hashers, h)
+		}
+	}
+	return hashers, nil
+}
+
+type pHasher struct{}
+
+func (pHasher) Name() string { return "phash" }
+func (pHasher) Words() int   { return 1 }
+func (pHasher) Hash(img image.Image) ([]uint64, error) {
+	h, err := goimagehash.PerceptionHash(img)
+	if err != nil {
+		return nil, err
+	}
+	return []uint64{h.GetHash()}, nil
+}
+
+type dHasher struct{}
+
+func (dHasher) Name() string { return "dhash" }
+func (dHasher) Words() int   { return 1 }
+func (dHasher) Hash(img image.Image) ([]uint64, error) {
+	h, err := goimagehash.DifferenceHash(img)
+	if err != nil {
+		return nil, err
+	}
+	return []uint64{h.GetHash()}, nil
+}
+
+type aHasher struct{}
+
+func (aHasher) Name() string { return "ahash" }
+func (aHasher) Words() int   { return 1 }
+func (aHasher) Hash(img image.Image) ([]uint64, error) {
+	h, err := goimagehash.AverageHash(img)
+	if err != nil {
+		return nil, err
+	}
+	return []uint64{h.GetHash()}, nil
+}
+
+// extPHasher is a 256 bit pHash, more detail at the cost of size.
+type extPHasher struct{}
+
+func (extPHasher) Name() string { return "extphash" }
+func (extPHasher) Words() int   { return 4 }
+func (extPHasher) Hash(img image.Image) ([]uint64, error) {
+	h, err := goimagehash.ExtPerceptionHash(img, 16, 16)
+	if err != nil {
+		return nil, err
+	}
+	return h.GetHash(), nil
+}
+
+// wHasher is a Haar wavelet hash: the image is scaled to 64x64 gray,
+// decomposed three levels down to the 8x8 low-frequency band and each
+// coefficient is compared with the median. Removing the DC component as
+// some implementations do doesn't change a median comparison, so it's skipped.
+type wHasher struct{}
+
+const (
+	wHashImageSize = 64
+	wHashSize      = 8
+)
+
+func (wHasher) Name() string { return "whash" }
+func (wHasher) Words() int   { return 1 }
+func (wHasher) Hash(img image.Image) ([]uint64, error) {
+	if img == nil {
+		return nil, fmt.Errorf("image is nil")
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, wHashImageSize, wHashImageSize))
+	xdraw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+
+	pixels := make([]float64, wHashImageSize*wHashImageSize)
+	for i := range pixels {
+		p := scaled.Pix[i*4 : i*4+3]
+		pixels[i] = 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+	}
+
+	for n := wHashImageSize; n > wHashSize; n /= 2 {
+		haarStep(pixels, wHashImageSize, n)
+	}
+
+	ll := make([]float64, 0, wHashSize*wHashSize)
+	for y := range wHashSize {
+		ll = append(ll, pixels[y*wHashImageSize:y*wHashImageSize+wHashSize]...)
+	}
+	sorted := slices.Clone(ll)
+	slices.Sort(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var h uint64
+	for i, v := range ll {
+		if v > median {
+			h |= 1 << (len(ll) - 1 - i)
+		}
+	}
+	return []uint64{h}, nil
+}
+
+// haarStep does one level of the 2D Haar transform on the top left n x n
+// block of a stride x stride matrix, leaving the averages in the top left
+// n/2 x n/2 block and the details around it.
+func haarStep(m []float64, stride, n int) {
+	tmp := make([]float64, n)
+	half := n / 2
+	for y := range n {
+		row := m[y*stride : y*stride+n]
+		for x := range half {
+			a, b := row[2*x], row[2*x+1]
+			tmp[x] = (a + b) / 2
+			tmp[half+x] = (a - b) / 2
+		}
+		copy(row, tmp)
+	}
+	for x := range n {
+		for y := range half {
+			a, b := m[2*y*stride+x], m[(2*y+1)*stride+x]
+			tmp[y] = (a + b) / 2
+			tmp[half+y] = (a - b) / 2
+		}
+		for y := range n {
+			m[y*stride+x] = tmp[y]
+		}
+	}
+}
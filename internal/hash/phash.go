@@ -2,13 +2,13 @@ package hash
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"image"
 	"image/draw"
 	"log/slog"
 	"math"
-	"strings"
 
 	"govdupes/internal/models"
 	"govdupes/internal/videoprocessor"
@@ -17,20 +17,38 @@ import (
 	"golang.org/x/image/bmp"
 )
 
-func Create(vp *videoprocessor.FFmpegWrapper, v *models.Video, method string) (*models.Videohash, *models.Screenshots, error) {
+// Options are the hashes computed next to the pHash.
+type Options struct {
+	// Extra are the hashers stored as signatures
+	Extra []ImageHasher
+	// Transforms also stores the pHash of flipped and rotated frames
+	Transforms bool
+	// SceneThreshold is the scene score that starts a new scene for
+	// ScenePhash, DefaultSceneThreshold when 0
+	SceneThreshold float64
+}
+
+// Create hashes v with the detection method. FastPhash and SlowPhash also
+// store the signatures in opts, TemporalPhash only uses the pHash for
+// alignment.
+func Create(ctx context.Context, vp *videoprocessor.FFmpegWrapper, v *models.Video, method string, opts Options) (*models.Videohash, *models.Screenshots, error) {
 	switch method {
 	case "SlowPhash":
-		return createSlowPhash(vp, v)
+		return createSlowPhash(ctx, vp, v, opts)
 	case "FastPhash":
-		return createFastPhash(vp, v)
+		return createFastPhash(ctx, vp, v, opts)
+	case "TemporalPhash":
+		return createTemporalPhash(ctx, vp, v)
+	case "ScenePhash":
+		return createScenePhash(ctx, vp, v, opts)
 	default:
 		return nil, nil, fmt.Errorf("unknown detection method: %s", method)
 	}
 }
 
-func createFastPhash(vp *videoprocessor.FFmpegWrapper, v *models.Video) (*models.Videohash, *models.Screenshots, error) {
+func createFastPhash(ctx context.Context, vp *videoprocessor.FFmpegWrapper, v *models.Video, opts Options) (*models.Videohash, *models.Screenshots, error) {
 	timestamps := createTimeStamps(v.Duration, models.NumImages)
-	images, err := createScreenshots(vp, timestamps, v)
+	images, err := createScreenshots(ctx, vp, timestamps, v)
 	if err != nil {
 		slog.Error("Error creating screenshots", slog.Any("error", err))
 		return nil, nil, err
@@ -38,12 +56,12 @@ func createFastPhash(vp *videoprocessor.FFmpegWrapper, v *models.Video) (*models
 
 	screenshots := &models.Screenshots{Screenshots: images}
 
-	image, err := createCollage(images)
+	collage, err := createCollage(images)
 	if err != nil {
 		slog.Error("Error creating collage", slog.Any("error", err))
 	}
 
-	hash, err := goimagehash.PerceptionHash(image)
+	hash, err := goimagehash.PerceptionHash(collage)
 	if err != nil {
 		slog.Error("Error creating phash", slog.Any("error", err))
 	}
@@ -51,82 +69,196 @@ func createFastPhash(vp *videoprocessor.FFmpegWrapper, v *models.Video) (*models
 	slog.Info("File has pHash", slog.String("file", v.FileName), slog.String("pHash", hash.ToString()))
 
 	pHash := createPhash(v, h)
+	pHash.Signatures = createSignatures([]image.Image{collage}, opts.Extra)
+	if opts.Transforms {
+		pHash.Signatures = append(pHash.Signatures, createVariants(images, true)...)
+	}
 	slog.Debug("Created pHash", slog.Any("pHash", *pHash))
 
 	return pHash, screenshots, nil
 }
 
-func createSlowPhash(vp *videoprocessor.FFmpegWrapper, v *models.Video) (*models.Videohash, *models.Screenshots, error) {
+func createSlowPhash(ctx context.Context, vp *videoprocessor.FFmpegWrapper, v *models.Video, opts Options) (*models.Videohash, *models.Screenshots, error) {
 	numFrames := int(math.Floor(float64(v.Duration)))
 	if numFrames == 0 {
 		return nil, nil, fmt.Errorf("error numFrames == 0 for slowPhash")
 	}
 
-	timestamps := createTimeStamps(v.Duration, numFrames)
-	images, err := createScreenshots(vp, timestamps, v)
+	// same frames as createTimeStamps(v.Duration, numFrames), in one decode
+	intro := float64(v.Duration) / 10
+	interval := float64(v.Duration) * 8 / 10 / float64(numFrames)
+	images, err := createScreenshotsAtInterval(ctx, vp, v, intro, interval, numFrames, videoprocessor.PixelRGB24)
 	if err != nil {
 		slog.Error("Error creating screenshots", slog.Any("error", err))
 		return nil, nil, err
 	}
 
-	var thumbIndex int
-	if len(images) < 5 {
-		thumbIndex = 0
-	} else {
-		thumbIndex = 5
-	}
+	thumbIndex := min(5, len(images)-1)
 	screenshots := &models.Screenshots{Screenshots: []image.Image{images[thumbIndex]}}
 
-	var builder strings.Builder
-	for i, img := range images {
-		/*
-			            // troubleshoot saving images to fs
-						outputPath := fmt.Sprintf("test%d.bmp", i)
-						file, err := os.Create(outputPath)
-						if err != nil {
-							return nil, nil, fmt.Errorf("error creating file for image %d: %w", i, err)
-						}
-						defer file.Close()
-
-						// pHash (it handles grayscale etc...)
-						if err := bmp.Encode(file, img); err != nil {
-							return nil, nil, fmt.Errorf("error writing BMP for image %d: %w", i, err)
-						}
-		*/
-
-		hash, hashErr := goimagehash.PerceptionHash(img)
-		if hashErr != nil {
-			slog.Warn("SlowPhash: skipping frame can't compute pHash", slog.Int("frameIndex", i), slog.Any("error", hashErr))
-			continue
-		}
+	frames := frameHashes(images, "SlowPhash")
 
-		// skip "p: " prefix in hash and append
-		builder.WriteString(hash.ToString()[2:])
+	slog.Info("SlowPhash: computed frame pHashes",
+		slog.String("file", v.FileName),
+		slog.Int("framesUsed", numFrames),
+	)
+
+	pHash := &models.Videohash{
+		ID:          v.ID,
+		HashType:    models.HashTypeSlow,
+		FrameHashes: frames,
+		Duration:    v.Duration,
+		Bucket:      -1,
+		Signatures:  createSignatures(images, opts.Extra),
+	}
+	if opts.Transforms {
+		pHash.Signatures = append(pHash.Signatures, createVariants(images, false)...)
 	}
 
-	combinedHash := builder.String()
-	if len(combinedHash) == 0 {
-		return nil, nil, fmt.Errorf("SlowPhash: no valid pHashes generated")
+	return pHash, screenshots, nil
+}
+
+// createTemporalPhash hashes one frame every models.TemporalSampleInterval
+// seconds over the whole video, intro and outro included, so that trimmed
+// copies and clips can be aligned against it. Frames that can't be hashed are
+// stored as zero hashes to keep the positions in step with time.
+func createTemporalPhash(ctx context.Context, vp *videoprocessor.FFmpegWrapper, v *models.Video) (*models.Videohash, *models.Screenshots, error) {
+	numFrames := int(math.Floor(float64(v.Duration) / models.TemporalSampleInterval))
+	if numFrames == 0 {
+		return nil, nil, fmt.Errorf("error numFrames == 0 for temporalPhash")
+	}
+
+	// sample the middle of each interval
+	// gray is enough for alignment and a third of the data, pHash ignores
+	// the luma range difference to the rgb24 frames
+	images, err := createScreenshotsAtInterval(ctx, vp, v, models.TemporalSampleInterval/2, models.TemporalSampleInterval, numFrames, videoprocessor.PixelGray)
+	if err != nil {
+		slog.Error("Error creating screenshots", slog.Any("error", err))
+		return nil, nil, err
 	}
 
-	slog.Info("SlowPhash: computed multiple pHashes",
+	screenshots := &models.Screenshots{Screenshots: []image.Image{images[len(images)/10]}}
+
+	frames := frameHashes(images, "TemporalPhash")
+
+	slog.Info("TemporalPhash: computed frame pHashes",
 		slog.String("file", v.FileName),
-		slog.Int("framesUsed", numFrames),
-		slog.String("combinedHash", combinedHash),
+		slog.Int("frames", len(images)),
 	)
 
 	pHash := &models.Videohash{
-		ID:        v.ID,
-		HashType:  "pHash",
-		HashValue: combinedHash,
-		Duration:  v.Duration,
-		Bucket:    -1,
+		ID:          v.ID,
+		HashType:    models.HashTypeTemporal,
+		FrameHashes: frames,
+		Duration:    v.Duration,
+		Bucket:      -1,
+	}
+
+	return pHash, screenshots, nil
+}
+
+const (
+	DefaultSceneThreshold = 0.3
+	// maxSceneFrames caps the scenes hashed per video
+	maxSceneFrames = 200
+	// minSceneFrames is the fewest scenes worth comparing, videos with fewer
+	// cuts also get a frame every sceneFallbackInterval seconds
+	minSceneFrames = 4
+	// sceneFallbackInterval doesn't depend on the duration, so a trimmed copy
+	// gets frames at the same spacing, only shifted by less than the interval
+	sceneFallbackInterval = 10.0
+)
+
+// createScenePhash hashes the first frame of every scene. The cuts are where
+// they are in every copy, whatever was trimmed around them, so the hashes
+// are compared as a set rather than by position.
+func createScenePhash(ctx context.Context, vp *videoprocessor.FFmpegWrapper, v *models.Video, opts Options) (*models.Videohash, *models.Screenshots, error) {
+	threshold := opts.SceneThreshold
+	if threshold == 0 {
+		threshold = DefaultSceneThreshold
+	}
+	filter := vp.FrameFilter(ctx, v)
+	images, err := vp.SceneFrames(ctx, v, threshold, maxSceneFrames, filter, videoprocessor.PixelRGB24)
+	if err != nil {
+		slog.Error("Error creating scene screenshots", slog.Any("error", err))
+		return nil, nil, fmt.Errorf("skipping file, cannot generate screenshots, err: %q", err)
 	}
 
+	fallback := min(maxSceneFrames-len(images), int(float64(v.Duration)/sceneFallbackInterval))
+	if len(images) < minSceneFrames && fallback > 0 {
+		slog.Info("Few scene cuts, adding evenly spaced frames",
+			slog.String("file", v.FileName), slog.Int("scenes", len(images)), slog.Int("frames", fallback))
+		extra, err := vp.FramesAtInterval(ctx, v, 0, sceneFallbackInterval, fallback, filter, videoprocessor.PixelRGB24)
+		if err != nil {
+			slog.Warn("Can't add evenly spaced frames", slog.String("path", v.Path), slog.Any("error", err))
+		}
+		images = append(images, extra...)
+	}
+	if len(images) == 0 {
+		return nil, nil, fmt.Errorf("skipping file, ffmpeg returned no screenshots")
+	}
+
+	screenshots := &models.Screenshots{Screenshots: []image.Image{images[len(images)/2]}}
+	frames := frameHashes(images, "ScenePhash")
+
+	slog.Info("ScenePhash: computed scene pHashes",
+		slog.String("file", v.FileName),
+		slog.Int("scenes", len(images)),
+	)
+
+	pHash := &models.Videohash{
+		ID:          v.ID,
+		HashType:    models.HashTypeScene,
+		FrameHashes: frames,
+		Duration:    v.Duration,
+		Bucket:      -1,
+	}
 	return pHash, screenshots, nil
 }
 
-func createTimeStamps(duration float32, numTimestamps int) []string {
+// frameHashes returns the pHash of every image. Images that can't be hashed
+// or carry too little information get a zero (blank) hash so the positions
+// stay in step with time. Unlike createScreenshots they aren't resampled:
+// a frame from a few seconds away would be out of step.
+func frameHashes(images []image.Image, method string) models.FrameHashes {
+	frames := make(models.FrameHashes, len(images))
+	for i, img := range images {
+		if IsLowInformation(img) {
+			continue
+		}
+		hash, err := goimagehash.PerceptionHash(img)
+		if err != nil {
+			slog.Warn(method+": can't compute pHash, storing a blank frame", slog.Int("frameIndex", i), slog.Any("error", err))
+			continue
+		}
+		frames[i] = hash.GetHash()
+	}
+	return frames
+}
+
+// createSignatures hashes images with each hasher. Images that can't be
+// hashed get zero words so the positions stay in step.
+func createSignatures(images []image.Image, hashers []ImageHasher) []models.Signature {
+	signatures := make([]models.Signature, 0, len(hashers))
+	for _, h := range hashers {
+		sig := models.Signature{Algorithm: h.Name(), Words: h.Words()}
+		sig.Hashes = make(models.FrameHashes, 0, len(images)*h.Words())
+		for i, img := range images {
+			words, err := h.Hash(img)
+			if err != nil || len(words) != h.Words() {
+				slog.Warn("Can't compute hash, storing a blank one", slog.String("algorithm", h.Name()), slog.Int("frameIndex", i), slog.Any("error", err))
+				words = make([]uint64, h.Words())
+			}
+			sig.Hashes = append(sig.Hashes, words...)
+		}
+		signatures = append(signatures, sig)
+	}
+	return signatures
+}
+
+// createTimeStamps spreads numTimestamps times, in seconds, evenly between
+// 10% and 90% of duration.
+func createTimeStamps(duration float32, numTimestamps int) []float32 {
 	if numTimestamps <= 0 {
 		return nil
 	}
@@ -135,10 +267,9 @@ func createTimeStamps(duration float32, numTimestamps int) []string {
 	outro := duration * 9 / 10
 	interval := (outro - intro) / float32(numTimestamps)
 
-	var timestamps []string
+	var timestamps []float32
 	for i := range numTimestamps {
-		t := durationToFFmpegTimestamp(intro + float32(i)*interval)
-		timestamps = append(timestamps, t)
+		timestamps = append(timestamps, intro+float32(i)*interval)
 	}
 
 	return timestamps
@@ -156,26 +287,60 @@ func durationToFFmpegTimestamp(d float32) string {
 	return fmt.Sprintf("%02d:%02d:%02d.%03d", hours, minutes, seconds, milliseconds)
 }
 
-func createScreenshots(vp *videoprocessor.FFmpegWrapper, timestamps []string, v *models.Video) ([]image.Image, error) {
-	images := []image.Image{}
-	buf := bytes.Buffer{}
-
+// createScreenshots grabs a frame at each timestamp. Blank frames, e.g. a
+// fade to black, are replaced by an informative frame a few seconds away when
+// there is one.
+func createScreenshots(ctx context.Context, vp *videoprocessor.FFmpegWrapper, timestamps []float32, v *models.Video) ([]image.Image, error) {
+	filter := vp.FrameFilter(ctx, v)
+	images := make([]image.Image, 0, len(timestamps))
 	for _, t := range timestamps {
-		err := vp.ScreenshotAtTime(v.Path, &buf, t)
+		img, err := vp.FrameAtTime(ctx, v, durationToFFmpegTimestamp(t), filter, videoprocessor.PixelRGB24)
 		if err != nil {
 			return nil, fmt.Errorf("skipping file, cannot generate screenshots, err: %q", err)
 		}
+		if IsLowInformation(img) {
+			img = resampleNear(ctx, vp, v, filter, t, img)
+		}
+		images = append(images, img)
+	}
 
-		img, err := bmp.Decode(&buf)
+	return images, nil
+}
+
+// resampleOffsets are tried in order, in seconds from the blank frame.
+var resampleOffsets = []float32{2, -2, 5, -5, 10, -10}
+
+// resampleNear returns the first informative frame at one of resampleOffsets
+// from t, or blank when there is none.
+func resampleNear(ctx context.Context, vp *videoprocessor.FFmpegWrapper, v *models.Video, filter string, t float32, blank image.Image) image.Image {
+	for _, offset := range resampleOffsets {
+		at := t + offset
+		if at < 0 || at >= v.Duration {
+			continue
+		}
+		img, err := vp.FrameAtTime(ctx, v, durationToFFmpegTimestamp(at), filter, videoprocessor.PixelRGB24)
 		if err != nil {
-			slog.Error("Error decoding image", slog.Any("error", err))
-			return images, err
+			continue
+		}
+		if !IsLowInformation(img) {
+			slog.Debug("Resampled blank frame", slog.String("path", v.Path), slog.Float64("from", float64(t)), slog.Float64("to", float64(at)))
+			return img
 		}
-
-		images = append(images, img)
-		buf.Reset()
 	}
+	slog.Debug("No informative frame near blank frame", slog.String("path", v.Path), slog.Float64("at", float64(t)))
+	return blank
+}
 
+// createScreenshotsAtInterval decodes the video once for all the frames
+// Slow and TemporalPhash need, instead of one ffmpeg process per frame.
+func createScreenshotsAtInterval(ctx context.Context, vp *videoprocessor.FFmpegWrapper, v *models.Video, start, interval float64, count int, pix videoprocessor.PixelFormat) ([]image.Image, error) {
+	images, err := vp.FramesAtInterval(ctx, v, start, interval, count, vp.FrameFilter(ctx, v), pix)
+	if err != nil {
+		return nil, fmt.Errorf("skipping file, cannot generate screenshots, err: %q", err)
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("skipping file, ffmpeg returned no screenshots")
+	}
 	return images, nil
 }
 
@@ -235,94 +400,3 @@ func ConvertImagesToBase64(images []image.Image) ([]string, error) {
 	}
 	return encodedStrings, nil
 }
-
-/*
-func createSlowPhash(vp *videoprocessor.FFmpegWrapper, v *models.Video) (*models.Videohash, *models.Screenshots, error) {
-	numFrames := int(math.Floor(float64(v.Duration)))
-
-	// build a list of timestamps [0s, 1s, 2s, ...] up to numFrames
-	timeStamps := make([]string, 0, numFrames)
-	for i := range numFrames {
-		// convert `i` seconds to "HH:MM:SS.mmm"
-		timeStr := durationToFFmpegTimestamp(float32(i))
-		timeStamps = append(timeStamps, timeStr)
-	}
-
-	if len(timeStamps) == 0 {
-		return nil, nil, fmt.Errorf("no timestamps generated for slowPhash")
-	}
-
-	// returns at least one screenshot or errors
-	var buf bytes.Buffer
-	bmpBytes, err := vp.ScreenshotsAtTimestamps(v.Path, &buf, timeStamps)
-	if err != nil {
-		slog.Error("SlowPhash: error retrieving frames", slog.Any("error", err))
-		return nil, nil, err
-	}
-
-	var thumbIndex int
-	if len(bmpBytes) < 5 {
-		thumbIndex = 0
-	} else {
-		thumbIndex = 5
-	}
-	slog.Info("thumbIndex", "value:", thumbIndex)
-
-	var screenshot *models.Screenshots
-	var builder strings.Builder
-	for i, bmpData := range bmpBytes {
-		img, decErr := bmpDecodeBytes(bmpData)
-		if decErr != nil {
-			slog.Warn("SlowPhash: skipping broken frame", slog.Int("frameIndex", i), slog.Any("error", decErr))
-			continue
-		}
-		// store the decoded frame so we can display in UI
-		if i == thumbIndex {
-			screenshot = &models.Screenshots{Screenshots: []image.Image{img}}
-		}
-		os.WriteFile(fmt.Sprintf("test%d.bmp", i), bmpData, os.ModePerm)
-
-		// compute pHash (it handles grayscale etc...)
-		hash, hashErr := goimagehash.PerceptionHash(img)
-		if hashErr != nil {
-			slog.Warn("SlowPhash: skipping frame can't compute pHash", slog.Int("frameIndex", i), slog.Any("error", hashErr))
-			continue
-		}
-
-		// skip "p: "
-		builder.WriteString(hash.ToString()[2:])
-	}
-
-	combinedHash := builder.String()
-
-	if len(combinedHash) == 0 {
-		return nil, nil, fmt.Errorf("SlowPhash: no valid pHashes generated")
-	}
-
-		slog.Info("SlowPhash: computed multiple pHashes",
-			slog.String("file", v.FileName),
-			slog.Int("framesUsed", len(bmpBytes)),
-			slog.String("combinedHash", combinedHash),
-		)
-
-	pHash := &models.Videohash{
-		ID:        v.ID,
-		HashType:  "pHash",
-		HashValue: combinedHash,
-		Duration:  v.Duration,
-		Bucket:    -1,
-	}
-
-	return pHash, screenshot, nil
-}
-
-func bmpDecodeBytes(bmpData []byte) (image.Image, error) {
-	buf := bytes.NewReader(bmpData)
-	img, err := bmp.Decode(buf)
-	if err != nil {
-		return nil, fmt.Errorf("bmp decode failed: %w", err)
-	}
-	return img, nil
-}
-
-*/
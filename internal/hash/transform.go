@@ -0,0 +1,73 @@
+package hash
+
+import (
+	"image"
+	"log/slog"
+
+	"govdupes/internal/models"
+
+	"github.com/corona10/goimagehash"
+)
+
+// createVariants stores the pHashes of the frames flipped and rotated by each
+// of models.Transforms, one per frame, or one of the collage when collage is
+// set, so that re-uploads in another orientation can be matched.
+func createVariants(images []image.Image, collage bool) []models.Signature {
+	variants := make([]models.Signature, 0, len(models.Transforms))
+	for _, t := range models.Transforms {
+		transformed := make([]image.Image, len(images))
+		for i, img := range images {
+			transformed[i] = transformImage(img, t)
+		}
+
+		sig := models.Signature{Algorithm: models.VariantAlgorithm(t), Words: 1}
+		if collage {
+			img, err := createCollage(transformed)
+			if err != nil {
+				slog.Warn("Can't create collage for variant", slog.String("transform", string(t)), slog.Any("error", err))
+				continue
+			}
+			hash, err := goimagehash.PerceptionHash(img)
+			if err != nil {
+				slog.Warn("Can't compute variant pHash", slog.String("transform", string(t)), slog.Any("error", err))
+				continue
+			}
+			sig.Hashes = models.FrameHashes{hash.GetHash()}
+		} else {
+			sig.Hashes = frameHashes(transformed, "Variant "+string(t))
+		}
+		variants = append(variants, sig)
+	}
+	return variants
+}
+
+// transformImage returns img flipped or rotated clockwise by t.
+func transformImage(img image.Image, t models.Transform) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	var out *image.RGBA
+	if t == models.TransformRot90 || t == models.TransformRot270 {
+		out = image.NewRGBA(image.Rect(0, 0, h, w))
+	} else {
+		out = image.NewRGBA(image.Rect(0, 0, w, h))
+	}
+
+	for y := range h {
+		for x := range w {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			switch t {
+			case models.TransformHFlip:
+				out.Set(w-1-x, y, c)
+			case models.TransformRot90:
+				out.Set(h-1-y, x, c)
+			case models.TransformRot180:
+				out.Set(w-1-x, h-1-y, c)
+			case models.TransformRot270:
+				out.Set(y, w-1-x, c)
+			default:
+				out.Set(x, y, c)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,35 @@
+package hash
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"govdupes/internal/models"
+)
+
+func TestTransformImage(t *testing.T) {
+	// 3x2 image with a marked top left pixel
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, color.White)
+
+	tests := []struct {
+		transform  models.Transform
+		w, h, x, y int
+	}{
+		{models.TransformHFlip, 3, 2, 2, 0},
+		{models.TransformRot90, 2, 3, 1, 0},
+		{models.TransformRot180, 3, 2, 2, 1},
+		{models.TransformRot270, 2, 3, 0, 2},
+	}
+	for _, tt := range tests {
+		out := transformImage(img, tt.transform)
+		if b := out.Bounds(); b.Dx() != tt.w || b.Dy() != tt.h {
+			t.Errorf("%s: size %dx%d, want %dx%d", tt.transform, b.Dx(), b.Dy(), tt.w, tt.h)
+			continue
+		}
+		if r, _, _, _ := out.At(tt.x, tt.y).RGBA(); r == 0 {
+			t.Errorf("%s: marked pixel not at %d,%d", tt.transform, tt.x, tt.y)
+		}
+	}
+}
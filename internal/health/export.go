@@ -0,0 +1,52 @@
+package health
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"govdupes/internal/models"
+)
+
+// Export writes checks as "csv" or "json".
+func Export(w io.Writer, checks []*models.HealthCheck, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(checks)
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"path", "status", "details", "full", "size", "modifiedAt", "checkedAt"}); err != nil {
+			return err
+		}
+		for _, c := range checks {
+			record := []string{
+				c.Path,
+				string(c.Status),
+				c.Details,
+				strconv.FormatBool(c.Full),
+				strconv.FormatInt(c.Size, 10),
+				c.ModifiedAt.Format(time.RFC3339),
+				c.CheckedAt.Format(time.RFC3339),
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown export format %q, expected csv or json", format)
+	}
+}
+
+// FormatFromPath picks the export format from path's extension.
+func FormatFromPath(path string) string {
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+}
@@ -0,0 +1,121 @@
+// Package health decodes videos to find damaged files.
+package health
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"govdupes/internal/models"
+	"govdupes/internal/videoprocessor"
+)
+
+const (
+	// fastSeconds is how much of the start and of the end a fast check decodes
+	fastSeconds = 5
+	// maxDetailLines is how many lines of ffmpeg's errors are kept per file
+	maxDetailLines = 10
+	// endTolerance is how many seconds decoding may end before the duration
+	// in the header, streams often end a little apart
+	endTolerance = 1.5
+)
+
+// truncationMarkers are what ffmpeg's demuxers log when a file ends early.
+var truncationMarkers = []string{
+	"partial file",
+	"end of file",
+	"ended prematurely",
+	"moov atom not found",
+	"truncat",
+}
+
+// Check probes v and decodes it. A fast check decodes the first and last
+// seconds, which is enough for cut off downloads and broken containers, a
+// full check decodes every frame. Either way a video whose decoding ends
+// before the duration in its header is truncated, even without errors.
+func Check(ctx context.Context, vp *videoprocessor.FFmpegWrapper, v *models.Video, full bool) *models.HealthCheck {
+	c := &models.HealthCheck{
+		Path:       v.Path,
+		Full:       full,
+		Size:       v.Size,
+		ModifiedAt: v.ModifiedAt,
+		CheckedAt:  time.Now(),
+	}
+
+	if err := vp.Probe(ctx, v); err != nil {
+		c.Status, c.Details = classify("", err)
+		return c
+	}
+	if v.VideoCodec == "" {
+		c.Status, c.Details = models.HealthMissingStreams, "no video stream"
+		return c
+	}
+
+	var log strings.Builder
+	var runErr error
+	var end float64
+	if full || v.Duration <= 2*fastSeconds {
+		out, decoded, err := vp.DecodeErrors(ctx, v, 0, 0)
+		log.WriteString(out)
+		runErr, end = err, decoded
+	} else {
+		for _, start := range []float64{0, float64(v.Duration) - fastSeconds} {
+			out, decoded, err := vp.DecodeErrors(ctx, v, start, fastSeconds)
+			log.WriteString(out)
+			if err != nil {
+				runErr = err
+			}
+			end = start + decoded
+		}
+	}
+	c.Status, c.Details = classify(log.String(), runErr)
+	if runErr == nil && c.Status != models.HealthTruncated {
+		if short := endsEarly(float64(v.Duration), end); short != "" {
+			c.Status = models.HealthTruncated
+			c.Details = strings.TrimSpace(short + "\n" + c.Details)
+		}
+	}
+	return c
+}
+
+// endsEarly describes how far before duration decoding ended, empty when
+// it got to the end. ffprobe reads the duration from the header, which a cut
+// off download still has in full.
+func endsEarly(duration, end float64) string {
+	if duration <= 0 || end >= duration-endTolerance {
+		return ""
+	}
+	return fmt.Sprintf("decoding ended at %.1fs of %.1fs", end, duration)
+}
+
+// classify turns what ffmpeg logged at error level into a status. A run
+// that failed without logging anything counts as a decode error.
+func classify(log string, runErr error) (models.HealthStatus, string) {
+	var lines []string
+	for _, line := range strings.Split(log, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 && runErr != nil {
+		lines = append(lines, runErr.Error())
+	}
+	if len(lines) == 0 {
+		return models.HealthOK, ""
+	}
+
+	status := models.HealthDecodeErrors
+	for _, line := range lines {
+		lower := strings.ToLower(line)
+		for _, marker := range truncationMarkers {
+			if strings.Contains(lower, marker) {
+				status = models.HealthTruncated
+			}
+		}
+	}
+	if len(lines) > maxDetailLines {
+		lines = append(lines[:maxDetailLines], fmt.Sprintf("... %d more lines", len(lines)-maxDetailLines))
+	}
+	return status, strings.Join(lines, "\n")
+}
@@ -0,0 +1,63 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"govdupes/internal/models"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		log    string
+		runErr error
+		want   models.HealthStatus
+	}{
+		{"clean", "", nil, models.HealthOK},
+		{"blank lines", "\n  \n", nil, models.HealthOK},
+		{"decode error", "[h264 @ 0x1] error while decoding MB 10 4, bytestream -5\n", nil, models.HealthDecodeErrors},
+		{"partial mp4", "[mov,mp4,m4a,3gp,3g2,mj2 @ 0x1] stream 0, offset 0x2a: partial file\n", nil, models.HealthTruncated},
+		{"missing moov", "[mov,mp4,m4a,3gp,3g2,mj2 @ 0x1] moov atom not found\n", errors.New("exit status 1"), models.HealthTruncated},
+		{"matroska", "[matroska,webm @ 0x1] File ended prematurely\n", nil, models.HealthTruncated},
+		{"silent failure", "", errors.New("exit status 1"), models.HealthDecodeErrors},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, details := classify(tt.log, tt.runErr)
+			if got != tt.want {
+				t.Errorf("classify() = %q (%q), want %q", got, details, tt.want)
+			}
+		})
+	}
+
+	var log strings.Builder
+	for i := range 25 {
+		fmt.Fprintf(&log, "error %d\n", i)
+	}
+	_, details := classify(log.String(), nil)
+	if lines := strings.Split(details, "\n"); len(lines) != maxDetailLines+1 || lines[maxDetailLines] != "... 15 more lines" {
+		t.Errorf("classify() kept %d lines, last %q", len(lines), lines[len(lines)-1])
+	}
+}
+
+func TestEndsEarly(t *testing.T) {
+	tests := []struct {
+		name          string
+		duration, end float64
+		want          bool
+	}{
+		{"complete", 600, 600, false},
+		{"audio a little shorter", 600, 599.2, false},
+		{"cut off download", 600, 412.5, true},
+		{"seek past the end", 600, 595, true},
+		{"unknown duration", 0, 0, false},
+	}
+	for _, tt := range tests {
+		if got := endsEarly(tt.duration, tt.end) != ""; got != tt.want {
+			t.Errorf("%s: endsEarly(%v, %v) = %v, want %v", tt.name, tt.duration, tt.end, got, tt.want)
+		}
+	}
+}
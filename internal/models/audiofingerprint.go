@@ -0,0 +1,42 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+)
+
+// AudioFingerprint is a sequence of 32 bit spectral sub-fingerprints, stored
+// as a BLOB of little-endian uint32s. A zero sub-fingerprint is silence.
+type AudioFingerprint []uint32
+
+func (af *AudioFingerprint) Scan(value any) error {
+	if value == nil {
+		*af = nil
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("unsupported type for audio fingerprint: %T", value)
+	}
+	if len(b)%4 != 0 {
+		return fmt.Errorf("audio fingerprint blob has %d bytes, not a multiple of 4", len(b))
+	}
+	fp := make(AudioFingerprint, len(b)/4)
+	for i := range fp {
+		fp[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	*af = fp
+	return nil
+}
+
+func (af AudioFingerprint) Value() (driver.Value, error) {
+	if len(af) == 0 {
+		return nil, nil
+	}
+	b := make([]byte, 0, len(af)*4)
+	for _, v := range af {
+		b = binary.LittleEndian.AppendUint32(b, v)
+	}
+	return b, nil
+}
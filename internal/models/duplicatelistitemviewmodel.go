@@ -8,4 +8,6 @@ type DuplicateListItemViewModel struct {
 	VideoData       *VideoData
 	Selected        bool
 	Hidden          bool
+	IsReference     bool
+	QualityScore    float64
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+type HealthStatus string
+
+const (
+	HealthOK             HealthStatus = "ok"
+	HealthTruncated      HealthStatus = "truncated"
+	HealthDecodeErrors   HealthStatus = "decode_errors"
+	HealthMissingStreams HealthStatus = "missing_streams"
+)
+
+// HealthCheck is the result of decoding a file. Full is set when the whole
+// file was decoded rather than its start and end.
+type HealthCheck struct {
+	Path       string       `db:"path" json:"path"`
+	Status     HealthStatus `db:"status" json:"status"`
+	Details    string       `db:"details" json:"details"`
+	Full       bool         `db:"full" json:"full"`
+	Size       int64        `db:"size" json:"size"`
+	ModifiedAt time.Time    `db:"modifiedAt" json:"modifiedAt"`
+	CheckedAt  time.Time    `db:"checkedAt" json:"checkedAt"`
+}
+
+// Covers reports whether the check still holds for v and is at least as
+// thorough as a check with full.
+func (c *HealthCheck) Covers(v *Video, full bool) bool {
+	return c.Size == v.Size && c.ModifiedAt.Equal(v.ModifiedAt) && (c.Full || !full)
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type ReviewDecisionType string
+
+const (
+	// ReviewNotDuplicate stops the matcher from pairing any two of the videohashes.
+	ReviewNotDuplicate ReviewDecisionType = "not_duplicate"
+	// ReviewKeepAll hides the group until a new video joins it.
+	ReviewKeepAll ReviewDecisionType = "keep_all"
+)
+
+// ReviewDecision is a user's verdict on a duplicate group or pair, keyed by
+// the videohashes it covers so it survives rescans.
+type ReviewDecision struct {
+	ID           int64              `db:"id" json:"id"`
+	Decision     ReviewDecisionType `db:"decision" json:"decision"`
+	CreatedAt    time.Time          `db:"createdAt" json:"createdAt"`
+	VideohashIDs []int64            `json:"videohashIDs"`
+	Paths        []string           `json:"paths"`
+}
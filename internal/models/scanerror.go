@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// ScanStage is the step of a scan a file failed in.
+type ScanStage string
+
+const (
+	StageWalk    ScanStage = "walk"
+	StageFFprobe ScanStage = "ffprobe"
+	StageFFmpeg  ScanStage = "ffmpeg"
+	StageHash    ScanStage = "hash"
+	StageWrite   ScanStage = "write"
+)
+
+// ScanError is the last failure for a path. Size and ModifiedAt are the
+// file's when it failed, scans skip the file until either changes.
+type ScanError struct {
+	Path       string    `db:"path" json:"path"`
+	Stage      ScanStage `db:"stage" json:"stage"`
+	Error      string    `db:"error" json:"error"`
+	Size       int64     `db:"size" json:"size"`
+	ModifiedAt time.Time `db:"modifiedAt" json:"modifiedAt"`
+	OccurredAt time.Time `db:"occurredAt" json:"occurredAt"`
+	// Ignored hides the error from the list, the file is still skipped
+	Ignored bool `db:"ignored" json:"ignored"`
+}
+
+// Unchanged reports whether v is the same file that failed.
+func (e *ScanError) Unchanged(v *Video) bool {
+	return e.Size == v.Size && e.ModifiedAt.Equal(v.ModifiedAt)
+}
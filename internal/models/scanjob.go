@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// ScanTaskStage is how far a scan got with a file.
+type ScanTaskStage string
+
+const (
+	TaskPending ScanTaskStage = "pending"
+	TaskProbed  ScanTaskStage = "probed"
+	// TaskDone files are stored with their hash, TaskFailed ones have a
+	// ScanError. Neither is scanned again when the job resumes.
+	TaskDone   ScanTaskStage = "done"
+	TaskFailed ScanTaskStage = "failed"
+)
+
+// ScanJob is one search. Its tasks are the files found by the walk that
+// weren't in the database, so an interrupted search can resume without
+// walking the dirs again.
+type ScanJob struct {
+	ID int64 `db:"id" json:"id"`
+	// Dirs are the starting dirs of the search, one per line
+	Dirs      string    `db:"dirs" json:"dirs"`
+	Total     int       `db:"total" json:"total"`
+	Finished  bool      `db:"finished" json:"finished"`
+	CreatedAt time.Time `db:"createdAt" json:"createdAt"`
+	// Remaining counts the tasks that are neither done nor failed
+	Remaining int `db:"remaining" json:"remaining"`
+}
+
+type ScanTask struct {
+	FKTaskJob int64         `db:"FK_task_job" json:"FK_task_job"`
+	Path      string        `db:"path" json:"path"`
+	Stage     ScanTaskStage `db:"stage" json:"stage"`
+}
@@ -1,5 +1,92 @@
 package models
 
+import "strings"
+
+// GroupMatchMode decides how matching rows affect their duplicate group.
+type GroupMatchMode int
+
+const (
+	// GroupMatchRows shows only the matching rows of each group.
+	GroupMatchRows GroupMatchMode = iota
+	// GroupMatchAny shows whole groups that have at least one matching row.
+	GroupMatchAny
+	// GroupMatchAll shows whole groups in which every row matches.
+	GroupMatchAll
+)
+
+// SearchQuery is a disjunction of conjunctions: a row matches when every
+// term of at least one of the OrGroups matches.
 type SearchQuery struct {
-	OrGroups [][]string
+	OrGroups  [][]SearchTerm
+	GroupMode GroupMatchMode
+}
+
+// SearchTerm is either free text matched against the path and file name
+// (Field == "") or a predicate on a named field, e.g. size>1GB.
+type SearchTerm struct {
+	Field    string
+	Op       string // ":", "=", "!=", "<", "<=", ">" or ">="
+	Text     string // lower-cased
+	Num      float64
+	Excluded bool
+}
+
+// Matches reports whether vd satisfies the term.
+func (t SearchTerm) Matches(vd *VideoData) bool {
+	var ok bool
+	switch {
+	case t.Field == "":
+		haystack := strings.ToLower(vd.Video.Path + " " + vd.Video.FileName)
+		ok = strings.Contains(haystack, t.Text)
+	case fieldKinds[t.Field] == FieldNumeric:
+		v := vd.NumericField(t.Field)
+		switch t.Op {
+		case ":", "=":
+			ok = v == t.Num
+		case "!=":
+			ok = v != t.Num
+		case "<":
+			ok = v < t.Num
+		case "<=":
+			ok = v <= t.Num
+		case ">":
+			ok = v > t.Num
+		case ">=":
+			ok = v >= t.Num
+		}
+	default:
+		v := vd.TextField(t.Field)
+		switch t.Op {
+		case ":":
+			ok = strings.Contains(v, t.Text)
+		case "=":
+			ok = v == t.Text
+		case "!=":
+			ok = v != t.Text
+		}
+	}
+	if t.Excluded {
+		return !ok
+	}
+	return ok
+}
+
+// Matches reports whether vd satisfies the query. An empty query matches everything.
+func (q SearchQuery) Matches(vd *VideoData) bool {
+	if len(q.OrGroups) == 0 {
+		return true
+	}
+	for _, andGroup := range q.OrGroups {
+		all := true
+		for _, t := range andGroup {
+			if !t.Matches(vd) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
 }
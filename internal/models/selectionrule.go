@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+type SelectionRule struct {
+	ID         int64     `db:"id" json:"id"`
+	Name       string    `db:"name" json:"name"`
+	Expression string    `db:"expression" json:"expression"`
+	CreatedAt  time.Time `db:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,33 @@
+package models
+
+// Signature is a hash made with an extra algorithm next to the pHash of the
+// videohash. FastPhash stores one hash, SlowPhash one per frame.
+type Signature struct {
+	FKSignatureVideohash int64       `db:"FK_signature_videohash" json:"-"`
+	Algorithm            string      `db:"algorithm" json:"algorithm"`
+	Words                int         `db:"words" json:"words"` // 64-bit words per hash
+	Hashes               FrameHashes `db:"hashes" json:"hashes"`
+}
+
+// Len returns the number of hashes.
+func (s *Signature) Len() int {
+	if s.Words <= 0 {
+		return 0
+	}
+	return len(s.Hashes) / s.Words
+}
+
+// At returns the i-th hash.
+func (s *Signature) At(i int) []uint64 {
+	return s.Hashes[i*s.Words : (i+1)*s.Words]
+}
+
+// Signature returns the signature made with algorithm, or nil.
+func (vh *Videohash) Signature(algorithm string) *Signature {
+	for i := range vh.Signatures {
+		if vh.Signatures[i].Algorithm == algorithm {
+			return &vh.Signatures[i]
+		}
+	}
+	return nil
+}
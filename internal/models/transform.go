@@ -0,0 +1,64 @@
+package models
+
+import "strings"
+
+// Transform is a change of orientation between two copies of a video.
+type Transform string
+
+const (
+	TransformNone   Transform = ""
+	TransformHFlip  Transform = "hflip"
+	TransformRot90  Transform = "rot90"
+	TransformRot180 Transform = "rot180"
+	TransformRot270 Transform = "rot270"
+)
+
+// Transforms are the variants hashed when transform matching is enabled.
+var Transforms = []Transform{TransformHFlip, TransformRot90, TransformRot180, TransformRot270}
+
+// variantPrefix marks signatures holding the pHash of a transformed frame.
+const variantPrefix = "phash:"
+
+// VariantAlgorithm is the signature algorithm for the pHash of frames
+// transformed by t.
+func VariantAlgorithm(t Transform) string {
+	return variantPrefix + string(t)
+}
+
+// Transform returns the transform of a variant signature, TransformNone for
+// signatures of other algorithms.
+func (s *Signature) Transform() Transform {
+	t, ok := strings.CutPrefix(s.Algorithm, variantPrefix)
+	if !ok {
+		return TransformNone
+	}
+	return Transform(t)
+}
+
+// Inverse returns the transform that undoes t.
+func (t Transform) Inverse() Transform {
+	switch t {
+	case TransformRot90:
+		return TransformRot270
+	case TransformRot270:
+		return TransformRot90
+	default:
+		return t
+	}
+}
+
+// Describe words t for the UI.
+func (t Transform) Describe() string {
+	switch t {
+	case TransformHFlip:
+		return "mirrored"
+	case TransformRot90:
+		return "rotated 90° clockwise"
+	case TransformRot180:
+		return "upside down"
+	case TransformRot270:
+		return "rotated 90° anticlockwise"
+	default:
+		return ""
+	}
+}
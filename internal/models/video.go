@@ -29,9 +29,68 @@ type Video struct {
 	SampleRateAvg  int       `db:"sampleRateAvg" json:"sampleRateAvg"`
 	Corrupted      bool
 
+	// from ffprobe, the codec fields above are the main video and audio stream's
+	Container         string    `db:"container" json:"container"`
+	MediaCreatedAt    time.Time `db:"mediaCreatedAt" json:"mediaCreatedAt"`
+	Rotation          int       `db:"rotation" json:"rotation"`
+	PixFmt            string    `db:"pixFmt" json:"pixFmt"`
+	VideoProfile      string    `db:"videoProfile" json:"videoProfile"`
+	VideoLevel        int       `db:"videoLevel" json:"videoLevel"`
+	ColorTransfer     string    `db:"colorTransfer" json:"colorTransfer"`
+	ColorPrimaries    string    `db:"colorPrimaries" json:"colorPrimaries"`
+	AudioChannels     int       `db:"audioChannels" json:"audioChannels"`
+	AudioLanguages    string    `db:"audioLanguages" json:"audioLanguages"`
+	SubtitleLanguages string    `db:"subtitleLanguages" json:"subtitleLanguages"`
+	VideoStreams      int       `db:"videoStreams" json:"videoStreams"`
+	AudioStreams      int       `db:"audioStreams" json:"audioStreams"`
+	SubtitleTracks    int       `db:"subtitleTracks" json:"subtitleTracks"`
+
+	// VideoStreamIndex and AudioStreamIndex are the container indexes of the
+	// main streams, -1 when there is none or the video was probed before
+	// they were recorded
+	VideoStreamIndex int           `db:"videoStreamIndex" json:"videoStreamIndex"`
+	AudioStreamIndex int           `db:"audioStreamIndex" json:"audioStreamIndex"`
+	Streams          []VideoStream `db:"-" json:"streams"`
+
 	FKVideoVideohash int64 `db:"FK_video_videohash" json:"FK_video_videohash"`
 }
 
+// CopyProbeInfo copies what ffprobe found out about src, for another link
+// to the same file.
+func (v *Video) CopyProbeInfo(src *Video) {
+	v.Size = src.Size
+	v.Duration = src.Duration
+	v.BitRate = src.BitRate
+	v.VideoCodec = src.VideoCodec
+	v.AudioCodec = src.AudioCodec
+	v.Width = src.Width
+	v.Height = src.Height
+	v.SampleRateAvg = src.SampleRateAvg
+	v.AvgFrameRate = src.AvgFrameRate
+	v.Container = src.Container
+	v.MediaCreatedAt = src.MediaCreatedAt
+	v.Rotation = src.Rotation
+	v.PixFmt = src.PixFmt
+	v.VideoProfile = src.VideoProfile
+	v.VideoLevel = src.VideoLevel
+	v.ColorTransfer = src.ColorTransfer
+	v.ColorPrimaries = src.ColorPrimaries
+	v.AudioChannels = src.AudioChannels
+	v.AudioLanguages = src.AudioLanguages
+	v.SubtitleLanguages = src.SubtitleLanguages
+	v.VideoStreams = src.VideoStreams
+	v.AudioStreams = src.AudioStreams
+	v.SubtitleTracks = src.SubtitleTracks
+	v.VideoStreamIndex = src.VideoStreamIndex
+	v.AudioStreamIndex = src.AudioStreamIndex
+	v.Streams = src.Streams
+}
+
+// IsHDR reports whether the main video stream uses a PQ or HLG transfer.
+func (v *Video) IsHDR() bool {
+	return v.ColorTransfer == "smpte2084" || v.ColorTransfer == "arib-std-b67"
+}
+
 func (v Video) String() string {
 	return fmt.Sprintf(
 		`ID: %d, XXHash: %s, Path: %s, FileName: %s, CreatedAt: %s, ModifiedAt: %s, 
@@ -4,4 +4,7 @@ type VideoData struct {
 	Video      Video       `db:"video"`
 	Videohash  Videohash   `db:"videohash"`
 	Screenshot Screenshots `db:"screenshot"`
+	// Relations describes temporal matches with other members of the group,
+	// e.g. "contained in b.mp4 at 00:12:03–00:15:40"
+	Relations []string `db:"-" json:"relations,omitempty"`
 }
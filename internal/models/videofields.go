@@ -0,0 +1,277 @@
+package models
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldKind says how a named video field is compared.
+type FieldKind int
+
+const (
+	FieldNumeric FieldKind = iota
+	FieldText
+)
+
+// fieldKinds lists every field that can be referenced by name from the
+// selection rules and the duplicates filter.
+var fieldKinds = map[string]FieldKind{
+	"size":       FieldNumeric,
+	"bitrate":    FieldNumeric,
+	"width":      FieldNumeric,
+	"height":     FieldNumeric,
+	"resolution": FieldNumeric, // width * height
+	"res":        FieldNumeric, // shorter side, e.g. 1080 for 1920x1080
+	"duration":   FieldNumeric,
+	"fps":        FieldNumeric,
+	"samplerate": FieldNumeric,
+	"modified":   FieldNumeric,
+	"created":    FieldNumeric,
+	"links":      FieldNumeric,
+	"symlink":    FieldNumeric,
+	"hardlink":   FieldNumeric,
+	"rotation":   FieldNumeric,
+	"level":      FieldNumeric,
+	"hdr":        FieldNumeric,
+	"channels":   FieldNumeric,
+	"subs":       FieldNumeric, // subtitle tracks
+	"vstreams":   FieldNumeric,
+	"astreams":   FieldNumeric,
+	"recorded":   FieldNumeric, // creation_time tag of the container
+	"path":       FieldText,
+	"name":       FieldText,
+	"dir":        FieldText,
+	"ext":        FieldText,
+	"codec":      FieldText,
+	"acodec":     FieldText,
+	"container":  FieldText,
+	"pixfmt":     FieldText,
+	"profile":    FieldText,
+	"transfer":   FieldText,
+	"primaries":  FieldText,
+	"alang":      FieldText, // comma separated audio languages
+	"slang":      FieldText, // comma separated subtitle languages
+}
+
+// fieldAliases maps alternative spellings onto the canonical field name.
+var fieldAliases = map[string]string{
+	"filename":    "name",
+	"file":        "name",
+	"vcodec":      "codec",
+	"videocodec":  "codec",
+	"audiocodec":  "acodec",
+	"framerate":   "fps",
+	"pixels":      "resolution",
+	"modifiedat":  "modified",
+	"mtime":       "modified",
+	"createdat":   "created",
+	"hardlinks":   "links",
+	"numhardlink": "links",
+	"len":         "duration",
+	"length":      "duration",
+	"rotate":      "rotation",
+	"subtitles":   "subs",
+	"format":      "container",
+	"pix_fmt":     "pixfmt",
+	"audiolang":   "alang",
+	"sublang":     "slang",
+}
+
+// LookupField returns the canonical name and kind of a field.
+func LookupField(name string) (string, FieldKind, bool) {
+	name = strings.ToLower(name)
+	if alias, ok := fieldAliases[name]; ok {
+		name = alias
+	}
+	kind, ok := fieldKinds[name]
+	return name, kind, ok
+}
+
+// NumericField returns the value of a numeric field. Sizes are in bytes,
+// durations in seconds and times as unix seconds.
+func (vd *VideoData) NumericField(name string) float64 {
+	v := &vd.Video
+	switch name {
+	case "size":
+		return float64(v.Size)
+	case "bitrate":
+		return float64(v.BitRate)
+	case "width":
+		return float64(v.Width)
+	case "height":
+		return float64(v.Height)
+	case "resolution":
+		return float64(v.Width * v.Height)
+	case "res":
+		return float64(min(v.Width, v.Height))
+	case "duration":
+		return float64(v.Duration)
+	case "fps":
+		return float64(v.AvgFrameRate)
+	case "samplerate":
+		return float64(v.SampleRateAvg)
+	case "modified":
+		return float64(v.ModifiedAt.Unix())
+	case "created":
+		return float64(v.CreatedAt.Unix())
+	case "links":
+		return float64(v.NumHardLinks)
+	case "symlink":
+		return boolToFloat(v.IsSymbolicLink)
+	case "hardlink":
+		return boolToFloat(v.IsHardLink)
+	case "rotation":
+		return float64(v.Rotation)
+	case "level":
+		return float64(v.VideoLevel)
+	case "hdr":
+		return boolToFloat(v.IsHDR())
+	case "channels":
+		return float64(v.AudioChannels)
+	case "subs":
+		return float64(v.SubtitleTracks)
+	case "vstreams":
+		return float64(v.VideoStreams)
+	case "astreams":
+		return float64(v.AudioStreams)
+	case "recorded":
+		return float64(v.MediaCreatedAt.Unix())
+	}
+	return 0
+}
+
+// TextField returns the lower-cased value of a text field.
+func (vd *VideoData) TextField(name string) string {
+	v := &vd.Video
+	var s string
+	switch name {
+	case "path":
+		s = v.Path
+	case "name":
+		s = v.FileName
+	case "dir":
+		s = filepath.Dir(v.Path)
+	case "ext":
+		s = strings.TrimPrefix(filepath.Ext(v.Path), ".")
+	case "codec":
+		s = v.VideoCodec
+	case "acodec":
+		s = v.AudioCodec
+	case "container":
+		s = v.Container
+	case "pixfmt":
+		s = v.PixFmt
+	case "profile":
+		s = v.VideoProfile
+	case "transfer":
+		s = v.ColorTransfer
+	case "primaries":
+		s = v.ColorPrimaries
+	case "alang":
+		s = v.AudioLanguages
+	case "slang":
+		s = v.SubtitleLanguages
+	}
+	return strings.ToLower(s)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ParseNumericValue parses the right hand side of a numeric comparison.
+// Sizes accept KB/MB/GB/TB suffixes (binary units), bitrates accept
+// k/m/g or kbps/mbps/gbps (decimal units, 8m is 8,000,000 b/s), durations
+// accept s/m/h suffixes or hh:mm:ss, resolutions accept a trailing "p"
+// (1080p), booleans accept true/false and times accept YYYY-MM-DD.
+func ParseNumericValue(field, s string) (float64, error) {
+	raw := strings.ToLower(strings.TrimSpace(s))
+	if raw == "" {
+		return 0, fmt.Errorf("missing value for %q", field)
+	}
+
+	switch field {
+	case "size":
+		return parseWithSuffix(raw, []unitSuffix{
+			{"tb", 1 << 40}, {"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10},
+			{"t", 1 << 40}, {"g", 1 << 30}, {"m", 1 << 20}, {"k", 1 << 10}, {"b", 1},
+		})
+	case "bitrate":
+		return parseWithSuffix(raw, []unitSuffix{
+			{"gbps", 1e9}, {"mbps", 1e6}, {"kbps", 1e3}, {"bps", 1},
+			{"g", 1e9}, {"m", 1e6}, {"k", 1e3},
+		})
+	case "duration":
+		if strings.Contains(raw, ":") {
+			d, err := parseClock(raw)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+			}
+			return d, nil
+		}
+		return parseWithSuffix(raw, []unitSuffix{{"h", 3600}, {"m", 60}, {"s", 1}})
+	case "res", "width", "height":
+		return parseWithSuffix(raw, []unitSuffix{{"p", 1}})
+	case "symlink", "hardlink", "hdr":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return 0, fmt.Errorf("invalid boolean %q", s)
+		}
+		return boolToFloat(b), nil
+	case "modified", "created", "recorded":
+		if t, err := time.Parse("2006-01-02", raw); err == nil {
+			return float64(t.Unix()), nil
+		}
+	}
+
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q for %q", s, field)
+	}
+	return f, nil
+}
+
+type unitSuffix struct {
+	suffix string
+	factor float64
+}
+
+func parseWithSuffix(raw string, units []unitSuffix) (float64, error) {
+	for _, u := range units {
+		if numStr, ok := strings.CutSuffix(raw, u.suffix); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(numStr), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid number %q", raw)
+			}
+			return f * u.factor, nil
+		}
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", raw)
+	}
+	return f, nil
+}
+
+// parseClock parses "mm:ss" or "hh:mm:ss(.fff)" into seconds.
+func parseClock(raw string) (float64, error) {
+	parts := strings.Split(raw, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("too many ':' separators")
+	}
+	total := 0.0
+	for _, p := range parts {
+		f, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return 0, err
+		}
+		total = total*60 + f
+	}
+	return total, nil
+}
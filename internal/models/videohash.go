@@ -1,6 +1,8 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -11,8 +13,20 @@ type HashType string
 
 const (
 	HashTypePHash HashType = "phash"
+	// HashTypeSlow is a sequence of per-frame pHashes spread proportionally
+	// over the video between the intro and the outro.
+	HashTypeSlow HashType = "slowPhash"
+	// HashTypeTemporal is a sequence of per-frame pHashes, one every
+	// TemporalSampleInterval seconds from the start of the video.
+	HashTypeTemporal HashType = "temporalPhash"
+	// HashTypeScene is the set of pHashes of the first frame of each scene,
+	// compared regardless of order and position.
+	HashTypeScene HashType = "scenePhash"
 )
 
+// TemporalSampleInterval is the time in seconds between TemporalPhash frames.
+const TemporalSampleInterval = 1.0
+
 type Videohash struct {
 	ID         int64    `db:"id"`
 	HashType   HashType `db:"hashType"`
@@ -20,6 +34,104 @@ type Videohash struct {
 	Duration   float32  `db:"duration"`
 	Neighbours IntSlice `db:"neighbours"`
 	Bucket     int      `db:"bucket"`
+	// FrameHashes holds the per-frame hashes of SlowPhash and TemporalPhash
+	FrameHashes FrameHashes `db:"frameHashes"`
+	// AudioFingerprint is set when the match mode uses audio
+	AudioFingerprint AudioFingerprint `db:"audioFingerprint"`
+	// Signatures holds the hashes of the extra algorithms
+	Signatures []Signature `db:"-"`
+}
+
+// Kind returns the hash type, recognising rows written before the type was
+// stored per method: those SlowPhash rows are "pHash" with the frame hashes
+// concatenated in HashValue, FastPhash values start with "p:".
+func (vh *Videohash) Kind() HashType {
+	switch {
+	case vh.HashType == HashTypeSlow || vh.HashType == HashTypeTemporal || vh.HashType == HashTypeScene:
+		return vh.HashType
+	case len(vh.FrameHashes) > 0 || (vh.HashValue != "" && !strings.HasPrefix(vh.HashValue, "p:")):
+		return HashTypeSlow
+	default:
+		return HashTypePHash
+	}
+}
+
+// Frames returns the per-frame hashes, parsing the hex HashValue of rows
+// written before FrameHashes existed.
+func (vh *Videohash) Frames() []uint64 {
+	if len(vh.FrameHashes) > 0 {
+		return vh.FrameHashes
+	}
+	if vh.Kind() == HashTypePHash {
+		return nil
+	}
+	return ParseFrameHashes(vh.HashValue)
+}
+
+// IsBlank reports whether every frame is a solid colour.
+func (vh *Videohash) IsBlank() bool {
+	if vh.Kind() == HashTypePHash {
+		h := strings.TrimPrefix(vh.HashValue, "p:")
+		return strings.EqualFold(h, "8000000000000000") || strings.EqualFold(h, "0000000000000000")
+	}
+	for _, f := range vh.Frames() {
+		if !IsBlankFrame(f) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsBlankFrame reports whether h is the pHash of a solid colour frame.
+func IsBlankFrame(h uint64) bool {
+	return h == 0 || h == 0x8000000000000000
+}
+
+// ParseFrameHashes splits concatenated 16 digit hex hashes. Frames that don't
+// parse are returned as zero, which counts as blank.
+func ParseFrameHashes(value string) []uint64 {
+	frames := make([]uint64, len(value)/16)
+	for i := range frames {
+		h, err := strconv.ParseUint(value[i*16:i*16+16], 16, 64)
+		if err == nil {
+			frames[i] = h
+		}
+	}
+	return frames
+}
+
+// FrameHashes is stored as a BLOB of little-endian uint64s.
+type FrameHashes []uint64
+
+func (fh *FrameHashes) Scan(value any) error {
+	if value == nil {
+		*fh = nil
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("unsupported type for frame hashes: %T", value)
+	}
+	if len(b)%8 != 0 {
+		return fmt.Errorf("frame hashes blob has %d bytes, not a multiple of 8", len(b))
+	}
+	hashes := make(FrameHashes, len(b)/8)
+	for i := range hashes {
+		hashes[i] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+	*fh = hashes
+	return nil
+}
+
+func (fh FrameHashes) Value() (driver.Value, error) {
+	if len(fh) == 0 {
+		return nil, nil
+	}
+	b := make([]byte, 0, len(fh)*8)
+	for _, h := range fh {
+		b = binary.LittleEndian.AppendUint64(b, h)
+	}
+	return b, nil
 }
 
 // Metadata    Metadata `db:"metadata"`
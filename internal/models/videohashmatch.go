@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"math"
+)
+
+type MatchType string
+
+const (
+	// MatchDuplicate means both videos are covered almost completely.
+	MatchDuplicate MatchType = "duplicate"
+	// MatchContained means A is contained in B.
+	MatchContained MatchType = "contained"
+	// MatchOverlap means only part of each video matches, e.g. both are trimmed.
+	MatchOverlap MatchType = "overlap"
+)
+
+// VideohashMatch is an aligned section shared by two temporal hashes. Times
+// are in seconds, B's times are StartA*Speed+offset. Matches of flipped or
+// rotated copies only set Transform: B is A transformed by it.
+type VideohashMatch struct {
+	ID         int64     `db:"id" json:"id"`
+	FKMatchA   int64     `db:"FK_match_a" json:"a"`
+	FKMatchB   int64     `db:"FK_match_b" json:"b"`
+	MatchType  MatchType `db:"matchType" json:"matchType"`
+	StartA     float64   `db:"startA" json:"startA"`
+	EndA       float64   `db:"endA" json:"endA"`
+	StartB     float64   `db:"startB" json:"startB"`
+	EndB       float64   `db:"endB" json:"endB"`
+	Speed      float64   `db:"speed" json:"speed"`
+	Similarity float64   `db:"similarity" json:"similarity"`
+	Transform  Transform `db:"transform" json:"transform,omitempty"`
+}
+
+// Describe explains the match from the point of view of the videohash self,
+// naming the other video other.
+func (m *VideohashMatch) Describe(self int64, other string) string {
+	if m.Transform != TransformNone {
+		// self is other transformed by the inverse when self is A
+		t := m.Transform
+		if self == m.FKMatchA {
+			t = t.Inverse()
+		}
+		return fmt.Sprintf("same as %s, %s", other, t.Describe())
+	}
+
+	var s string
+	switch {
+	case m.MatchType == MatchDuplicate && math.Abs(m.StartA-m.StartB) < 2:
+		s = fmt.Sprintf("same as %s", other)
+	case m.MatchType == MatchDuplicate && self == m.FKMatchA:
+		s = fmt.Sprintf("same as %s at %s–%s", other, FormatClock(m.StartB), FormatClock(m.EndB))
+	case m.MatchType == MatchDuplicate:
+		s = fmt.Sprintf("same as %s at %s–%s", other, FormatClock(m.StartA), FormatClock(m.EndA))
+	case m.MatchType == MatchContained && self == m.FKMatchA:
+		s = fmt.Sprintf("contained in %s at %s–%s", other, FormatClock(m.StartB), FormatClock(m.EndB))
+	case m.MatchType == MatchContained:
+		s = fmt.Sprintf("contains %s at %s–%s", other, FormatClock(m.StartB), FormatClock(m.EndB))
+	case self == m.FKMatchA:
+		s = fmt.Sprintf("%s–%s overlaps %s at %s–%s",
+			FormatClock(m.StartA), FormatClock(m.EndA), other, FormatClock(m.StartB), FormatClock(m.EndB))
+	default:
+		s = fmt.Sprintf("%s–%s overlaps %s at %s–%s",
+			FormatClock(m.StartB), FormatClock(m.EndB), other, FormatClock(m.StartA), FormatClock(m.EndA))
+	}
+	if m.Speed < 0.99 || m.Speed > 1.01 {
+		s += fmt.Sprintf(" (speed %.2fx)", m.Speed)
+	}
+	return s
+}
+
+// FormatClock formats seconds as hh:mm:ss.
+func FormatClock(seconds float64) string {
+	t := int(seconds + 0.5)
+	if t < 0 {
+		t = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d", t/3600, t/60%60, t%60)
+}
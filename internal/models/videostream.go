@@ -0,0 +1,19 @@
+package models
+
+// VideoStream is one stream of a video's container as ffprobe reported it.
+type VideoStream struct {
+	FKStreamVideo int64  `db:"FK_stream_video" json:"-"`
+	Index         int    `db:"streamIndex" json:"index"`
+	CodecType     string `db:"codecType" json:"codecType"`
+	CodecName     string `db:"codecName" json:"codecName"`
+	Profile       string `db:"profile" json:"profile"`
+	Width         int    `db:"width" json:"width"`
+	Height        int    `db:"height" json:"height"`
+	PixFmt        string `db:"pixFmt" json:"pixFmt"`
+	BitRate       int    `db:"bitRate" json:"bitRate"`
+	SampleRate    int    `db:"sampleRate" json:"sampleRate"`
+	Channels      int    `db:"channels" json:"channels"`
+	Language      string `db:"language" json:"language"`
+	IsDefault     bool   `db:"isDefault" json:"isDefault"`
+	AttachedPic   bool   `db:"attachedPic" json:"attachedPic"`
+}
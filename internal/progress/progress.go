@@ -0,0 +1,231 @@
+// Package progress counts what each stage of a search has done, for the
+// search dialog and the command line.
+package progress
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stage is a step of a search.
+type Stage string
+
+const (
+	Walk  Stage = "walk"
+	Probe Stage = "probe"
+	Hash  Stage = "hash"
+	Match Stage = "match"
+	Write Stage = "write"
+)
+
+// Stages are the stages in the order a search runs them.
+var Stages = []Stage{Walk, Probe, Hash, Match, Write}
+
+// Tracker is safe for concurrent use. The zero value isn't usable, see
+// NewTracker.
+type Tracker struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	stages map[Stage]*stage
+}
+
+type stage struct {
+	total, done, failed   int
+	totalBytes, doneBytes int64
+	started, finished     time.Time
+	// active counts the workers on each path
+	active map[string]int
+}
+
+// StageProgress is a snapshot of one stage. Total is 0 while it isn't
+// known, e.g. during the walk.
+type StageProgress struct {
+	Stage                 Stage
+	Total, Done, Failed   int
+	TotalBytes, DoneBytes int64
+	Elapsed               time.Duration
+	FilesPerSec           float64
+	BytesPerSec           float64
+	// ETA is 0 when it can't be estimated yet
+	ETA      time.Duration
+	Finished bool
+	// Active are the files the workers are on
+	Active []string
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{now: time.Now, stages: make(map[Stage]*stage)}
+}
+
+// get returns s, starting its clock on first use. t.mu must be held.
+func (t *Tracker) get(s Stage) *stage {
+	st, ok := t.stages[s]
+	if !ok {
+		st = &stage{started: t.now(), active: make(map[string]int)}
+		t.stages[s] = st
+	}
+	return st
+}
+
+// AddTotal adds files and bytes to what s has to do.
+func (t *Tracker) AddTotal(s Stage, files int, bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.get(s)
+	st.total += files
+	st.totalBytes += bytes
+}
+
+// Begin marks path as being worked on in s.
+func (t *Tracker) Begin(s Stage, path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.get(s).active[path]++
+}
+
+// Done counts path as done in s, Fail as failed. bytes is the file's size.
+func (t *Tracker) Done(s Stage, path string, bytes int64) {
+	t.end(s, path, bytes, false)
+}
+
+func (t *Tracker) Fail(s Stage, path string, bytes int64) {
+	t.end(s, path, bytes, true)
+}
+
+func (t *Tracker) end(s Stage, path string, bytes int64, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.get(s)
+	if n := st.active[path]; n > 1 {
+		st.active[path] = n - 1
+	} else {
+		delete(st.active, path)
+	}
+	if failed {
+		st.failed++
+	} else {
+		st.done++
+	}
+	st.doneBytes += bytes
+}
+
+// Advance counts n files of s as done without tracking them as active.
+func (t *Tracker) Advance(s Stage, n int, bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.get(s)
+	st.done += n
+	st.doneBytes += bytes
+}
+
+// Finish stops the clock of s.
+func (t *Tracker) Finish(s Stage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.get(s)
+	if st.finished.IsZero() {
+		st.finished = t.now()
+	}
+}
+
+// Snapshot returns the stages that have started, in order.
+func (t *Tracker) Snapshot() []StageProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var snap []StageProgress
+	for _, s := range Stages {
+		if st, ok := t.stages[s]; ok {
+			snap = append(snap, t.snapshot(s, st))
+		}
+	}
+	return snap
+}
+
+// Stage returns the snapshot of s, empty when it hasn't started.
+func (t *Tracker) Stage(s Stage) StageProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.stages[s]
+	if !ok {
+		return StageProgress{Stage: s}
+	}
+	return t.snapshot(s, st)
+}
+
+func (t *Tracker) snapshot(s Stage, st *stage) StageProgress {
+	end := st.finished
+	if end.IsZero() {
+		end = t.now()
+	}
+	p := StageProgress{
+		Stage:      s,
+		Total:      st.total,
+		Done:       st.done,
+		Failed:     st.failed,
+		TotalBytes: st.totalBytes,
+		DoneBytes:  st.doneBytes,
+		Elapsed:    end.Sub(st.started),
+		Finished:   !st.finished.IsZero(),
+	}
+	for path := range st.active {
+		p.Active = append(p.Active, path)
+	}
+	slices.Sort(p.Active)
+
+	secs := p.Elapsed.Seconds()
+	if secs <= 0 {
+		return p
+	}
+	p.FilesPerSec = float64(p.Done+p.Failed) / secs
+	p.BytesPerSec = float64(p.DoneBytes) / secs
+	if p.Finished {
+		return p
+	}
+	// sizes vary a lot between videos, bytes estimate better than files
+	if p.TotalBytes > 0 && p.BytesPerSec > 0 {
+		p.ETA = time.Duration(float64(max(0, p.TotalBytes-p.DoneBytes)) / p.BytesPerSec * float64(time.Second))
+	} else if p.Total > 0 && p.FilesPerSec > 0 {
+		p.ETA = time.Duration(float64(max(0, p.Total-p.Done-p.Failed)) / p.FilesPerSec * float64(time.Second))
+	}
+	return p
+}
+
+// Fraction is how much of the stage is done, from 0 to 1.
+func (p StageProgress) Fraction() float64 {
+	if p.Finished {
+		return 1
+	}
+	if p.Total == 0 {
+		return 0
+	}
+	return min(1, float64(p.Done+p.Failed)/float64(p.Total))
+}
+
+// String formats p on one line, e.g.
+// "hash 120/3412 (2 failed), 1.4 files/s, 35.1 MB/s, ETA 39m12s".
+func (p StageProgress) String() string {
+	var b strings.Builder
+	b.WriteString(string(p.Stage))
+	b.WriteString(" ")
+	b.WriteString(fmt.Sprint(p.Done + p.Failed))
+	if p.Total > 0 {
+		fmt.Fprintf(&b, "/%d", p.Total)
+	}
+	if p.Failed > 0 {
+		fmt.Fprintf(&b, " (%d failed)", p.Failed)
+	}
+	fmt.Fprintf(&b, ", %.1f files/s", p.FilesPerSec)
+	if p.BytesPerSec > 0 {
+		fmt.Fprintf(&b, ", %.1f MB/s", p.BytesPerSec/(1<<20))
+	}
+	switch {
+	case p.Finished:
+		fmt.Fprintf(&b, ", done in %s", p.Elapsed.Round(time.Second))
+	case p.ETA > 0:
+		fmt.Fprintf(&b, ", ETA %s", p.ETA.Round(time.Second))
+	}
+	return b.String()
+}
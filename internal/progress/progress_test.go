@@ -0,0 +1,41 @@
+package progress
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrackerETA(t *testing.T) {
+	now := time.Unix(0, 0)
+	tr := NewTracker()
+	tr.now = func() time.Time { return now }
+
+	tr.AddTotal(Hash, 4, 400<<20)
+	tr.Begin(Hash, "a.mp4")
+	tr.Begin(Hash, "b.mp4")
+	now = now.Add(10 * time.Second)
+	tr.Done(Hash, "a.mp4", 100<<20)
+
+	st := tr.Stage(Hash)
+	if st.Done != 1 || st.Total != 4 || len(st.Active) != 1 || st.Active[0] != "b.mp4" {
+		t.Fatalf("Stage(Hash) = %+v, want 1/4 done and b.mp4 active", st)
+	}
+	// 100 MB in 10s leaves 300 MB for 30s
+	if st.ETA != 30*time.Second {
+		t.Errorf("ETA = %s, want 30s", st.ETA)
+	}
+	if st.Fraction() != 0.25 {
+		t.Errorf("Fraction = %f, want 0.25", st.Fraction())
+	}
+
+	tr.Fail(Hash, "b.mp4", 100<<20)
+	tr.Finish(Hash)
+	now = now.Add(time.Hour)
+	st = tr.Stage(Hash)
+	if st.Failed != 1 || st.ETA != 0 || st.Elapsed != 10*time.Second || st.Fraction() != 1 {
+		t.Errorf("finished Stage(Hash) = %+v, want 1 failed, no ETA and the clock stopped at 10s", st)
+	}
+	if got, want := st.String(), "hash 2/4 (1 failed), 0.2 files/s, 20.0 MB/s, done in 10s"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
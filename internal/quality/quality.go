@@ -0,0 +1,140 @@
+// Package quality scores the members of a duplicate group so the best copy
+// can be kept. Scores are in [0, 1] and only meaningful within one group.
+package quality
+
+import (
+	"strings"
+
+	"govdupes/internal/config"
+	"govdupes/internal/models"
+)
+
+// Weights sets how much each component contributes to the score.
+type Weights struct {
+	Codec      float64
+	Resolution float64
+	Bitrate    float64
+	FrameRate  float64
+	Duration   float64
+	Audio      float64
+}
+
+func WeightsFromConfig(c *config.Config) Weights {
+	return Weights{
+		Codec:      c.QualityCodecWeight,
+		Resolution: c.QualityResolutionWeight,
+		Bitrate:    c.QualityBitrateWeight,
+		FrameRate:  c.QualityFrameRateWeight,
+		Duration:   c.QualityDurationWeight,
+		Audio:      c.QualityAudioWeight,
+	}
+}
+
+// codecEfficiency is roughly how many H.264 bits one bit of each codec is
+// worth at the same visual quality.
+var codecEfficiency = map[string]float64{
+	"av1":        2.0,
+	"hevc":       1.6,
+	"vp9":        1.5,
+	"h264":       1.0,
+	"vp8":        0.9,
+	"wmv3":       0.7,
+	"vc1":        0.8,
+	"mpeg4":      0.7,
+	"msmpeg4v3":  0.6,
+	"flv1":       0.5,
+	"h263":       0.5,
+	"mpeg2video": 0.5,
+	"mpeg1video": 0.4,
+}
+
+// audioQuality scores audio codecs, lossless being 1.
+var audioQuality = map[string]float64{
+	"flac":      1.0,
+	"alac":      1.0,
+	"truehd":    1.0,
+	"pcm_s16le": 1.0,
+	"pcm_s24le": 1.0,
+	"dts":       0.9,
+	"eac3":      0.85,
+	"opus":      0.85,
+	"ac3":       0.8,
+	"aac":       0.75,
+	"vorbis":    0.75,
+	"mp3":       0.65,
+	"wmav2":     0.5,
+	"mp2":       0.5,
+}
+
+// targetBitsPerPixel is the bits per pixel per frame above which extra
+// bitrate no longer counts as extra quality.
+const targetBitsPerPixel = 0.1
+
+const maxFrameRate = 60
+
+func CodecEfficiency(codec string) float64 {
+	if e, ok := codecEfficiency[strings.ToLower(codec)]; ok {
+		return e
+	}
+	return 1.0
+}
+
+func audioScore(codec string) float64 {
+	if codec == "" {
+		return 0
+	}
+	if q, ok := audioQuality[strings.ToLower(codec)]; ok {
+		return q
+	}
+	return 0.6
+}
+
+// bitsPerPixel is the bitrate spread over every pixel of every frame, in
+// H.264 bits so an efficient codec needs fewer of them to reach the target.
+func bitsPerPixel(v *models.Video) float64 {
+	pixels := float64(v.Width * v.Height)
+	fps := float64(v.AvgFrameRate)
+	if pixels <= 0 || fps <= 0 || v.BitRate <= 0 {
+		return 0
+	}
+	return float64(v.BitRate) * CodecEfficiency(v.VideoCodec) / (pixels * fps)
+}
+
+// ScoreGroup returns a score for every member of group. Resolution, frame
+// rate and duration are relative to the best member of the group, codec,
+// bitrate and audio are absolute.
+func ScoreGroup(group []*models.VideoData, w Weights) []float64 {
+	var maxPixels, maxFPS, maxDuration, maxCodec float64
+	for _, vd := range group {
+		v := &vd.Video
+		maxPixels = max(maxPixels, float64(v.Width*v.Height))
+		maxFPS = max(maxFPS, min(float64(v.AvgFrameRate), maxFrameRate))
+		maxDuration = max(maxDuration, float64(v.Duration))
+		maxCodec = max(maxCodec, CodecEfficiency(v.VideoCodec))
+	}
+
+	total := w.Codec + w.Resolution + w.Bitrate + w.FrameRate + w.Duration + w.Audio
+	scores := make([]float64, len(group))
+	if total <= 0 {
+		return scores
+	}
+
+	for i, vd := range group {
+		v := &vd.Video
+		score := w.Codec*ratio(CodecEfficiency(v.VideoCodec), maxCodec) +
+			w.Resolution*ratio(float64(v.Width*v.Height), maxPixels) +
+			w.Bitrate*min(bitsPerPixel(v)/targetBitsPerPixel, 1) +
+			w.FrameRate*ratio(min(float64(v.AvgFrameRate), maxFrameRate), maxFPS) +
+			w.Duration*ratio(float64(v.Duration), maxDuration) +
+			w.Audio*audioScore(v.AudioCodec)
+		scores[i] = score / total
+	}
+	return scores
+}
+
+func ratio(v, best float64) float64 {
+	if best <= 0 {
+		return 0
+	}
+	return v / best
+}
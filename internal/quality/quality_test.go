@@ -0,0 +1,106 @@
+package quality
+
+import (
+	"math"
+	"testing"
+
+	"govdupes/internal/models"
+)
+
+func video(codec string, width, height, bitrate int) *models.VideoData {
+	return &models.VideoData{Video: models.Video{
+		VideoCodec:   codec,
+		AudioCodec:   "aac",
+		Width:        width,
+		Height:       height,
+		BitRate:      bitrate,
+		AvgFrameRate: 30,
+		Duration:     600,
+	}}
+}
+
+var defaultWeights = Weights{Codec: 1, Resolution: 3, Bitrate: 2, FrameRate: 1, Duration: 2, Audio: 0.5}
+
+func TestScoreGroupRanking(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *models.VideoData
+		// want is "a", "b" or "tie"
+		want string
+	}{
+		{
+			name: "resolution beats a lower resolution at the same bitrate",
+			a:    video("h264", 1920, 1080, 8_000_000),
+			b:    video("h264", 1280, 720, 8_000_000),
+			want: "a",
+		},
+		{
+			name: "bitrate beats a starved copy at the same resolution",
+			a:    video("h264", 1920, 1080, 2_000_000),
+			b:    video("h264", 1920, 1080, 8_000_000),
+			want: "b",
+		},
+		{
+			name: "codec decides between otherwise equal copies",
+			a:    video("h264", 1920, 1080, 4_000_000),
+			b:    video("hevc", 1920, 1080, 4_000_000),
+			want: "b",
+		},
+		{
+			name: "resolution outweighs a better codec",
+			a:    video("hevc", 1280, 720, 4_000_000),
+			b:    video("h264", 1920, 1080, 8_000_000),
+			want: "b",
+		},
+		{
+			name: "hevc at half the bitrate beats a bloated h264 copy",
+			a:    video("h264", 1920, 1080, 8_000_000),
+			b:    video("hevc", 1920, 1080, 4_000_000),
+			want: "b",
+		},
+		{
+			name: "identical copies tie",
+			a:    video("h264", 1920, 1080, 4_000_000),
+			b:    video("h264", 1920, 1080, 4_000_000),
+			want: "tie",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores := ScoreGroup([]*models.VideoData{tt.a, tt.b}, defaultWeights)
+			var got string
+			switch {
+			case scores[0] == scores[1]:
+				got = "tie"
+			case scores[0] > scores[1]:
+				got = "a"
+			default:
+				got = "b"
+			}
+			if got != tt.want {
+				t.Errorf("ScoreGroup() = %v, want %s to win", scores, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreGroupCountsCodecOnce(t *testing.T) {
+	// a high bitrate puts the bitrate term at its cap for both
+	h264 := video("h264", 1920, 1080, 8_000_000)
+	hevc := video("hevc", 1920, 1080, 8_000_000)
+	scores := ScoreGroup([]*models.VideoData{h264, hevc}, defaultWeights)
+
+	total := 9.5
+	want := defaultWeights.Codec * (1 - CodecEfficiency("h264")/CodecEfficiency("hevc")) / total
+	if diff := scores[1] - scores[0]; math.Abs(diff-want) > 1e-9 {
+		t.Errorf("hevc scores %f more than h264, want only the codec term %f", diff, want)
+	}
+}
+
+func TestScoreGroupZeroWeights(t *testing.T) {
+	scores := ScoreGroup([]*models.VideoData{video("h264", 1920, 1080, 8_000_000)}, Weights{})
+	if scores[0] != 0 {
+		t.Errorf("ScoreGroup() with zero weights = %v, want 0", scores)
+	}
+}
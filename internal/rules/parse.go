@@ -0,0 +1,248 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"govdupes/internal/models"
+)
+
+/*
+Grammar (keywords are case-insensitive):
+
+	rule      := stmt { ';' stmt }
+	stmt      := 'prefer' criterion { ',' criterion } | 'keep' INT
+	criterion := ['not'] FIELD ['asc' | 'desc']
+	           | ['not'] FIELD OP VALUE
+	OP        := '=' | '!=' | '<' | '<=' | '>' | '>=' |
+	             'contains' | 'startswith' | 'endswith' | 'matches'
+
+e.g. prefer resolution desc, bitrate desc, path contains "/keep/", codec = hevc; keep 1
+*/
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokString
+	tokOp
+	tokComma
+	tokSemicolon
+	tokEOF
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(s) {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == ',':
+			tokens = append(tokens, token{tokComma, ",", i})
+			i++
+		case ch == ';':
+			tokens = append(tokens, token{tokSemicolon, ";", i})
+			i++
+		case ch == '"' || ch == '\'':
+			end := strings.IndexByte(s[i+1:], ch)
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated string starting at %d", i)
+			}
+			tokens = append(tokens, token{tokString, s[i+1 : i+1+end], i})
+			i += end + 2
+		case ch == '=' || ch == '!' || ch == '<' || ch == '>':
+			op := string(ch)
+			if i+1 < len(s) && s[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected '!' at %d", i)
+			}
+			if op == "==" {
+				op = "="
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n\r,;\"'=!<>", rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokWord, s[start:i], start})
+		}
+	}
+	tokens = append(tokens, token{tokEOF, "", len(s)})
+	return tokens, nil
+}
+
+var wordOps = map[string]bool{
+	"contains":   true,
+	"startswith": true,
+	"endswith":   true,
+	"matches":    true,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokWord && strings.EqualFold(t.text, word)
+}
+
+// Parse compiles a rule expression.
+func Parse(expr string) (*Rule, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	r := &Rule{Expr: strings.TrimSpace(expr), Keep: 1}
+
+	for p.peek().kind != tokEOF {
+		if p.peek().kind == tokSemicolon {
+			p.next()
+			continue
+		}
+		switch {
+		case p.isKeyword("prefer"):
+			p.next()
+			for {
+				c, err := p.parseCriterion()
+				if err != nil {
+					return nil, err
+				}
+				r.Prefer = append(r.Prefer, c)
+				if p.peek().kind != tokComma {
+					break
+				}
+				p.next()
+			}
+		case p.isKeyword("keep"):
+			p.next()
+			t := p.next()
+			n, err := strconv.Atoi(t.text)
+			if t.kind != tokWord || err != nil {
+				return nil, fmt.Errorf("keep expects a number, got %q at %d", t.text, t.pos)
+			}
+			if n < 1 {
+				return nil, fmt.Errorf("keep must be at least 1, got %d", n)
+			}
+			r.Keep = n
+		default:
+			t := p.peek()
+			return nil, fmt.Errorf("expected 'prefer' or 'keep', got %q at %d", t.text, t.pos)
+		}
+
+		if t := p.peek(); t.kind != tokSemicolon && t.kind != tokEOF {
+			return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+		}
+	}
+
+	if len(r.Prefer) == 0 {
+		return nil, fmt.Errorf("rule has no 'prefer' criteria")
+	}
+	return r, nil
+}
+
+func (p *parser) parseCriterion() (Criterion, error) {
+	var c Criterion
+	if p.isKeyword("not") {
+		p.next()
+		c.Negate = true
+	}
+
+	t := p.next()
+	if t.kind != tokWord {
+		return c, fmt.Errorf("expected a field name, got %q at %d", t.text, t.pos)
+	}
+	field, kind, ok := models.LookupField(t.text)
+	if !ok {
+		return c, fmt.Errorf("unknown field %q at %d", t.text, t.pos)
+	}
+	c.Field = field
+	c.Kind = kind
+
+	// ordering criterion: FIELD [asc|desc]
+	next := p.peek()
+	isOp := next.kind == tokOp || (next.kind == tokWord && wordOps[strings.ToLower(next.text)])
+	if !isOp {
+		c.Desc = true
+		if p.isKeyword("asc") {
+			p.next()
+			c.Desc = false
+		} else if p.isKeyword("desc") {
+			p.next()
+		}
+		if c.Negate {
+			return c, fmt.Errorf("'not' can only be used with a comparison (field %q)", c.Field)
+		}
+		return c, nil
+	}
+
+	// boolean criterion: FIELD OP VALUE
+	c.Op = strings.ToLower(p.next().text)
+	v := p.next()
+	if v.kind != tokWord && v.kind != tokString {
+		return c, fmt.Errorf("expected a value after %q at %d", c.Op, v.pos)
+	}
+	c.Value = v.text
+
+	switch c.Kind {
+	case models.FieldNumeric:
+		if wordOps[c.Op] {
+			return c, fmt.Errorf("operator %q needs a text field, %q is numeric", c.Op, c.Field)
+		}
+		num, err := models.ParseNumericValue(c.Field, c.Value)
+		if err != nil {
+			return c, err
+		}
+		c.num = num
+	case models.FieldText:
+		switch c.Op {
+		case "<", "<=", ">", ">=":
+			return c, fmt.Errorf("operator %q needs a numeric field, %q is text", c.Op, c.Field)
+		case "matches":
+			re, err := regexp.Compile("(?i)" + c.Value)
+			if err != nil {
+				return c, fmt.Errorf("invalid regular expression %q: %w", c.Value, err)
+			}
+			c.re = re
+		}
+		c.Value = strings.ToLower(c.Value)
+	}
+	return c, nil
+}
+
+// isIdent reports whether s can be printed back without quotes.
+func isIdent(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != '_' {
+			return false
+		}
+	}
+	return s != ""
+}
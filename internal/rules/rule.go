@@ -0,0 +1,209 @@
+// Package rules implements the auto-selection rule language. A rule is an
+// ordered list of preferences that ranks the members of a duplicate group;
+// the best Keep members are kept and every other member is selected. The
+// package also parses the search queries of the duplicates filter.
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"govdupes/internal/models"
+)
+
+// Criterion is a single preference. Ordering criteria (Op == "") rank by
+// the field value, boolean criteria rank members that satisfy them first.
+type Criterion struct {
+	Field  string
+	Kind   models.FieldKind
+	Desc   bool
+	Op     string
+	Value  string
+	Negate bool
+
+	num float64
+	re  *regexp.Regexp
+}
+
+type Rule struct {
+	Expr   string
+	Prefer []Criterion
+	Keep   int
+}
+
+// Preview summarises what a rule would do to a set of groups.
+type Preview struct {
+	Groups        int
+	Kept          int
+	Selected      int
+	SelectedBytes int64
+}
+
+func (p Preview) String() string {
+	return fmt.Sprintf("%d groups: keeping %d videos, selecting %d videos (%s)",
+		p.Groups, p.Kept, p.Selected, formatBytes(p.SelectedBytes))
+}
+
+// Matches reports whether vd satisfies a boolean criterion.
+func (c *Criterion) Matches(vd *models.VideoData) bool {
+	var ok bool
+	if c.Kind == models.FieldNumeric {
+		v := vd.NumericField(c.Field)
+		switch c.Op {
+		case "=":
+			ok = v == c.num
+		case "!=":
+			ok = v != c.num
+		case "<":
+			ok = v < c.num
+		case "<=":
+			ok = v <= c.num
+		case ">":
+			ok = v > c.num
+		case ">=":
+			ok = v >= c.num
+		}
+	} else {
+		v := vd.TextField(c.Field)
+		switch c.Op {
+		case "=":
+			ok = v == c.Value
+		case "!=":
+			ok = v != c.Value
+		case "contains":
+			ok = strings.Contains(v, c.Value)
+		case "startswith":
+			ok = strings.HasPrefix(v, c.Value)
+		case "endswith":
+			ok = strings.HasSuffix(v, c.Value)
+		case "matches":
+			ok = c.re.MatchString(v)
+		}
+	}
+	if c.Negate {
+		return !ok
+	}
+	return ok
+}
+
+// compare returns <0 if a is preferred over b, >0 if b is preferred, 0 on a tie.
+func (c *Criterion) compare(a, b *models.VideoData) int {
+	if c.Op != "" {
+		ma, mb := c.Matches(a), c.Matches(b)
+		switch {
+		case ma == mb:
+			return 0
+		case ma:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	var cmp int
+	if c.Kind == models.FieldNumeric {
+		va, vb := a.NumericField(c.Field), b.NumericField(c.Field)
+		switch {
+		case va < vb:
+			cmp = -1
+		case va > vb:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(a.TextField(c.Field), b.TextField(c.Field))
+	}
+	if c.Desc {
+		return -cmp
+	}
+	return cmp
+}
+
+// Rank returns the indexes of group ordered from most to least preferred.
+// Ties keep the group's existing order.
+func (r *Rule) Rank(group []*models.VideoData) []int {
+	order := make([]int, len(group))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := group[order[i]], group[order[j]]
+		for k := range r.Prefer {
+			if c := r.Prefer[k].compare(a, b); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return order
+}
+
+// Select returns, for each member of group, whether it should be selected.
+func (r *Rule) Select(group []*models.VideoData) []bool {
+	selected := make([]bool, len(group))
+	for rank, idx := range r.Rank(group) {
+		selected[idx] = rank >= r.Keep
+	}
+	return selected
+}
+
+// Preview runs the rule against groups without changing anything.
+func (r *Rule) Preview(groups [][]*models.VideoData) Preview {
+	var p Preview
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		p.Groups++
+		for i, sel := range r.Select(group) {
+			if sel {
+				p.Selected++
+				p.SelectedBytes += group[i].Video.Size
+			} else {
+				p.Kept++
+			}
+		}
+	}
+	return p
+}
+
+// String prints the rule back in its canonical form.
+func (r *Rule) String() string {
+	parts := make([]string, len(r.Prefer))
+	for i, c := range r.Prefer {
+		parts[i] = c.String()
+	}
+	return fmt.Sprintf("prefer %s; keep %d", strings.Join(parts, ", "), r.Keep)
+}
+
+func (c Criterion) String() string {
+	if c.Op == "" {
+		if c.Desc {
+			return c.Field + " desc"
+		}
+		return c.Field + " asc"
+	}
+	value := c.Value
+	if !isIdent(value) {
+		value = strconv.Quote(value)
+	}
+	s := fmt.Sprintf("%s %s %s", c.Field, c.Op, value)
+	if c.Negate {
+		return "not " + s
+	}
+	return s
+}
+
+func formatBytes(sizeBytes int64) string {
+	const (
+		MB = 1024.0 * 1024.0
+		GB = 1024.0 * 1024.0 * 1024.0
+	)
+	gbVal := float64(sizeBytes) / GB
+	if gbVal >= 1.0 {
+		return fmt.Sprintf("%.2f GB", gbVal)
+	}
+	return fmt.Sprintf("%.2f MB", float64(sizeBytes)/MB)
+}
@@ -0,0 +1,90 @@
+package rules
+
+import (
+	"testing"
+
+	"govdupes/internal/models"
+)
+
+func video(path, codec string, width, height, bitrate int) *models.VideoData {
+	return &models.VideoData{Video: models.Video{
+		Path:       path,
+		VideoCodec: codec,
+		Width:      width,
+		Height:     height,
+		BitRate:    bitrate,
+	}}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr bool
+	}{
+		{
+			expr: `prefer resolution desc, bitrate desc, path contains "/keep/", codec = hevc; keep 1`,
+			want: `prefer resolution desc, bitrate desc, path contains "/keep/", codec = hevc; keep 1`,
+		},
+		{expr: "prefer size asc; keep 2", want: "prefer size asc; keep 2"},
+		{expr: "PREFER Bitrate", want: "prefer bitrate desc; keep 1"},
+		{expr: "prefer not name matches sample, size > 1GB", want: "prefer not name matches sample, size > 1GB; keep 1"},
+		{expr: "keep 1", wantErr: true},
+		{expr: "prefer nosuchfield", wantErr: true},
+		{expr: "prefer size contains 10", wantErr: true},
+		{expr: "prefer path > 3", wantErr: true},
+		{expr: "prefer size; keep 0", wantErr: true},
+		{expr: `prefer path contains "unterminated`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.expr)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) err = nil, want error", tt.expr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) err = %q, want nil", tt.expr, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("Parse(%q) = %q, want %q", tt.expr, got.String(), tt.want)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	group := []*models.VideoData{
+		video("/dl/a.mp4", "h264", 1920, 1080, 8000),
+		video("/keep/b.mkv", "hevc", 1920, 1080, 4000),
+		video("/dl/c.mp4", "h264", 1280, 720, 9000),
+	}
+
+	tests := []struct {
+		expr string
+		want []bool
+	}{
+		{"prefer resolution desc, bitrate desc", []bool{false, true, true}},
+		{`prefer path contains "/keep/"`, []bool{true, false, true}},
+		{"prefer codec = hevc", []bool{true, false, true}},
+		{"prefer bitrate asc", []bool{true, false, true}},
+		{"prefer resolution desc; keep 2", []bool{false, false, true}},
+		{"prefer bitrate desc; keep 5", []bool{false, false, false}},
+	}
+
+	for _, tt := range tests {
+		rule, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q) err = %q, want nil", tt.expr, err)
+		}
+		got := rule.Select(group)
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: Select() = %v, want %v", tt.expr, got, tt.want)
+				break
+			}
+		}
+	}
+}
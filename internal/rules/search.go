@@ -0,0 +1,191 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"govdupes/internal/models"
+)
+
+// ParseSearchQuery parses the user’s raw filter string into OR-groups of AND-terms.
+// Each AND-term can be positive or negative, can be a multi-word quoted phrase
+// and can be a field predicate such as size>1GB, codec:hevc, res>=1080,
+// duration<60s, path:"/tv/" or links>1.
+//
+// The rules are:
+// - Split on " or " or "|" to get orGroups
+// - Within each orGroup, split into terms
+// - Terms that start with '-' are excluded
+// - Quoted phrases are kept intact
+// - field<op>value is a predicate when field is a known field name
+func ParseSearchQuery(text string) (models.SearchQuery, error) {
+	var query models.SearchQuery
+	if strings.TrimSpace(text) == "" {
+		return query, nil
+	}
+
+	for _, orPart := range splitCaseInsensitive(text, " or ") {
+		for _, part := range strings.Split(orPart, "|") {
+			var terms []models.SearchTerm
+			for _, tok := range tokenize(part) {
+				t, err := parseTerm(tok)
+				if err != nil {
+					return models.SearchQuery{}, err
+				}
+				terms = append(terms, t)
+			}
+			if len(terms) > 0 {
+				query.OrGroups = append(query.OrGroups, terms)
+			}
+		}
+	}
+	return query, nil
+}
+
+// predicate operators, longest first so "<=" wins over "<"
+var searchOps = []string{">=", "<=", "!=", ":", "=", "<", ">"}
+
+func parseTerm(tok queryToken) (models.SearchTerm, error) {
+	t := models.SearchTerm{}
+	s := tok.text
+	if strings.HasPrefix(s, "-") && len(s) > 1 {
+		t.Excluded = true
+		s = s[1:]
+	}
+
+	if !tok.literal {
+		if field, op, value, ok := splitPredicate(s); ok {
+			name, kind, known := models.LookupField(field)
+			if known {
+				t.Field = name
+				t.Op = op
+				t.Text = strings.ToLower(value)
+				if kind == models.FieldNumeric {
+					num, err := models.ParseNumericValue(name, value)
+					if err != nil {
+						return t, err
+					}
+					t.Num = num
+				} else if op != ":" && op != "=" && op != "!=" {
+					return t, fmt.Errorf("operator %q needs a numeric field, %q is text", op, name)
+				}
+				return t, nil
+			}
+		}
+	}
+
+	t.Text = strings.ToLower(s)
+	return t, nil
+}
+
+// splitPredicate splits "field<op>value" at the first operator.
+func splitPredicate(s string) (field, op, value string, ok bool) {
+	idx := strings.IndexAny(s, ":=!<>")
+	if idx <= 0 {
+		return "", "", "", false
+	}
+	for _, candidate := range searchOps {
+		if strings.HasPrefix(s[idx:], candidate) {
+			return s[:idx], candidate, s[idx+len(candidate):], true
+		}
+	}
+	return "", "", "", false
+}
+
+// splitCaseInsensitive splits a string on a substring ignoring case.
+func splitCaseInsensitive(s, sep string) []string {
+	var result []string
+	sepLower := strings.ToLower(sep)
+	sLower := strings.ToLower(s)
+
+	for {
+		idx := strings.Index(sLower, sepLower)
+		if idx == -1 {
+			// no more occurrences
+			result = append(result, strings.TrimSpace(s))
+			break
+		}
+		// slice up to idx
+		chunk := s[:idx]
+		result = append(result, strings.TrimSpace(chunk))
+
+		// advance past the separator
+		nextPos := idx + len(sep)
+		if nextPos >= len(s) {
+			break
+		}
+		s = s[nextPos:]
+		sLower = sLower[nextPos:]
+	}
+	return result
+}
+
+type queryToken struct {
+	text    string
+	literal bool // the whole token was a quoted phrase
+}
+
+// tokenize handles quoted phrases vs. unquoted words.
+// E.g.  foo "bar baz" -qux path:"/tv shows/"  =>  ["foo", "bar baz", "-qux", "path:/tv shows/"]
+// A quote directly after a predicate operator or a leading '-' stays part of
+// the same token.
+func tokenize(s string) []queryToken {
+	var tokens []queryToken
+	var current strings.Builder
+	inQuotes := false
+	literal := false
+
+	for i := range len(s) {
+		ch := s[i]
+
+		if ch == '"' {
+			// Toggle inQuotes
+			if inQuotes {
+				// close quote, the token ends at the next space
+				inQuotes = false
+				continue
+			}
+			// open quote
+			inQuotes = true
+			prefix := current.String()
+			if prefix == "" || prefix == "-" {
+				literal = true
+			} else if !strings.ContainsAny(prefix[len(prefix)-1:], ":=<>") {
+				// if current has something, that’s separate
+				tokens = appendToken(tokens, prefix, false)
+				current.Reset()
+				literal = true
+			}
+			continue
+		}
+
+		// If we’re not in quotes, and we see space, that ends a token
+		if !inQuotes && (ch == ' ' || ch == '\t') {
+			if current.Len() > 0 {
+				tokens = appendToken(tokens, current.String(), literal)
+				current.Reset()
+			}
+			literal = false
+			continue
+		}
+
+		// Otherwise accumulate
+		current.WriteByte(ch)
+	}
+
+	// Flush remainder
+	if current.Len() > 0 {
+		tokens = appendToken(tokens, current.String(), literal)
+	}
+
+	return tokens
+}
+
+// appendToken is a helper to skip empty tokens
+func appendToken(tokens []queryToken, t string, literal bool) []queryToken {
+	t = strings.TrimSpace(t)
+	if t != "" {
+		tokens = append(tokens, queryToken{text: t, literal: literal})
+	}
+	return tokens
+}
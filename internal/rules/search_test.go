@@ -0,0 +1,74 @@
+package rules
+
+import (
+	"testing"
+
+	"govdupes/internal/models"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	hevc := &models.VideoData{Video: models.Video{
+		Path: "/tv/show/ep1.mkv", FileName: "ep1.mkv", VideoCodec: "hevc",
+		Width: 1920, Height: 1080, Size: 2 << 30, Duration: 1500, NumHardLinks: 2,
+		BitRate: 8_000_000,
+	}}
+	h264 := &models.VideoData{Video: models.Video{
+		Path: "/movies/clip one.mp4", FileName: "clip one.mp4", VideoCodec: "h264",
+		Width: 1280, Height: 720, Size: 100 << 20, Duration: 45, NumHardLinks: 1,
+		BitRate: 8_200_000,
+	}}
+
+	tests := []struct {
+		query    string
+		wantHEVC bool
+		wantH264 bool
+	}{
+		{"", true, true},
+		{"ep1", true, false},
+		{"EP1", true, false},
+		{`"clip one"`, false, true},
+		{"-ep1", false, true},
+		{"size>1GB", true, false},
+		{"size<=100MB", false, true},
+		// bitrates are decimal, sizes binary
+		{"bitrate>8m", false, true},
+		{"bitrate<=8mbps", true, false},
+		{"bitrate>8100kbps", false, true},
+		{"codec:hevc", true, false},
+		{"codec=h26", false, false},
+		{"-codec:hevc", false, true},
+		{"res>=1080", true, false},
+		{"res=720p", false, true},
+		{"duration<60s", false, true},
+		{"duration>20m", true, false},
+		{`path:"/tv/"`, true, false},
+		{`-path:"/tv/"`, false, true},
+		{"links>1", true, false},
+		{"codec:hevc or duration<1m", true, true},
+		{"codec:hevc | res<720", true, false},
+		{"codec:hevc ep2", false, false},
+		{"c:/windows", false, false},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseSearchQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseSearchQuery(%q) err = %q, want nil", tt.query, err)
+			continue
+		}
+		if got := q.Matches(hevc); got != tt.wantHEVC {
+			t.Errorf("%q matches hevc = %t, want %t", tt.query, got, tt.wantHEVC)
+		}
+		if got := q.Matches(h264); got != tt.wantH264 {
+			t.Errorf("%q matches h264 = %t, want %t", tt.query, got, tt.wantH264)
+		}
+	}
+}
+
+func TestParseSearchQueryErrors(t *testing.T) {
+	for _, query := range []string{"size>big", "path>3", "duration<abc"} {
+		if _, err := ParseSearchQuery(query); err == nil {
+			t.Errorf("ParseSearchQuery(%q) err = nil, want error", query)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package videoprocessor
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"govdupes/internal/models"
+)
+
+// AudioPCM decodes up to maxSeconds of v's main audio stream as mono signed
+// 16 bit samples at sampleRate. Videos that ffprobe found no audio in return
+// no samples without running ffmpeg, which fails on an empty output.
+func (f *FFmpegWrapper) AudioPCM(ctx context.Context, v *models.Video, sampleRate int, maxSeconds float64) ([]int16, error) {
+	if v.AudioStreamIndex < 0 || v.AudioCodec == "" {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	seconds := maxSeconds
+	if v.Duration > 0 {
+		seconds = min(seconds, float64(v.Duration))
+	}
+	_, err := f.decode(ctx, []string{
+		"-i", v.Path,
+		"-map", audioMap(v),
+		"-vn",
+		"-t", fmt.Sprintf("%.3f", maxSeconds),
+		"-ac", "1",
+		"-ar", strconv.Itoa(sampleRate),
+		"-f", "s16le",
+		"pipe:",
+	}, &buf, seconds)
+	if err != nil {
+		slog.Error("Error decoding audio", slog.String("path", v.Path), slog.Any("error", err))
+		return nil, err
+	}
+
+	samples := make([]int16, buf.Len()/2)
+	if err := binary.Read(&buf, binary.LittleEndian, samples); err != nil {
+		return nil, fmt.Errorf("reading pcm samples: %w", err)
+	}
+	return samples, nil
+}
@@ -0,0 +1,30 @@
+package videoprocessor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"govdupes/internal/config"
+	"govdupes/internal/models"
+)
+
+func TestAudioPCMWithoutAudio(t *testing.T) {
+	// ffmpeg can't run, so any call to it fails the test
+	f := NewFFmpegInstance(&config.Config{FFmpegPath: filepath.Join(t.TempDir(), "ffmpeg")})
+
+	tests := []struct {
+		name string
+		v    *models.Video
+	}{
+		{"no audio stream", &models.Video{Path: "silent.mp4", AudioStreamIndex: -1, AudioCodec: ""}},
+		{"not probed", &models.Video{Path: "silent.mp4", AudioStreamIndex: -1, AudioCodec: "aac"}},
+		{"no codec", &models.Video{Path: "silent.mp4", AudioStreamIndex: 1, AudioCodec: ""}},
+	}
+	for _, tt := range tests {
+		samples, err := f.AudioPCM(context.Background(), tt.v, 11025, 120)
+		if samples != nil || err != nil {
+			t.Errorf("%s: AudioPCM() = %d samples, %v, want nil, nil", tt.name, len(samples), err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package videoprocessor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"govdupes/internal/models"
+	"govdupes/internal/videoprocessor/process"
+)
+
+// DecodeErrors decodes length seconds of v from start, or everything after
+// start when length is 0, and returns what ffmpeg logged as errors and how
+// many seconds it decoded. The error is set when ffmpeg itself failed.
+func (f *FFmpegWrapper) DecodeErrors(ctx context.Context, v *models.Video, start, length float64) (string, float64, error) {
+	args := []string{"-v", "error", "-progress", "pipe:1"}
+	if start > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", start))
+	}
+	args = append(args, "-i", v.Path)
+	seconds := float64(v.Duration) - start
+	if length > 0 {
+		args = append(args, "-t", fmt.Sprintf("%.3f", length))
+		seconds = length
+	}
+	args = append(args, "-f", "null", "-")
+
+	var progress bytes.Buffer
+	stderr, err := f.decode(ctx, args, &progress, seconds)
+	return stderr, decodedSeconds(progress.String()), err
+}
+
+// decodedSeconds returns the last output time of ffmpeg's -progress
+// output, 0 when nothing was decoded.
+func decodedSeconds(progress string) float64 {
+	var us int64
+	for _, line := range strings.Split(progress, "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), "out_time_us=")
+		if !ok {
+			continue
+		}
+		// N/A until the first frame is written
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			us = n
+		}
+	}
+	return float64(us) / 1e6
+}
+
+// decode is ffmpeg for a call that decodes seconds of a video instead of a
+// few frames. The default timeout is meant for those, so a long decode may
+// take twice real time when that is longer.
+func (f *FFmpegWrapper) decode(ctx context.Context, args []string, stdout io.Writer, seconds float64) (string, error) {
+	var timeout time.Duration
+	if d := f.runner.Timeout(); d > 0 {
+		timeout = max(d, time.Duration(seconds*2*float64(time.Second)))
+	}
+	return f.runner.FFmpeg(ctx, process.Cmd{
+		Args:    append([]string{"-hide_banner", "-nostats", "-nostdin"}, args...),
+		Stdout:  stdout,
+		Timeout: timeout,
+	})
+}
@@ -0,0 +1,22 @@
+package videoprocessor
+
+import "testing"
+
+func TestDecodedSeconds(t *testing.T) {
+	progress := `frame=0
+out_time_us=N/A
+progress=continue
+frame=250
+out_time_us=10000000
+progress=continue
+frame=301
+out_time_us=12040000
+progress=end
+`
+	if got := decodedSeconds(progress); got != 12.04 {
+		t.Errorf("decodedSeconds() = %v, want 12.04", got)
+	}
+	if got := decodedSeconds("out_time_us=N/A\nprogress=end\n"); got != 0 {
+		t.Errorf("decodedSeconds() without frames = %v, want 0", got)
+	}
+}
@@ -2,33 +2,95 @@ package ffprobe
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
-	"os/exec"
+	"math"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"govdupes/internal/models"
+	"govdupes/internal/videoprocessor/process"
 )
 
 type FFProbeOutput struct {
-	Streams []struct {
-		CodecType     string          `json:"codec_type"`
-		CodecName     string          `json:"codec_name"`
-		Width         int             `json:"width"`
-		Height        int             `json:"height"`
-		SampleRateAvg int             `json:"sample_rate_avg"`
-		AvgFrameRate  FractionFloat32 `json:"avg_frame_rate"`
-	} `json:"streams"`
-	Format struct {
-		Duration string `json:"duration"`
-		Size     string `json:"size"`
-		BitRate  string `json:"bit_rate"`
+	Streams []FFProbeStream `json:"streams"`
+	Format  struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		Size       string `json:"size"`
+		BitRate    string `json:"bit_rate"`
+		Tags       struct {
+			CreationTime string `json:"creation_time"`
+		} `json:"tags"`
 	} `json:"format"`
 }
 
+type FFProbeStream struct {
+	Index          int             `json:"index"`
+	CodecType      string          `json:"codec_type"`
+	CodecName      string          `json:"codec_name"`
+	Profile        string          `json:"profile"`
+	Level          int             `json:"level"`
+	Width          int             `json:"width"`
+	Height         int             `json:"height"`
+	PixFmt         string          `json:"pix_fmt"`
+	ColorTransfer  string          `json:"color_transfer"`
+	ColorPrimaries string          `json:"color_primaries"`
+	SampleRate     string          `json:"sample_rate"`
+	BitRate        string          `json:"bit_rate"`
+	Channels       int             `json:"channels"`
+	AvgFrameRate   FractionFloat32 `json:"avg_frame_rate"`
+	// the display matrix rotation, older muxers write the rotate tag
+	SideDataList []struct {
+		Rotation float64 `json:"rotation"`
+	} `json:"side_data_list"`
+	Tags struct {
+		Rotate   string `json:"rotate"`
+		Language string `json:"language"`
+	} `json:"tags"`
+	Disposition struct {
+		Default     int `json:"default"`
+		AttachedPic int `json:"attached_pic"`
+	} `json:"disposition"`
+}
+
+func (s *FFProbeStream) toModel() models.VideoStream {
+	sampleRate, _ := strconv.Atoi(s.SampleRate)
+	bitRate, _ := strconv.Atoi(s.BitRate)
+	return models.VideoStream{
+		Index:       s.Index,
+		CodecType:   s.CodecType,
+		CodecName:   s.CodecName,
+		Profile:     s.Profile,
+		Width:       s.Width,
+		Height:      s.Height,
+		PixFmt:      s.PixFmt,
+		BitRate:     bitRate,
+		SampleRate:  sampleRate,
+		Channels:    s.Channels,
+		Language:    s.Tags.Language,
+		IsDefault:   s.Disposition.Default != 0,
+		AttachedPic: s.Disposition.AttachedPic != 0,
+	}
+}
+
+// rotation returns the clockwise rotation applied on display, 0 to 270.
+func (s *FFProbeStream) rotation() int {
+	rotation, _ := strconv.Atoi(s.Tags.Rotate)
+	for _, sd := range s.SideDataList {
+		if sd.Rotation != 0 {
+			// the display matrix angle is anticlockwise
+			rotation = -int(math.Round(sd.Rotation))
+		}
+	}
+	return ((rotation % 360) + 360) % 360
+}
+
 type FractionFloat32 float32
 
 func (f *FractionFloat32) UnmarshalJSON(data []byte) error {
@@ -56,24 +118,24 @@ func (f *FractionFloat32) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func GetVideoInfo(v *models.Video) error {
+// GetVideoInfo runs ffprobe on v through r and fills in its metadata.
+func GetVideoInfo(ctx context.Context, r *process.Runner, v *models.Video) error {
 	slog.Info("Getting video info", slog.String("filename", v.FileName))
-	cmd := exec.Command("ffprobe",
-		"-hide_banner",
-		"-loglevel", "error",
-		"-show_entries", "format=duration,size,bit_rate",
-		"-show_entries", "stream=codec_type,codec_name,width,height,sample_rate,avg_frame_rate",
-		"-of", "json",
-		v.Path)
-
 	var out bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
+	_, err := r.FFprobe(ctx, process.Cmd{
+		Args: []string{
+			"-hide_banner",
+			"-loglevel", "error",
+			"-show_entries", "format=format_name,duration,size,bit_rate:format_tags=creation_time",
+			"-show_entries", "stream=index,codec_type,codec_name,profile,level,width,height,pix_fmt,color_transfer,color_primaries,sample_rate,bit_rate,channels,avg_frame_rate" +
+				":stream_tags=rotate,language:stream_disposition=default,attached_pic:stream_side_data=rotation",
+			"-of", "json",
+			v.Path,
+		},
+		Stdout: &out,
+	})
 	if err != nil {
-		return fmt.Errorf("ffprobe error: %v, stderr: %s", err, stderr.String())
+		return fmt.Errorf("ffprobe error: %w", err)
 	}
 
 	var ffProbeOutput FFProbeOutput
@@ -87,25 +149,76 @@ func GetVideoInfo(v *models.Video) error {
 	return nil
 }
 
+// setVideo copies the probed metadata into v. The first video stream marked
+// default, or else the first one, is the main one; cover art is skipped.
+// Streams of other types, e.g. subtitles and data, are counted but don't
+// fail the probe.
 func setVideo(f *FFProbeOutput, v *models.Video) error {
-	for _, stream := range f.Streams {
+	var main *FFProbeStream
+	var audio *FFProbeStream
+	var audioLanguages, subtitleLanguages []string
+	v.VideoStreams, v.AudioStreams, v.SubtitleTracks = 0, 0, 0
+	v.Streams = make([]models.VideoStream, 0, len(f.Streams))
+	for i := range f.Streams {
+		stream := &f.Streams[i]
+		v.Streams = append(v.Streams, stream.toModel())
 		switch stream.CodecType {
 		case "video":
-			if stream.Width <= 0 || stream.Height <= 0 {
-				return fmt.Errorf("invalid video dimensions: width=%d, height=%d", stream.Width, stream.Height)
+			if stream.Disposition.AttachedPic != 0 {
+				continue
+			}
+			v.VideoStreams++
+			if main == nil || (stream.Disposition.Default != 0 && main.Disposition.Default == 0) {
+				main = stream
 			}
-			v.VideoCodec = stream.CodecName
-			v.Width = stream.Width
-			v.Height = stream.Height
-			v.AvgFrameRate = float32(stream.AvgFrameRate)
 		case "audio":
-			v.AudioCodec = stream.CodecName
-			v.SampleRateAvg = stream.SampleRateAvg
+			v.AudioStreams++
+			if audio == nil || (stream.Disposition.Default != 0 && audio.Disposition.Default == 0) {
+				audio = stream
+			}
+			audioLanguages = appendLanguage(audioLanguages, stream.Tags.Language)
+		case "subtitle":
+			v.SubtitleTracks++
+			subtitleLanguages = appendLanguage(subtitleLanguages, stream.Tags.Language)
 		default:
-			return fmt.Errorf("unknown codec detected: %q", stream.CodecName)
+			slog.Debug("Ignoring stream", slog.String("type", stream.CodecType), slog.String("codec", stream.CodecName))
 		}
 	}
 
+	v.VideoStreamIndex, v.AudioStreamIndex = -1, -1
+	if main != nil {
+		v.VideoStreamIndex = main.Index
+		if main.Width <= 0 || main.Height <= 0 {
+			return fmt.Errorf("invalid video dimensions: width=%d, height=%d", main.Width, main.Height)
+		}
+		v.VideoCodec = main.CodecName
+		v.VideoProfile = main.Profile
+		v.VideoLevel = main.Level
+		v.PixFmt = main.PixFmt
+		v.ColorTransfer = main.ColorTransfer
+		v.ColorPrimaries = main.ColorPrimaries
+		v.Width = main.Width
+		v.Height = main.Height
+		// ffmpeg decodes rotated videos upright, use the displayed size
+		v.Rotation = main.rotation()
+		if v.Rotation%180 != 0 {
+			v.Width, v.Height = v.Height, v.Width
+		}
+		v.AvgFrameRate = float32(main.AvgFrameRate)
+	}
+	if audio != nil {
+		v.AudioStreamIndex = audio.Index
+		v.AudioCodec = audio.CodecName
+		v.SampleRateAvg, _ = strconv.Atoi(audio.SampleRate)
+		v.AudioChannels = audio.Channels
+	}
+	v.AudioLanguages = strings.Join(audioLanguages, ",")
+	v.SubtitleLanguages = strings.Join(subtitleLanguages, ",")
+	v.Container = f.Format.FormatName
+	if t, err := time.Parse(time.RFC3339Nano, f.Format.Tags.CreationTime); err == nil {
+		v.MediaCreatedAt = t
+	}
+
 	size, err := strconv.Atoi(f.Format.Size)
 	if err != nil {
 		return fmt.Errorf("error converting size to int, filename: %q, size: %q", v.FileName, f.Format.Size)
@@ -131,6 +244,15 @@ func setVideo(f *FFProbeOutput, v *models.Video) error {
 	return nil
 }
 
+// appendLanguage adds lang once, streams without a language are left out.
+func appendLanguage(languages []string, lang string) []string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "" || lang == "und" || slices.Contains(languages, lang) {
+		return languages
+	}
+	return append(languages, lang)
+}
+
 func (f *FFProbeOutput) print() {
 	slog.Info("ffprobe output",
 		slog.String("Duration", f.Format.Duration),
@@ -147,9 +269,9 @@ func (f *FFProbeOutput) print() {
 		case "audio":
 			slog.Info("Audio stream",
 				slog.String("Codec", stream.CodecName),
-				slog.Int("SampleRateAvg", stream.SampleRateAvg))
+				slog.String("SampleRate", stream.SampleRate))
 		default:
-			slog.Warn("Unknown codec detected", slog.String("Codec", stream.CodecName))
+			slog.Info("Other stream", slog.String("Type", stream.CodecType), slog.String("Codec", stream.CodecName))
 		}
 	}
 }
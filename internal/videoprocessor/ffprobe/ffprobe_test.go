@@ -0,0 +1,84 @@
+package ffprobe
+
+import (
+	"encoding/json"
+	"testing"
+
+	"govdupes/internal/models"
+)
+
+func TestSetVideoRotation(t *testing.T) {
+	tests := []struct {
+		name          string
+		stream        string
+		width, height int
+	}{
+		{"upright", `{"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080, "avg_frame_rate": "30/1"}`, 1920, 1080},
+		{"display matrix", `{"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080, "avg_frame_rate": "30/1",
+			"side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}]}`, 1080, 1920},
+		{"rotate tag", `{"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080, "avg_frame_rate": "30/1",
+			"tags": {"rotate": "270"}}`, 1080, 1920},
+		{"upside down", `{"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080, "avg_frame_rate": "30/1",
+			"side_data_list": [{"side_data_type": "Display Matrix", "rotation": 180}]}`, 1920, 1080},
+	}
+	for _, tt := range tests {
+		var out FFProbeOutput
+		data := `{"streams": [` + tt.stream + `], "format": {"duration": "10.0", "size": "1000", "bit_rate": "800"}}`
+		if err := json.Unmarshal([]byte(data), &out); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var v models.Video
+		if err := setVideo(&out, &v); err != nil {
+			t.Fatalf("%s: setVideo() error = %v", tt.name, err)
+		}
+		if v.Width != tt.width || v.Height != tt.height {
+			t.Errorf("%s: size = %dx%d, want %dx%d", tt.name, v.Width, v.Height, tt.width, tt.height)
+		}
+	}
+}
+
+func TestSetVideoStreams(t *testing.T) {
+	data := `{"streams": [
+		{"index": 0, "codec_type": "video", "codec_name": "mjpeg", "width": 600, "height": 600, "avg_frame_rate": "0/0",
+			"disposition": {"default": 0, "attached_pic": 1}},
+		{"index": 1, "codec_type": "video", "codec_name": "hevc", "profile": "Main 10", "level": 150, "width": 3840, "height": 2160,
+			"pix_fmt": "yuv420p10le", "color_transfer": "smpte2084", "color_primaries": "bt2020", "avg_frame_rate": "24000/1001",
+			"disposition": {"default": 1, "attached_pic": 0}},
+		{"index": 2, "codec_type": "audio", "codec_name": "aac", "sample_rate": "44100", "channels": 2, "tags": {"language": "jpn"},
+			"disposition": {"default": 0}},
+		{"index": 3, "codec_type": "audio", "codec_name": "eac3", "sample_rate": "48000", "channels": 6, "tags": {"language": "eng"},
+			"disposition": {"default": 1}},
+		{"codec_type": "subtitle", "codec_name": "subrip", "tags": {"language": "eng"}},
+		{"codec_type": "subtitle", "codec_name": "ass", "tags": {"language": "und"}},
+		{"codec_type": "data", "codec_name": "bin_data"},
+		{"codec_type": "attachment", "codec_name": "ttf"}
+	], "format": {"format_name": "matroska,webm", "duration": "10.0", "size": "1000", "bit_rate": "800",
+		"tags": {"creation_time": "2021-05-01T10:00:00.000000Z"}}}`
+	var out FFProbeOutput
+	if err := json.Unmarshal([]byte(data), &out); err != nil {
+		t.Fatal(err)
+	}
+	var v models.Video
+	if err := setVideo(&out, &v); err != nil {
+		t.Fatalf("setVideo() error = %v", err)
+	}
+
+	if v.VideoStreamIndex != 1 || v.AudioStreamIndex != 3 || len(v.Streams) != 8 {
+		t.Errorf("main streams = %d and %d of %d, want 1 and 3 of 8", v.VideoStreamIndex, v.AudioStreamIndex, len(v.Streams))
+	}
+	if v.VideoCodec != "hevc" || v.Width != 3840 || v.VideoStreams != 1 {
+		t.Errorf("main video = %s %dx%d in %d streams, want hevc 3840x2160 in 1", v.VideoCodec, v.Width, v.Height, v.VideoStreams)
+	}
+	if !v.IsHDR() || v.PixFmt != "yuv420p10le" || v.VideoProfile != "Main 10" || v.VideoLevel != 150 {
+		t.Errorf("video details = %q %q %q %d, want HDR yuv420p10le Main 10 150", v.ColorTransfer, v.PixFmt, v.VideoProfile, v.VideoLevel)
+	}
+	if v.AudioCodec != "eac3" || v.AudioChannels != 6 || v.SampleRateAvg != 48000 || v.AudioStreams != 2 {
+		t.Errorf("audio = %s %dch %dHz in %d streams, want the default eac3 6ch 48000Hz in 2", v.AudioCodec, v.AudioChannels, v.SampleRateAvg, v.AudioStreams)
+	}
+	if v.AudioLanguages != "jpn,eng" || v.SubtitleTracks != 2 || v.SubtitleLanguages != "eng" {
+		t.Errorf("languages = %q, %d subs %q, want jpn,eng, 2 subs eng", v.AudioLanguages, v.SubtitleTracks, v.SubtitleLanguages)
+	}
+	if v.Container != "matroska,webm" || v.MediaCreatedAt.Year() != 2021 {
+		t.Errorf("container = %q created %v", v.Container, v.MediaCreatedAt)
+	}
+}
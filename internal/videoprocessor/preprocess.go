@@ -0,0 +1,147 @@
+package videoprocessor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"govdupes/internal/models"
+)
+
+// Preprocess is applied to every frame before it is scaled for hashing, in
+// order: black bars are cropped, the centre is kept and the corners are
+// masked. Changing it makes new hashes incomparable with older ones.
+type Preprocess struct {
+	// AutoCrop removes black bars found by ffmpeg's cropdetect.
+	AutoCrop bool
+	// CenterCrop is the fraction of the width and height kept around the
+	// centre, 0 or 1 keeps the whole frame.
+	CenterCrop float64
+	// MaskCorners are the corners ("tl", "tr", "bl", "br") painted black,
+	// MaskSize is the fraction of the width and height each mask covers.
+	MaskCorners []string
+	MaskSize    float64
+}
+
+// Crop is a crop rectangle in pixels.
+type Crop struct {
+	W, H, X, Y int
+}
+
+const (
+	// cropdetectSeconds is how many frames, one per second, are scanned for bars
+	cropdetectSeconds = 20
+	// minCropFraction ignores bars thinner than this fraction of a side
+	minCropFraction = 0.02
+)
+
+var cropRe = regexp.MustCompile(`crop=(-?\d+):(-?\d+):(-?\d+):(-?\d+)`)
+
+// FrameFilter returns the filters for v's frames, empty when there is
+// nothing to do. Crop detection failures only skip the crop.
+func (f *FFmpegWrapper) FrameFilter(ctx context.Context, v *models.Video) string {
+	var crop *Crop
+	if f.preprocess.AutoCrop {
+		c, err := f.DetectCrop(ctx, v)
+		if err != nil {
+			slog.Warn("Can't detect black bars", slog.String("path", v.Path), slog.Any("error", err))
+		}
+		crop = c
+	}
+	return buildFrameFilter(crop, f.preprocess)
+}
+
+// DetectCrop runs cropdetect over frames from a quarter into v and returns
+// the area that is never black, or nil when there are no bars to remove.
+func (f *FFmpegWrapper) DetectCrop(ctx context.Context, v *models.Video) (*Crop, error) {
+	stderr, err := f.ffmpeg(ctx, []string{
+		"-ss", fmt.Sprintf("%.3f", float64(v.Duration)/4),
+		"-i", v.Path,
+		"-map", videoMap(v),
+		// without a reset the reported area grows to cover every frame
+		"-vf", "fps=1,cropdetect=limit=24:round=2:reset=0",
+		"-frames:v", strconv.Itoa(cropdetectSeconds),
+		"-f", "null",
+		"-",
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	crop, ok := parseCropdetect(stderr)
+	if !ok {
+		return nil, fmt.Errorf("cropdetect reported no crop")
+	}
+	if !significantCrop(crop, v.Width, v.Height) {
+		return nil, nil
+	}
+	slog.Info("Cropping black bars", slog.String("path", v.Path), slog.Any("crop", crop))
+	return &crop, nil
+}
+
+// parseCropdetect returns the last crop cropdetect logged. Frames that are
+// all black give a negative size, which isn't usable.
+func parseCropdetect(output string) (Crop, bool) {
+	matches := cropRe.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return Crop{}, false
+	}
+	last := matches[len(matches)-1]
+	var values [4]int
+	for i := range values {
+		n, err := strconv.Atoi(last[i+1])
+		if err != nil {
+			return Crop{}, false
+		}
+		values[i] = n
+	}
+	crop := Crop{W: values[0], H: values[1], X: values[2], Y: values[3]}
+	if crop.W <= 0 || crop.H <= 0 || crop.X < 0 || crop.Y < 0 {
+		return Crop{}, false
+	}
+	return crop, true
+}
+
+// significantCrop reports whether crop removes more than a sliver of a
+// width x height frame. Without a known size any smaller crop counts.
+func significantCrop(crop Crop, width, height int) bool {
+	if width <= 0 || height <= 0 {
+		return crop.X > 0 || crop.Y > 0
+	}
+	return float64(width-crop.W) > minCropFraction*float64(width) ||
+		float64(height-crop.H) > minCropFraction*float64(height)
+}
+
+func buildFrameFilter(crop *Crop, p Preprocess) string {
+	var filters []string
+	if crop != nil {
+		filters = append(filters, fmt.Sprintf("crop=%d:%d:%d:%d", crop.W, crop.H, crop.X, crop.Y))
+	}
+	if p.CenterCrop > 0 && p.CenterCrop < 1 {
+		filters = append(filters, fmt.Sprintf("crop=iw*%[1]g:ih*%[1]g", p.CenterCrop))
+	}
+	if p.MaskSize > 0 && p.MaskSize < 1 {
+		far := 1 - p.MaskSize
+		for _, corner := range p.MaskCorners {
+			var x, y string
+			switch strings.ToLower(strings.TrimSpace(corner)) {
+			case "tl":
+				x, y = "0", "0"
+			case "tr":
+				x, y = fmt.Sprintf("iw*%g", far), "0"
+			case "bl":
+				x, y = "0", fmt.Sprintf("ih*%g", far)
+			case "br":
+				x, y = fmt.Sprintf("iw*%g", far), fmt.Sprintf("ih*%g", far)
+			default:
+				slog.Warn("Unknown mask corner, expected tl, tr, bl or br", slog.String("corner", corner))
+				continue
+			}
+			filters = append(filters, fmt.Sprintf("drawbox=x=%s:y=%s:w=iw*%[3]g:h=ih*%[3]g:color=black:t=fill", x, y, p.MaskSize))
+		}
+	}
+	return strings.Join(filters, ",")
+}
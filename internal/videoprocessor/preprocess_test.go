@@ -0,0 +1,44 @@
+package videoprocessor
+
+import "testing"
+
+func TestParseCropdetect(t *testing.T) {
+	output := `[Parsed_cropdetect_1 @ 0x5581] x1:0 x2:1919 y1:142 y2:937 w:1920 h:784 x:0 y:148 pts:1 t:1.0 crop=1920:784:0:148
+[Parsed_cropdetect_1 @ 0x5581] x1:0 x2:1919 y1:138 y2:941 w:1920 h:800 x:0 y:140 pts:2 t:2.0 crop=1920:800:0:140
+`
+	crop, ok := parseCropdetect(output)
+	if !ok || crop != (Crop{W: 1920, H: 800, X: 0, Y: 140}) {
+		t.Errorf("parseCropdetect() = %+v, %v, want the last crop", crop, ok)
+	}
+	if !significantCrop(crop, 1920, 1080) {
+		t.Errorf("letterbox bars should be cropped")
+	}
+	if significantCrop(Crop{W: 1920, H: 1072, X: 0, Y: 4}, 1920, 1080) {
+		t.Errorf("a few rows of black should be left alone")
+	}
+
+	if _, ok := parseCropdetect("crop=-1904:-1072:1912:1076"); ok {
+		t.Errorf("all black frames must not give a crop")
+	}
+}
+
+func TestBuildFrameFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		crop *Crop
+		p    Preprocess
+		want string
+	}{
+		{"nothing", nil, Preprocess{CenterCrop: 1, MaskSize: 0.15}, ""},
+		{"bars and centre", &Crop{W: 1440, H: 1080, X: 240, Y: 0}, Preprocess{CenterCrop: 0.9},
+			"crop=1440:1080:240:0,crop=iw*0.9:ih*0.9"},
+		{"logo corners", nil, Preprocess{MaskCorners: []string{"tr", " BL", "middle"}, MaskSize: 0.25},
+			"drawbox=x=iw*0.75:y=0:w=iw*0.25:h=ih*0.25:color=black:t=fill," +
+				"drawbox=x=0:y=ih*0.75:w=iw*0.25:h=ih*0.25:color=black:t=fill"},
+	}
+	for _, tt := range tests {
+		if got := buildFrameFilter(tt.crop, tt.p); got != tt.want {
+			t.Errorf("%s: buildFrameFilter() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Package process runs ffmpeg and ffprobe with timeouts, a shared limit on
+// how many run at once and a lowered CPU and IO priority.
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stderrTail is how much of the end of stderr is kept for errors and callers.
+const stderrTail = 64 << 10
+
+// Options configure a Runner. Zero values keep the defaults: the binaries
+// from PATH, no timeout, one process per CPU and an unchanged priority.
+type Options struct {
+	FFmpegPath  string
+	FFprobePath string
+	// Timeout is the default per call, 0 disables it
+	Timeout time.Duration
+	// MaxProcesses is how many ffmpeg and ffprobe processes run at once
+	MaxProcesses int
+	// Nice is added to the niceness of every process, IONiceClass is the
+	// ionice scheduling class (1 realtime, 2 best-effort, 3 idle). Both
+	// need the nice and ionice commands and are skipped without them.
+	Nice        int
+	IONiceClass int
+	// LogCommands logs every command line at debug level
+	LogCommands bool
+}
+
+// Runner starts the processes. It is safe for concurrent use and meant to be
+// shared, so the limit covers every stage that runs ffmpeg or ffprobe.
+type Runner struct {
+	opts   Options
+	slots  chan struct{}
+	prefix []string
+}
+
+// Cmd is one run of ffmpeg or ffprobe.
+type Cmd struct {
+	Args []string
+	// Stdout receives the output, nil discards it
+	Stdout io.Writer
+	// Timeout replaces the runner's default for this call, -1 disables it
+	Timeout time.Duration
+}
+
+// Error is a process that failed, with the end of what it wrote to stderr.
+type Error struct {
+	Command string
+	Err     error
+	Stderr  string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s: %v", e.Command, e.Err)
+	if last := lastLines(e.Stderr, 5); last != "" {
+		msg += ": " + last
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// ErrTimeout is wrapped by the errors of processes killed by their timeout.
+var ErrTimeout = errors.New("timed out")
+
+func NewRunner(opts Options) *Runner {
+	if opts.FFmpegPath == "" {
+		opts.FFmpegPath = "ffmpeg"
+	}
+	if opts.FFprobePath == "" {
+		opts.FFprobePath = "ffprobe"
+	}
+	if opts.MaxProcesses <= 0 {
+		opts.MaxProcesses = runtime.NumCPU()
+	}
+	return &Runner{
+		opts:   opts,
+		slots:  make(chan struct{}, opts.MaxProcesses),
+		prefix: priorityPrefix(opts.Nice, opts.IONiceClass),
+	}
+}
+
+// Timeout is the default per call timeout, 0 when there is none.
+func (r *Runner) Timeout() time.Duration {
+	return r.opts.Timeout
+}
+
+// FFmpeg runs ffmpeg and returns the end of its stderr.
+func (r *Runner) FFmpeg(ctx context.Context, cmd Cmd) (string, error) {
+	return r.run(ctx, r.opts.FFmpegPath, cmd)
+}
+
+// FFprobe runs ffprobe and returns the end of its stderr.
+func (r *Runner) FFprobe(ctx context.Context, cmd Cmd) (string, error) {
+	return r.run(ctx, r.opts.FFprobePath, cmd)
+}
+
+func (r *Runner) run(ctx context.Context, bin string, c Cmd) (string, error) {
+	select {
+	case r.slots <- struct{}{}:
+		defer func() { <-r.slots }()
+	case <-ctx.Done():
+		return "", r.contextError(ctx, bin, "")
+	}
+
+	// the timeout starts once the process does, not while it waits for a slot
+	timeout := r.opts.Timeout
+	if c.Timeout != 0 {
+		timeout = c.Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	name, args := bin, c.Args
+	if len(r.prefix) > 0 {
+		name = r.prefix[0]
+		args = append(append(slices.Clone(r.prefix[1:]), bin), c.Args...)
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	stderr := &tailBuffer{max: stderrTail}
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = stderr
+	// don't wait forever for a reader that stopped reading after a kill
+	cmd.WaitDelay = 5 * time.Second
+	if r.opts.LogCommands {
+		slog.Debug("Running", slog.String("command", bin+" "+strings.Join(c.Args, " ")))
+	}
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return stderr.String(), r.contextError(ctx, bin, stderr.String())
+	}
+	if err != nil {
+		return stderr.String(), &Error{Command: bin, Err: err, Stderr: stderr.String()}
+	}
+	return stderr.String(), nil
+}
+
+func (r *Runner) contextError(ctx context.Context, bin, stderr string) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = ErrTimeout
+	}
+	return &Error{Command: bin, Err: err, Stderr: stderr}
+}
+
+// priorityPrefix returns the nice and ionice command lines to start the
+// processes through, empty when neither is wanted or available.
+func priorityPrefix(nice, ioniceClass int) []string {
+	var prefix []string
+	if ioniceClass > 0 {
+		if path, err := exec.LookPath("ionice"); err == nil {
+			prefix = append(prefix, path, "-c", strconv.Itoa(ioniceClass))
+		} else {
+			slog.Warn("ionice not found, IO priority is left unchanged")
+		}
+	}
+	if nice != 0 {
+		if path, err := exec.LookPath("nice"); err == nil {
+			prefix = append(prefix, path, "-n", strconv.Itoa(nice))
+		} else {
+			slog.Warn("nice not found, CPU priority is left unchanged")
+		}
+	}
+	return prefix
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.max; over > 0 {
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,48 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// shRunner runs sh scripts in place of ffmpeg.
+func shRunner(t *testing.T, timeout time.Duration) *Runner {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	return NewRunner(Options{FFmpegPath: sh, Timeout: timeout, MaxProcesses: 1})
+}
+
+func TestRunnerStderrInError(t *testing.T) {
+	r := shRunner(t, 0)
+	_, err := r.FFmpeg(context.Background(), Cmd{Args: []string{"-c", "echo header >&2; echo moov atom not found >&2; exit 1"}})
+	if err == nil {
+		t.Fatal("FFmpeg err = nil, want an error")
+	}
+	if !strings.Contains(err.Error(), "moov atom not found") {
+		t.Errorf("FFmpeg err = %q, want the stderr tail in it", err)
+	}
+}
+
+func TestRunnerTimeout(t *testing.T) {
+	r := shRunner(t, 50*time.Millisecond)
+	start := time.Now()
+	_, err := r.FFmpeg(context.Background(), Cmd{Args: []string{"-c", "exec sleep 10"}})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("FFmpeg err = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("FFmpeg took %s, want it killed at the timeout", elapsed)
+	}
+
+	// a per call timeout of -1 disables the default
+	if _, err := r.FFmpeg(context.Background(), Cmd{Args: []string{"-c", "sleep 0.2"}, Timeout: -1}); err != nil {
+		t.Errorf("FFmpeg without timeout err = %v, want nil", err)
+	}
+}
@@ -0,0 +1,159 @@
+package videoprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"strconv"
+
+	"govdupes/internal/models"
+)
+
+// PixelFormat is the layout of the raw frames ffmpeg writes to the pipe.
+type PixelFormat string
+
+const (
+	// PixelGray returns *image.Gray frames, one byte per pixel.
+	PixelGray PixelFormat = "gray"
+	// PixelRGB24 returns *image.RGBA frames with the same pixels a BMP
+	// screenshot decodes to, so hashes stay comparable with older ones.
+	PixelRGB24 PixelFormat = "rgb24"
+)
+
+func (p PixelFormat) bytesPerPixel() int {
+	if p == PixelGray {
+		return 1
+	}
+	return 3
+}
+
+// FrameAtTime returns the frame at timeStamp, read raw from the pipe. filter
+// is applied before scaling, see FrameFilter.
+func (f *FFmpegWrapper) FrameAtTime(ctx context.Context, v *models.Video, timeStamp string, filter string, pix PixelFormat) (image.Image, error) {
+	frames, err := f.rawFrames(ctx, v, timeStamp, "", filter, 1, pix)
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no frame at %s", timeStamp)
+	}
+	return frames[0], nil
+}
+
+// FramesAtInterval decodes the video once and returns the first frame at or
+// after start, start+interval, ..., read straight from the pipe as they are
+// decoded. Frame i is at start+i*interval, so fewer than count frames is an
+// error: the positions of the frames that did arrive aren't known.
+func (f *FFmpegWrapper) FramesAtInterval(ctx context.Context, v *models.Video, start, interval float64, count int, filter string, pix PixelFormat) ([]image.Image, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive, got %f", interval)
+	}
+	selectExpr := fmt.Sprintf("isnan(prev_selected_t)+gt(floor(t/%[1]f),floor(prev_selected_t/%[1]f))", interval)
+	frames, err := f.rawFrames(ctx, v, fmt.Sprintf("%.3f", start), selectExpr, filter, count, pix)
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) < count {
+		return nil, fmt.Errorf("got %d of %d frames every %.3fs from %.3fs", len(frames), count, interval, start)
+	}
+	return frames, nil
+}
+
+// SceneFrames returns the first frame and the first frame of every scene
+// after it, up to maxFrames. A scene starts where ffmpeg's scene score, the
+// difference to the previous frame from 0 to 1, exceeds threshold.
+func (f *FFmpegWrapper) SceneFrames(ctx context.Context, v *models.Video, threshold float64, maxFrames int, filter string, pix PixelFormat) ([]image.Image, error) {
+	if threshold <= 0 || threshold >= 1 {
+		return nil, fmt.Errorf("scene threshold must be between 0 and 1, got %f", threshold)
+	}
+	selectExpr := fmt.Sprintf("eq(n,0)+gt(scene,%f)", threshold)
+	return f.rawFrames(ctx, v, "", selectExpr, filter, maxFrames, pix)
+}
+
+// rawFrames runs ffmpeg with rawvideo output scaled to models.Width x
+// models.Height and turns every frame read from the pipe into an image.
+// The selected frames of v's main video stream go through filter before
+// they are scaled. seek is the input seek, empty to start at the beginning.
+func (f *FFmpegWrapper) rawFrames(ctx context.Context, v *models.Video, seek string, selectExpr string, filter string, count int, pix PixelFormat) ([]image.Image, error) {
+	vf := fmt.Sprintf("scale=%d:%d", models.Width, models.Height)
+	if filter != "" {
+		vf = filter + "," + vf
+	}
+	if selectExpr != "" {
+		vf = fmt.Sprintf("select='%s',%s", selectExpr, vf)
+	}
+
+	var args []string
+	if seek != "" {
+		args = append(args, "-ss", seek)
+	}
+	args = append(args,
+		"-i", v.Path,
+		"-map", videoMap(v),
+		"-vf", vf,
+		"-vsync", "vfr",
+		"-frames:v", strconv.Itoa(count),
+		"-f", "rawvideo",
+		"-pix_fmt", string(pix),
+		"pipe:",
+	)
+
+	pr, pw := io.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		var err error
+		if selectExpr != "" {
+			// the frames are selected while decoding up to the whole video
+			_, err = f.decode(ctx, args, pw, float64(v.Duration))
+		} else {
+			_, err = f.ffmpeg(ctx, args, pw)
+		}
+		pw.CloseWithError(err)
+		errc <- err
+	}()
+
+	frameSize := models.Width * models.Height * pix.bytesPerPixel()
+	frames := make([]image.Image, 0, count)
+	var readErr error
+	for len(frames) < count {
+		buf := make([]byte, frameSize)
+		if _, err := io.ReadFull(pr, buf); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				readErr = err
+			}
+			break
+		}
+		frames = append(frames, rawToImage(buf, pix))
+	}
+	// let ffmpeg exit if it still has something to write
+	pr.Close()
+
+	// an error after all frames arrived is ffmpeg noticing the closed pipe
+	if err := <-errc; err != nil && len(frames) < count {
+		slog.Error("Error creating raw frames", slog.String("path", v.Path), slog.Any("error", err))
+		return nil, err
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	return frames, nil
+}
+
+func rawToImage(buf []byte, pix PixelFormat) image.Image {
+	rect := image.Rect(0, 0, models.Width, models.Height)
+	if pix == PixelGray {
+		return &image.Gray{Pix: buf, Stride: models.Width, Rect: rect}
+	}
+
+	img := image.NewRGBA(rect)
+	for i, j := 0, 0; i < len(buf); i, j = i+3, j+4 {
+		img.Pix[j] = buf[i]
+		img.Pix[j+1] = buf[i+1]
+		img.Pix[j+2] = buf[i+2]
+		img.Pix[j+3] = 0xff
+	}
+	return img
+}
@@ -0,0 +1,26 @@
+package videoprocessor
+
+import (
+	"fmt"
+
+	"govdupes/internal/models"
+)
+
+// videoMap is the -map argument for v's main video stream. Videos probed
+// before the index was recorded get the first video stream that isn't
+// cover art.
+func videoMap(v *models.Video) string {
+	if v.VideoStreamIndex >= 0 {
+		return fmt.Sprintf("0:%d", v.VideoStreamIndex)
+	}
+	return "0:V:0"
+}
+
+// audioMap is the -map argument for v's main audio stream, it matches
+// nothing rather than failing when there is no audio.
+func audioMap(v *models.Video) string {
+	if v.AudioStreamIndex >= 0 {
+		return fmt.Sprintf("0:%d", v.AudioStreamIndex)
+	}
+	return "0:a:0?"
+}
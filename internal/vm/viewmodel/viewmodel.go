@@ -11,14 +11,15 @@ import (
 	"reflect"
 	"slices"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
 	"fyne.io/fyne/v2/data/binding"
 
 	"govdupes/internal/application"
+	"govdupes/internal/config"
 	"govdupes/internal/models"
+	"govdupes/internal/quality"
 	"govdupes/internal/vm"
 )
 
@@ -86,11 +87,17 @@ func (vm *viewModel) SetData(videoData [][]*models.VideoData) {
 		}
 	}
 
+	cfg := vm.Application.Config
+	weights := quality.WeightsFromConfig(cfg)
 	var filteredGroups [][]*models.VideoData
 	for _, group := range videoData {
-		if len(group) > 1 {
-			filteredGroups = append(filteredGroups, group)
+		if len(group) <= 1 {
+			continue
+		}
+		if cfg.HideReferenceOnlyGroups && allInReferenceDirs(cfg, group) {
+			continue
 		}
+		filteredGroups = append(filteredGroups, group)
 	}
 
 	if hasAnyVideos {
@@ -132,16 +139,49 @@ func (vm *viewModel) SetData(videoData [][]*models.VideoData) {
 			HeaderText:    groupHeaderText,
 		})
 
-		for _, vd := range group {
+		hasReference := false
+		groupStart := len(vm.items)
+		scores := quality.ScoreGroup(group, weights)
+		for j, vd := range group {
+			isReference := cfg.IsReferencePath(vd.Video.Path)
+			hasReference = hasReference || isReference
 			vm.items = append(vm.items, &models.DuplicateListItemViewModel{
-				GroupIndex: i,
-				VideoData:  vd,
+				GroupIndex:   i,
+				VideoData:    vd,
+				IsReference:  isReference,
+				QualityScore: scores[j],
 			})
 		}
+
+		// duplicates of videos in a reference dir are selected straight away
+		if hasReference {
+			for _, item := range vm.items[groupStart:] {
+				item.Selected = !item.IsReference
+			}
+		}
 	}
 	vm.UpdateStatistics(filteredGroups)
 }
 
+func allInReferenceDirs(cfg *config.Config, group []*models.VideoData) bool {
+	for _, vd := range group {
+		if !cfg.IsReferencePath(vd.Video.Path) {
+			return false
+		}
+	}
+	return true
+}
+
+// protectReferenceItems unselects every video in a reference dir so that
+// automatic selections never pick them. Callers must hold vm.mutex.
+func (vm *viewModel) protectReferenceItems() {
+	for _, item := range vm.items {
+		if item.IsReference {
+			item.Selected = false
+		}
+	}
+}
+
 // GetItems safely returns a copy of vm.items for the View to read.
 func (vm *viewModel) GetItems() []*models.DuplicateListItemViewModel {
 	vm.mutex.RLock()
@@ -188,62 +228,43 @@ func (vm *viewModel) ApplyFilter(query models.SearchQuery) {
 		return
 	}
 
-	for i, it := range vm.items {
-		if it.IsColumnsHeader {
-			vm.items[i].Hidden = false
-			continue
-		}
-		if it.IsGroupHeader {
-			vm.items[i].Hidden = true
-			continue
-		}
-		if it.VideoData == nil {
-			vm.items[i].Hidden = true
+	// per row match, then per group
+	groupAny := make(map[int]bool)
+	groupAll := make(map[int]bool)
+	for _, it := range vm.items {
+		if it.IsColumnsHeader || it.IsGroupHeader {
 			continue
 		}
-		// Filter check
-		if rowMatchesQuery(it, query) {
-			vm.items[i].Hidden = false
-		} else {
-			vm.items[i].Hidden = true
+		matched := it.VideoData != nil && query.Matches(it.VideoData)
+		it.Hidden = !matched
+		if _, seen := groupAll[it.GroupIndex]; !seen {
+			groupAll[it.GroupIndex] = true
 		}
+		groupAny[it.GroupIndex] = groupAny[it.GroupIndex] || matched
+		groupAll[it.GroupIndex] = groupAll[it.GroupIndex] && matched
 	}
 
-	groupHasVisible := make(map[int]bool)
 	for _, it := range vm.items {
-		if !it.IsColumnsHeader && !it.IsGroupHeader && !it.Hidden {
-			groupHasVisible[it.GroupIndex] = true
-		}
-	}
-	for i, it := range vm.items {
-		if it.IsGroupHeader && groupHasVisible[it.GroupIndex] {
-			vm.items[i].Hidden = false
+		if it.IsColumnsHeader {
+			it.Hidden = false
+			continue
 		}
-	}
-}
 
-func rowMatchesQuery(it *models.DuplicateListItemViewModel, query models.SearchQuery) bool {
-	if it.VideoData == nil {
-		return false
-	}
-	checkStr := strings.ToLower(it.VideoData.Video.Path + " " + it.VideoData.Video.FileName)
-
-	for _, andGroup := range query.OrGroups {
-		if andGroupSatisfied(checkStr, andGroup) {
-			return true
+		var groupVisible bool
+		switch query.GroupMode {
+		case models.GroupMatchAll:
+			groupVisible = groupAll[it.GroupIndex]
+		default:
+			groupVisible = groupAny[it.GroupIndex]
 		}
-	}
-	return false
-}
 
-func andGroupSatisfied(checkStr string, andGroup []string) bool {
-	for _, s := range andGroup {
-		s = strings.ToLower(s)
-		if !strings.Contains(checkStr, s) {
-			return false
+		switch {
+		case !groupVisible:
+			it.Hidden = true
+		case it.IsGroupHeader || query.GroupMode != models.GroupMatchRows:
+			it.Hidden = false
 		}
 	}
-	return true
 }
 
 // Sorting / Flattening
@@ -578,6 +599,7 @@ func (vm *viewModel) SelectIdentical() {
 	vm.ClearSelection()
 	vm.mutex.Lock()
 	defer vm.mutex.Unlock()
+	defer vm.protectReferenceItems()
 
 	groupedItems := make(map[int][]*models.DuplicateListItemViewModel)
 	for i := range vm.items {
@@ -632,6 +654,7 @@ func (vm *viewModel) SelectAllButLargest() {
 	vm.ClearSelection()
 	vm.mutex.Lock()
 	defer vm.mutex.Unlock()
+	defer vm.protectReferenceItems()
 
 	groupMaxSize := make(map[int]int64)
 	for _, item := range vm.items {
@@ -657,6 +680,7 @@ func (vm *viewModel) SelectAllButSmallest() {
 	vm.ClearSelection()
 	vm.mutex.Lock()
 	defer vm.mutex.Unlock()
+	defer vm.protectReferenceItems()
 
 	groupMinSize := make(map[int]int64)
 	for i := range vm.items {
@@ -689,6 +713,7 @@ func (vm *viewModel) SelectAllButNewest() {
 	vm.ClearSelection()
 	vm.mutex.Lock()
 	defer vm.mutex.Unlock()
+	defer vm.protectReferenceItems()
 
 	groupMaxModified := make(map[int]time.Time)
 	for _, item := range vm.items {
@@ -715,6 +740,7 @@ func (vm *viewModel) SelectAllButOldest() {
 	vm.ClearSelection()
 	vm.mutex.Lock()
 	defer vm.mutex.Unlock()
+	defer vm.protectReferenceItems()
 
 	groupMinModified := make(map[int]time.Time)
 	for _, item := range vm.items {
@@ -747,6 +773,7 @@ func (vm *viewModel) SelectAllButHighestBitrate() {
 	vm.ClearSelection()
 	vm.mutex.Lock()
 	defer vm.mutex.Unlock()
+	defer vm.protectReferenceItems()
 
 	groupMaxBitrate := make(map[int]int)
 	for _, item := range vm.items {
@@ -768,10 +795,68 @@ func (vm *viewModel) SelectAllButHighestBitrate() {
 	}
 }
 
+// SelectAllButBestQuality keeps the member with the highest quality score
+// in each group and selects the rest.
+func (vm *viewModel) SelectAllButBestQuality() {
+	vm.ClearSelection()
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+	defer vm.protectReferenceItems()
+
+	for _, group := range vm.groupedVideoItems() {
+		best := 0
+		for i, item := range group {
+			if item.QualityScore > group[best].QualityScore {
+				best = i
+			}
+		}
+		for i, item := range group {
+			item.Selected = i != best
+		}
+	}
+}
+
+func (vm *viewModel) SelectAllSymbolicLinks() {
+	vm.ClearSelection()
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+	defer vm.protectReferenceItems()
+
+	for i := range vm.items {
+		item := vm.items[i]
+		if item.VideoData != nil && !item.IsGroupHeader && !item.IsColumnsHeader {
+			if item.VideoData.Video.IsSymbolicLink {
+				item.Selected = true
+			}
+		}
+	}
+}
+
+// SelectReferenceDuplicates selects, in every group that has a video in a
+// reference dir, all the videos outside the reference dirs.
+func (vm *viewModel) SelectReferenceDuplicates() {
+	vm.ClearSelection()
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+
+	for _, group := range vm.groupedVideoItems() {
+		hasReference := slices.ContainsFunc(group, func(item *models.DuplicateListItemViewModel) bool {
+			return item.IsReference
+		})
+		if !hasReference {
+			continue
+		}
+		for _, item := range group {
+			item.Selected = !item.IsReference
+		}
+	}
+}
+
 func (vm *viewModel) SelectAll() {
 	vm.ClearSelection()
 	vm.mutex.Lock()
 	defer vm.mutex.Unlock()
+	defer vm.protectReferenceItems()
 
 	for i := range vm.items {
 		if vm.items[i].VideoData != nil &&
@@ -782,6 +867,196 @@ func (vm *viewModel) SelectAll() {
 	}
 }
 
+// Selection rules
+// _______________
+
+// groupedVideoItems returns the video rows of vm.items grouped by GroupIndex,
+// in display order. Callers must hold vm.mutex.
+func (vm *viewModel) groupedVideoItems() [][]*models.DuplicateListItemViewModel {
+	var groups [][]*models.DuplicateListItemViewModel
+	groupPos := make(map[int]int)
+	for _, item := range vm.items {
+		if item.IsColumnsHeader || item.IsGroupHeader || item.VideoData == nil {
+			continue
+		}
+		pos, ok := groupPos[item.GroupIndex]
+		if !ok {
+			pos = len(groups)
+			groupPos[item.GroupIndex] = pos
+			groups = append(groups, nil)
+		}
+		groups[pos] = append(groups[pos], item)
+	}
+	return groups
+}
+
+func itemsToVideoData(items []*models.DuplicateListItemViewModel) []*models.VideoData {
+	videos := make([]*models.VideoData, len(items))
+	for i, item := range items {
+		videos[i] = item.VideoData
+	}
+	return videos
+}
+
+// SelectByRule selects every member of each group except the ones the rule keeps.
+func (vm *viewModel) SelectByRule(nameOrExpr string) error {
+	rule, err := vm.Application.CompileRule(nameOrExpr)
+	if err != nil {
+		return err
+	}
+
+	vm.ClearSelection()
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+	defer vm.protectReferenceItems()
+
+	for _, group := range vm.groupedVideoItems() {
+		for i, selected := range rule.Select(itemsToVideoData(group)) {
+			group[i].Selected = selected
+		}
+	}
+	slog.Info("Applied selection rule", slog.String("rule", rule.String()))
+	return nil
+}
+
+// PreviewRule describes what SelectByRule would do without changing the selection.
+func (vm *viewModel) PreviewRule(nameOrExpr string) (string, error) {
+	rule, err := vm.Application.CompileRule(nameOrExpr)
+	if err != nil {
+		return "", err
+	}
+
+	vm.mutex.RLock()
+	defer vm.mutex.RUnlock()
+
+	var groups [][]*models.VideoData
+	for _, group := range vm.groupedVideoItems() {
+		groups = append(groups, itemsToVideoData(group))
+	}
+	return fmt.Sprintf("%s\n%s", rule.String(), rule.Preview(groups).String()), nil
+}
+
+func (vm *viewModel) SaveRule(name, expr string) error {
+	return vm.Application.SaveRule(name, expr)
+}
+
+func (vm *viewModel) DeleteRule(name string) error {
+	return vm.Application.RuleStore.DeleteRule(context.Background(), name)
+}
+
+func (vm *viewModel) GetRules() ([]*models.SelectionRule, error) {
+	return vm.Application.RuleStore.GetRules(context.Background())
+}
+
+// Review decisions
+// ________________
+
+// MarkSelectedGroups records decision for every group with a selected video
+// and removes those groups from the list. It returns the number of groups marked.
+func (vm *viewModel) MarkSelectedGroups(decision models.ReviewDecisionType) (int, error) {
+	return vm.markSelected(decision, func(group, selected []*models.VideoData) []*models.VideoData {
+		return group
+	})
+}
+
+// MarkSelectedPairs marks the two selected videos of each group as not
+// duplicates. Groups without exactly two selected videos are left alone.
+func (vm *viewModel) MarkSelectedPairs() (int, error) {
+	return vm.markSelected(models.ReviewNotDuplicate, func(group, selected []*models.VideoData) []*models.VideoData {
+		if len(selected) != 2 {
+			return nil
+		}
+		return selected
+	})
+}
+
+// markSelected stores a decision for the videos pick returns from each group
+// with a selection, then drops the groups those decisions fully cover.
+func (vm *viewModel) markSelected(decision models.ReviewDecisionType, pick func(group, selected []*models.VideoData) []*models.VideoData) (int, error) {
+	vm.mutex.Lock()
+	selectedIDs := make(map[int64]struct{})
+	for _, item := range vm.items {
+		if item.Selected && item.VideoData != nil {
+			selectedIDs[item.VideoData.Video.ID] = struct{}{}
+		}
+	}
+
+	var toMark [][]*models.VideoData
+	for _, group := range vm.InterfaceToVideoData() {
+		var selected []*models.VideoData
+		for _, vd := range group {
+			if _, ok := selectedIDs[vd.Video.ID]; ok {
+				selected = append(selected, vd)
+			}
+		}
+		if len(selected) == 0 {
+			continue
+		}
+		if videos := pick(group, selected); len(videos) != 0 {
+			toMark = append(toMark, videos)
+		}
+	}
+	// the list stays usable while the decisions are written
+	vm.mutex.Unlock()
+
+	var created []*models.ReviewDecision
+	var markErr error
+	for _, videos := range toMark {
+		d, err := vm.Application.MarkReviewed(decision, videos)
+		if err != nil {
+			markErr = fmt.Errorf("marking group of %s: %w", videos[0].Video.Path, err)
+			break
+		}
+		created = append(created, d)
+	}
+	if len(created) == 0 {
+		return 0, markErr
+	}
+
+	vm.mutex.Lock()
+	var remaining [][]*models.VideoData
+	for _, group := range vm.InterfaceToVideoData() {
+		if application.ReviewedBy(group, created) == nil {
+			remaining = append(remaining, group)
+		}
+	}
+
+	vm.SetViewModelDuplicateGroups(remaining)
+
+	vm.items = vm.items[:0]
+	vm.mutex.Unlock()
+	vm.SetData(remaining)
+	slog.Info("Stored review decisions", slog.String("decision", string(decision)), slog.Int("count", len(created)))
+	return len(created), markErr
+}
+
+func (vm *viewModel) GetReviewDecisions() ([]*models.ReviewDecision, error) {
+	return vm.Application.ReviewStore.GetReviewDecisions(context.Background())
+}
+
+// RevokeReviewDecision deletes a decision. The affected videos are grouped
+// again on the next search.
+func (vm *viewModel) RevokeReviewDecision(id int64) error {
+	return vm.Application.ReviewStore.DeleteReviewDecision(context.Background(), id)
+}
+
+// Scan errors
+// ___________
+
+func (vm *viewModel) GetScanErrors(includeIgnored bool) ([]*models.ScanError, error) {
+	return vm.Application.ScanErrorStore.GetScanErrors(context.Background(), includeIgnored)
+}
+
+// RetryScanErrors forgets the errors of paths, every error when empty, so
+// the files are scanned on the next search.
+func (vm *viewModel) RetryScanErrors(paths []string) error {
+	return vm.Application.RetryScanErrors(paths)
+}
+
+func (vm *viewModel) IgnoreScanError(path string) error {
+	return vm.Application.ScanErrorStore.IgnoreScanError(context.Background(), path)
+}
+
 // Setters / Getters for bindings
 // _____________________________
 
@@ -38,8 +38,29 @@ type ViewModel interface {
 	SelectAllButNewest()
 	SelectAllButOldest()
 	SelectAllButHighestBitrate()
+	SelectAllButBestQuality()
+	SelectAllSymbolicLinks()
+	SelectReferenceDuplicates()
 	SelectAll()
 
+	// Selection rules
+	SelectByRule(nameOrExpr string) error
+	PreviewRule(nameOrExpr string) (string, error)
+	SaveRule(name, expr string) error
+	DeleteRule(name string) error
+	GetRules() ([]*models.SelectionRule, error)
+
+	// Review decisions
+	MarkSelectedGroups(decision models.ReviewDecisionType) (int, error)
+	MarkSelectedPairs() (int, error)
+	GetReviewDecisions() ([]*models.ReviewDecision, error)
+	RevokeReviewDecision(id int64) error
+
+	// Scan errors
+	GetScanErrors(includeIgnored bool) ([]*models.ScanError, error)
+	RetryScanErrors(paths []string) error
+	IgnoreScanError(path string) error
+
 	// UntypedList
 	SetDuplicateGroups(groups []any) error
 
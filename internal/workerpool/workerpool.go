@@ -0,0 +1,211 @@
+// Package workerpool runs a stage of a scan over many videos with a fixed or
+// self-tuning number of workers, optionally limited per disk.
+package workerpool
+
+import (
+	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tuneInterval is how often auto mode measures throughput and adjusts.
+const tuneInterval = 5 * time.Second
+
+// maxQueued is how many tasks wait for their device at most, across all
+// devices, before Stream stops reading tasks.
+const maxQueued = 256
+
+// Options configure a Run.
+type Options struct {
+	// Name identifies the stage in the logs
+	Name string
+	// Workers is how many tasks run at once, 0 tunes it to the observed
+	// throughput between 1 and MaxWorkers
+	Workers    int
+	MaxWorkers int
+	// PerDevice limits the tasks running on one device, 0 is no limit
+	PerDevice int
+}
+
+// Run calls work for every task and returns when all are done. device
+// returns the device a task reads from, it is only used with PerDevice.
+func Run[T any](tasks []T, opts Options, device func(T) uint64, work func(T)) {
+	if len(tasks) == 0 {
+		return
+	}
+	ch := make(chan T, len(tasks))
+	for _, t := range tasks {
+		ch <- t
+	}
+	close(ch)
+	Stream(ch, opts, device, work)
+}
+
+// Stream is Run for tasks that arrive while the pool is working: it calls
+// work for everything received on tasks and returns once tasks is closed
+// and all are done. With PerDevice the tasks of a busy device wait in its
+// queue while tasks keeps being read for the others, up to maxQueued
+// waiting tasks in all.
+func Stream[T any](tasks <-chan T, opts Options, device func(T) uint64, work func(T)) {
+	if opts.MaxWorkers <= 0 {
+		opts.MaxWorkers = 2 * runtime.NumCPU()
+	}
+
+	limit := newLimiter(opts.Workers)
+	var done atomic.Int64
+	stop := make(chan struct{})
+	if opts.Workers <= 0 {
+		limit.set(1)
+		go tune(opts, limit, &done, stop)
+	}
+
+	workers := opts.MaxWorkers
+	if opts.Workers > 0 {
+		workers = opts.Workers
+	}
+	var wg sync.WaitGroup
+	start := func(next func() (T, bool), n int) {
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					t, ok := next()
+					if !ok {
+						return
+					}
+					limit.acquire()
+					work(t)
+					limit.release()
+					done.Add(1)
+				}
+			}()
+		}
+	}
+
+	if opts.PerDevice > 0 {
+		// every device's queue can hold all queued tasks, so only the
+		// shared cap stops reading tasks and a busy disk doesn't hold up
+		// the others until maxQueued tasks wait for it
+		queued := make(chan struct{}, maxQueued)
+		queues := make(map[uint64]chan T)
+		for t := range tasks {
+			queued <- struct{}{}
+			d := device(t)
+			q, ok := queues[d]
+			if !ok {
+				q = make(chan T, maxQueued)
+				queues[d] = q
+				start(func() (T, bool) {
+					t, ok := <-q
+					if ok {
+						<-queued
+					}
+					return t, ok
+				}, min(workers, opts.PerDevice))
+			}
+			q <- t
+		}
+		for _, q := range queues {
+			close(q)
+		}
+	} else {
+		start(func() (T, bool) {
+			t, ok := <-tasks
+			return t, ok
+		}, workers)
+	}
+	wg.Wait()
+	close(stop)
+}
+
+// tune adjusts limit every tuneInterval until stop is closed.
+func tune(opts Options, limit *limiter, done *atomic.Int64, stop <-chan struct{}) {
+	t := tuner{limit: 1, max: opts.MaxWorkers, dir: 1}
+	ticker := time.NewTicker(tuneInterval)
+	defer ticker.Stop()
+	last := int64(0)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			n := done.Load()
+			rate := float64(n-last) / tuneInterval.Seconds()
+			last = n
+			workers := t.next(rate)
+			limit.set(workers)
+			slog.Debug("Tuned workers", slog.String("stage", opts.Name),
+				slog.Float64("tasksPerSecond", rate), slog.Int("workers", workers))
+		}
+	}
+}
+
+// tuner climbs towards the worker count with the best throughput: it keeps
+// adding (or removing) workers while that helps and turns around when the
+// throughput drops.
+type tuner struct {
+	limit    int
+	max      int
+	dir      int
+	lastRate float64
+}
+
+func (t *tuner) next(rate float64) int {
+	// nothing finished yet, a slow task says nothing about the count
+	if rate == 0 {
+		return t.limit
+	}
+	if t.lastRate > 0 && rate < t.lastRate*0.95 {
+		t.dir = -t.dir
+	}
+	t.lastRate = rate
+	t.limit = max(1, min(t.max, t.limit+t.dir))
+	// at either end the only way is back
+	if t.limit == 1 {
+		t.dir = 1
+	} else if t.limit == t.max {
+		t.dir = -1
+	}
+	return t.limit
+}
+
+// limiter is a semaphore whose size can change while it is held.
+type limiter struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	size   int
+	active int
+}
+
+// newLimiter returns a limiter of size n, n <= 0 doesn't limit.
+func newLimiter(n int) *limiter {
+	l := &limiter{size: n}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+func (l *limiter) acquire() {
+	l.mu.Lock()
+	for l.size > 0 && l.active >= l.size {
+		l.cond.Wait()
+	}
+	l.active++
+	l.mu.Unlock()
+}
+
+func (l *limiter) release() {
+	l.mu.Lock()
+	l.active--
+	l.mu.Unlock()
+	l.cond.Signal()
+}
+
+func (l *limiter) set(n int) {
+	l.mu.Lock()
+	l.size = n
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
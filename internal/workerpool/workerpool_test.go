@@ -0,0 +1,147 @@
+package workerpool
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunPerDevice(t *testing.T) {
+	tasks := make([]int, 40)
+	for i := range tasks {
+		tasks[i] = i
+	}
+	var mu sync.Mutex
+	running := make(map[uint64]int)
+	most := make(map[uint64]int)
+	var ran atomic.Int64
+
+	device := func(i int) uint64 { return uint64(i % 2) }
+	Run(tasks, Options{Workers: 8, PerDevice: 1}, device, func(i int) {
+		mu.Lock()
+		running[device(i)]++
+		most[device(i)] = max(most[device(i)], running[device(i)])
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running[device(i)]--
+		mu.Unlock()
+		ran.Add(1)
+	})
+
+	if ran.Load() != int64(len(tasks)) {
+		t.Errorf("Run ran %d tasks, want %d", ran.Load(), len(tasks))
+	}
+	for d, n := range most {
+		if n > 1 {
+			t.Errorf("device %d ran %d tasks at once, want at most 1", d, n)
+		}
+	}
+}
+
+func TestTunerFindsPeak(t *testing.T) {
+	// throughput grows up to 4 workers and drops after
+	rate := func(workers int) float64 {
+		if workers <= 4 {
+			return float64(workers)
+		}
+		return 4 - float64(workers-4)
+	}
+	tn := tuner{limit: 1, max: 16, dir: 1}
+	for range 20 {
+		tn.next(rate(tn.limit))
+	}
+	if tn.limit < 3 || tn.limit > 5 {
+		t.Errorf("tuner settled on %d workers, want 3 to 5", tn.limit)
+	}
+}
+
+func TestStream(t *testing.T) {
+	tasks := make(chan int)
+	go func() {
+		for i := range 100 {
+			tasks <- i
+		}
+		close(tasks)
+	}()
+
+	var sum atomic.Int64
+	Stream(tasks, Options{Workers: 4, PerDevice: 2}, func(i int) uint64 { return uint64(i % 3) }, func(i int) {
+		sum.Add(int64(i))
+	})
+	if sum.Load() != 4950 {
+		t.Errorf("Stream summed %d, want 4950", sum.Load())
+	}
+}
+
+func TestStreamBusyDevice(t *testing.T) {
+	// device 0 is stuck until every task of device 1 is done, with its
+	// tasks waiting in front of them
+	tasks := make(chan int)
+	go func() {
+		for range maxQueued - 10 {
+			tasks <- 0
+		}
+		for range 10 {
+			tasks <- 1
+		}
+		close(tasks)
+	}()
+
+	var other sync.WaitGroup
+	other.Add(10)
+	released := make(chan struct{})
+	release := sync.OnceFunc(func() { close(released) })
+	go func() {
+		other.Wait()
+		release()
+	}()
+
+	timeout := time.NewTimer(5 * time.Second)
+	defer timeout.Stop()
+	var stuck atomic.Bool
+	Stream(tasks, Options{Workers: 2, PerDevice: 1}, func(d int) uint64 { return uint64(d) }, func(d int) {
+		if d == 1 {
+			other.Done()
+			return
+		}
+		select {
+		case <-released:
+		case <-timeout.C:
+			stuck.Store(true)
+			release()
+		}
+	})
+	if stuck.Load() {
+		t.Error("device 1 waited behind the busy device 0")
+	}
+}
+
+func TestStreamQueueCap(t *testing.T) {
+	tasks := make(chan int)
+	released := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		Stream(tasks, Options{Workers: 1, PerDevice: 1}, func(int) uint64 { return 0 }, func(int) {
+			<-released
+		})
+		close(finished)
+	}()
+
+	// one task runs, maxQueued wait and one more is read before the cap
+	// stops Stream from reading
+	for i := range maxQueued + 2 {
+		tasks <- i
+	}
+	select {
+	case tasks <- 0:
+		t.Fatalf("Stream read more than %d waiting tasks", maxQueued)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(released)
+	tasks <- 0
+	close(tasks)
+	<-finished
+}
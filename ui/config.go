@@ -1,11 +1,14 @@
 package ui
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 	"strings"
 
+	"govdupes/internal/application"
 	"govdupes/internal/config"
+	"govdupes/internal/hash"
 	"govdupes/internal/vm"
 
 	"fyne.io/fyne/v2"
@@ -30,11 +33,43 @@ type formStruct struct {
 	SilentFFmpeg     bool
 	FilesizeCutoff   int64
 	DetectionMethod  string
+	HideRefOnly      bool
+	QualityCodec     float64
+	QualityRes       float64
+	QualityBitrate   float64
+	QualityFPS       float64
+	QualityDuration  float64
+	QualityAudio     float64
+	TemporalMinMatch int
+	TemporalMaxDist  int
+	HashAlgorithms   string
+	MinHashAgreement int
+	MatchMode        string
+	MatchTransforms  bool
+	SceneThreshold   float64
+	SceneMinMatch    float64
+	AutoCrop         bool
+	CenterCrop       float64
+	MaskCorners      string
+	MaskSize         float64
+	FFmpegPath       string
+	FFprobePath      string
+	ProcessTimeout   int
+	MaxProcesses     int
+	ProcessNice      int
+	IONiceClass      int
+	WalkWorkers      int
+	ProbeWorkers     int
+	HashWorkers      int
+	DeviceWorkers    int
+	DBBatchSize      int
+	DBRetries        int
 }
 
 // creates a UI for reading/writing the config.Config object.
 // also allows the user to select & create the HashType and SearchMethod
-func buildConfigTab(cfg *config.Config, w fyne.Window, checkWidget *widget.Check, myViewModel vm.ViewModel) fyne.CanvasObject {
+func buildConfigTab(appInstance *application.App, w fyne.Window, checkWidget *widget.Check, myViewModel vm.ViewModel) fyne.CanvasObject {
+	cfg := appInstance.Config
 	formStruct := ConvertConfigToFormStruct(cfg)
 	formData := binding.BindStruct(&formStruct)
 	form := newFormWithData(formData)
@@ -71,15 +106,30 @@ func buildConfigTab(cfg *config.Config, w fyne.Window, checkWidget *widget.Check
 		}
 	})
 
+	// reference dirs are marked with the check box next to each starting dir
+	referenceDirs := make(map[string]bool)
+	for _, dir := range cfg.ReferenceDirs {
+		referenceDirs[dir] = true
+	}
+
 	dirList := widget.NewListWithData(
 		startingDirs,
 		func() fyne.CanvasObject {
-			return container.NewBorder(nil, nil, nil, nil, widget.NewLabel(""))
+			return container.NewBorder(nil, nil, widget.NewCheck("Reference", nil), nil, widget.NewLabel(""))
 		},
 		func(item binding.DataItem, obj fyne.CanvasObject) {
 			f := item.(binding.String)
-			lbl := obj.(*fyne.Container).Objects[0].(*widget.Label)
+			c := obj.(*fyne.Container)
+			lbl := c.Objects[0].(*widget.Label)
 			lbl.Bind(f)
+
+			dir, _ := f.Get()
+			check := c.Objects[1].(*widget.Check)
+			check.OnChanged = nil
+			check.SetChecked(referenceDirs[dir])
+			check.OnChanged = func(checked bool) {
+				referenceDirs[dir] = checked
+			}
 		},
 	)
 
@@ -137,6 +187,48 @@ func buildConfigTab(cfg *config.Config, w fyne.Window, checkWidget *widget.Check
 		cfg.SilentFFmpeg = formStruct.SilentFFmpeg
 		cfg.FilesizeCutoff = formStruct.FilesizeCutoff
 		cfg.DetectionMethod = formStruct.DetectionMethod
+		cfg.HideReferenceOnlyGroups = formStruct.HideRefOnly
+		cfg.QualityCodecWeight = formStruct.QualityCodec
+		cfg.QualityResolutionWeight = formStruct.QualityRes
+		cfg.QualityBitrateWeight = formStruct.QualityBitrate
+		cfg.QualityFrameRateWeight = formStruct.QualityFPS
+		cfg.QualityDurationWeight = formStruct.QualityDuration
+		cfg.QualityAudioWeight = formStruct.QualityAudio
+		cfg.TemporalMinMatchSeconds = formStruct.TemporalMinMatch
+		cfg.TemporalMaxFrameDistance = formStruct.TemporalMaxDist
+		cfg.MinHashAgreement = formStruct.MinHashAgreement
+		cfg.MatchMode = formStruct.MatchMode
+		cfg.MatchTransforms = formStruct.MatchTransforms
+		cfg.SceneThreshold = formStruct.SceneThreshold
+		cfg.AutoCrop = formStruct.AutoCrop
+		cfg.CenterCrop = formStruct.CenterCrop
+		cfg.MaskCorners = splitAndTrim(formStruct.MaskCorners)
+		cfg.MaskSize = formStruct.MaskSize
+		cfg.FFmpegPath = formStruct.FFmpegPath
+		cfg.FFprobePath = formStruct.FFprobePath
+		cfg.ProcessTimeout = formStruct.ProcessTimeout
+		cfg.MaxProcesses = formStruct.MaxProcesses
+		cfg.ProcessNice = formStruct.ProcessNice
+		cfg.ProcessIONiceClass = formStruct.IONiceClass
+		cfg.WalkWorkers = formStruct.WalkWorkers
+		cfg.ProbeWorkers = formStruct.ProbeWorkers
+		cfg.HashWorkers = formStruct.HashWorkers
+		cfg.DeviceWorkers = formStruct.DeviceWorkers
+		cfg.DBBatchSize = formStruct.DBBatchSize
+		cfg.DBRetries = formStruct.DBRetries
+
+		if formStruct.SceneMinMatch <= 0 || formStruct.SceneMinMatch > 1 {
+			dialog.ShowError(fmt.Errorf("SceneMinMatch must be above 0 and at most 1, got %g", formStruct.SceneMinMatch), w)
+			return
+		}
+		cfg.SceneMinMatch = formStruct.SceneMinMatch
+
+		algorithms := splitAndTrim(formStruct.HashAlgorithms)
+		if _, err := hash.ExtraHashers(algorithms); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		cfg.HashAlgorithms = algorithms
 
 		// read out each directory from the binding
 		length := startingDirs.Length()
@@ -149,12 +241,22 @@ func buildConfigTab(cfg *config.Config, w fyne.Window, checkWidget *widget.Check
 		}
 		cfg.StartingDirs = dirs
 
+		var refDirs []string
+		for _, dir := range dirs {
+			if referenceDirs[dir] {
+				refDirs = append(refDirs, dir)
+			}
+		}
+		cfg.ReferenceDirs = refDirs
+
 		err := config.ValidateStartingDirs(cfg)
 		if err != nil {
 			dialog.ShowError(err, w)
 			return
 		}
 
+		appInstance.ReloadVideoProcessor()
+
 		slog.Info("Updated real config.Config from UI", "cfg", cfg)
 	}
 
@@ -177,6 +279,37 @@ func ConvertConfigToFormStruct(cfg *config.Config) formStruct {
 		SilentFFmpeg:     cfg.SilentFFmpeg,
 		FilesizeCutoff:   cfg.FilesizeCutoff,
 		DetectionMethod:  cfg.DetectionMethod,
+		HideRefOnly:      cfg.HideReferenceOnlyGroups,
+		QualityCodec:     cfg.QualityCodecWeight,
+		QualityRes:       cfg.QualityResolutionWeight,
+		QualityBitrate:   cfg.QualityBitrateWeight,
+		QualityFPS:       cfg.QualityFrameRateWeight,
+		QualityDuration:  cfg.QualityDurationWeight,
+		QualityAudio:     cfg.QualityAudioWeight,
+		TemporalMinMatch: cfg.TemporalMinMatchSeconds,
+		TemporalMaxDist:  cfg.TemporalMaxFrameDistance,
+		HashAlgorithms:   strings.Join(cfg.HashAlgorithms, ","),
+		MinHashAgreement: cfg.MinHashAgreement,
+		MatchMode:        cfg.MatchMode,
+		MatchTransforms:  cfg.MatchTransforms,
+		SceneThreshold:   cfg.SceneThreshold,
+		SceneMinMatch:    cfg.SceneMinMatch,
+		AutoCrop:         cfg.AutoCrop,
+		CenterCrop:       cfg.CenterCrop,
+		MaskCorners:      strings.Join(cfg.MaskCorners, ","),
+		MaskSize:         cfg.MaskSize,
+		FFmpegPath:       cfg.FFmpegPath,
+		FFprobePath:      cfg.FFprobePath,
+		ProcessTimeout:   cfg.ProcessTimeout,
+		MaxProcesses:     cfg.MaxProcesses,
+		ProcessNice:      cfg.ProcessNice,
+		IONiceClass:      cfg.ProcessIONiceClass,
+		WalkWorkers:      cfg.WalkWorkers,
+		ProbeWorkers:     cfg.ProbeWorkers,
+		HashWorkers:      cfg.HashWorkers,
+		DeviceWorkers:    cfg.DeviceWorkers,
+		DBBatchSize:      cfg.DBBatchSize,
+		DBRetries:        cfg.DBRetries,
 	}
 }
 
@@ -193,9 +326,12 @@ func newFormWithData(data binding.DataMap) *widget.Form {
 			items[i] = widget.NewFormItem(k, widget.NewLabel(err.Error()))
 			continue
 		}
-		if k == "DetectionMethod" {
-			items[i] = widget.NewFormItem(k, createDetectionSelect(sub))
-		} else {
+		switch k {
+		case "DetectionMethod":
+			items[i] = widget.NewFormItem(k, createSelect(sub, []string{"SlowPhash", "FastPhash", "TemporalPhash", "ScenePhash"}))
+		case "MatchMode":
+			items[i] = widget.NewFormItem(k, createSelect(sub, []string{"video", "audio", "both", "either"}))
+		default:
 			items[i] = widget.NewFormItem(k, createBoundItem(sub))
 		}
 	}
@@ -210,6 +346,8 @@ func createBoundItem(v binding.DataItem) fyne.CanvasObject {
 		return widget.NewCheckWithData("", val)
 	case binding.Int:
 		return widget.NewEntryWithData(binding.IntToString(val))
+	case binding.Float:
+		return widget.NewEntryWithData(binding.FloatToString(val))
 	case binding.String:
 		return widget.NewEntryWithData(val)
 	default:
@@ -217,13 +355,13 @@ func createBoundItem(v binding.DataItem) fyne.CanvasObject {
 	}
 }
 
-func createDetectionSelect(data binding.DataItem) fyne.CanvasObject {
+func createSelect(data binding.DataItem, options []string) fyne.CanvasObject {
 	strBinding, ok := data.(binding.String)
 	if !ok {
 		return widget.NewLabel("Invalid binding")
 	}
 
-	selectWidget := widget.NewSelect([]string{"SlowPhash", "FastPhash"}, func(selected string) {
+	selectWidget := widget.NewSelect(options, func(selected string) {
 		strBinding.Set(selected)
 	})
 
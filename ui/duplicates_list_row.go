@@ -32,7 +32,7 @@ type DuplicatesListRow struct {
 	screenshotContainer *fyne.Container
 	pathText            *widget.Label
 	statsLabel          *fyne.Container
-	codecsText          *canvas.Text
+	codecsLabel         *fyne.Container
 	linksLabel          *fyne.Container
 	videoLayout         *fyne.Container
 
@@ -99,8 +99,7 @@ func NewDuplicatesListRow(onTapped func(itemID int, selected bool)) *DuplicatesL
 	row.pathText.Wrapping = fyne.TextWrapBreak
 
 	row.statsLabel = container.NewVBox()
-	row.codecsText = canvas.NewText("", color.White)
-	row.codecsText.Alignment = fyne.TextAlignLeading
+	row.codecsLabel = container.NewVBox()
 	row.linksLabel = container.NewVBox()
 
 	col1 := wrapWithBorder(
@@ -116,7 +115,7 @@ func NewDuplicatesListRow(onTapped func(itemID int, selected bool)) *DuplicatesL
 		color.RGBA{75, 0, 130, 255},
 	)
 	col4 := wrapWithBorder(
-		container.New(layout.NewGridWrapLayout(fyne.NewSize(100, 120)), newLeftAlignedContainer(row.codecsText)),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(100, 120)), row.codecsLabel),
 		color.RGBA{240, 230, 140, 255},
 	)
 	col5 := wrapWithBorder(
@@ -218,7 +217,14 @@ func (r *DuplicatesListRow) updateVideoRow(item *models.DuplicateListItemViewMod
 	r.screenshotContainer.Refresh()
 
 	// Path
-	r.pathText.SetText(vd.Video.Path)
+	path := vd.Video.Path
+	if item.IsReference {
+		path = "[reference] " + path
+	}
+	for _, relation := range vd.Relations {
+		path += "\n" + relation
+	}
+	r.pathText.SetText(path)
 
 	// Stats
 	r.statsLabel.Objects = []fyne.CanvasObject{
@@ -228,13 +234,18 @@ func (r *DuplicatesListRow) updateVideoRow(item *models.DuplicateListItemViewMod
 		newLeftAlignedCanvasText(fmt.Sprintf("%.2f fps", vd.Video.AvgFrameRate), color.White),
 		newLeftAlignedCanvasText(fmt.Sprintf("%dx%d", vd.Video.Width, vd.Video.Height), color.White),
 		newLeftAlignedCanvasText(formatDuration(vd.Video.Duration), color.White),
+		newLeftAlignedCanvasText(fmt.Sprintf("Quality: %.2f", item.QualityScore), color.White),
 		layout.NewSpacer(),
 	}
 	r.statsLabel.Refresh()
 
 	// Codecs
-	r.codecsText.Text = fmt.Sprintf("%s / %s", vd.Video.VideoCodec, vd.Video.AudioCodec)
-	r.codecsText.Refresh()
+	r.codecsLabel.Objects = []fyne.CanvasObject{layout.NewSpacer()}
+	for _, line := range codecLines(&vd.Video) {
+		r.codecsLabel.Add(newLeftAlignedCanvasText(line, color.White))
+	}
+	r.codecsLabel.Add(layout.NewSpacer())
+	r.codecsLabel.Refresh()
 
 	// Links
 	r.linksLabel.Objects = []fyne.CanvasObject{
@@ -249,6 +260,46 @@ func (r *DuplicatesListRow) updateVideoRow(item *models.DuplicateListItemViewMod
 	r.videoLayout.Refresh()
 }
 
+// codecLines describes the streams and container of v, leaving out what
+// ffprobe didn't report.
+func codecLines(v *models.Video) []string {
+	lines := []string{fmt.Sprintf("%s / %s", v.VideoCodec, v.AudioCodec)}
+	if v.VideoProfile != "" {
+		lines = append(lines, v.VideoProfile)
+	}
+	if v.PixFmt != "" {
+		pix := v.PixFmt
+		if v.IsHDR() {
+			pix += " HDR"
+		}
+		lines = append(lines, pix)
+	}
+	if v.AudioChannels > 0 {
+		audio := fmt.Sprintf("%dch", v.AudioChannels)
+		if v.AudioStreams > 1 {
+			audio = fmt.Sprintf("%d tracks, %s", v.AudioStreams, audio)
+		}
+		if v.AudioLanguages != "" {
+			audio += " " + v.AudioLanguages
+		}
+		lines = append(lines, audio)
+	}
+	if v.SubtitleTracks > 0 {
+		subs := fmt.Sprintf("%d subs", v.SubtitleTracks)
+		if v.SubtitleLanguages != "" {
+			subs += " " + v.SubtitleLanguages
+		}
+		lines = append(lines, subs)
+	}
+	if v.Rotation != 0 {
+		lines = append(lines, fmt.Sprintf("rotated %d°", v.Rotation))
+	}
+	if v.Container != "" {
+		lines = append(lines, v.Container)
+	}
+	return lines
+}
+
 type duplicatesListRowRenderer struct {
 	row        *DuplicatesListRow
 	background *canvas.Rectangle
@@ -0,0 +1,101 @@
+package ui
+
+import (
+	"fmt"
+	"log/slog"
+
+	"govdupes/internal/models"
+	"govdupes/internal/vm"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// buildErrorsTab lists the files that failed to scan and lets the user retry
+// or ignore them.
+func buildErrorsTab(vm vm.ViewModel, w fyne.Window) fyne.CanvasObject {
+	var scanErrors []*models.ScanError
+	showIgnored := false
+
+	list := widget.NewList(
+		func() int { return len(scanErrors) },
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("")
+			label.Wrapping = fyne.TextWrapWord
+			buttons := container.NewHBox(widget.NewButton("Retry", nil), widget.NewButton("Ignore", nil))
+			return container.NewBorder(nil, nil, nil, buttons, label)
+		},
+		nil,
+	)
+
+	reload := func() {
+		var err error
+		scanErrors, err = vm.GetScanErrors(showIgnored)
+		if err != nil {
+			slog.Error("Failed to load scan errors", "error", err)
+		}
+		list.Refresh()
+	}
+
+	list.UpdateItem = func(id widget.ListItemID, obj fyne.CanvasObject) {
+		if id >= len(scanErrors) {
+			return
+		}
+		e := scanErrors[id]
+		row := obj.(*fyne.Container)
+		label := row.Objects[0].(*widget.Label)
+		buttons := row.Objects[1].(*fyne.Container)
+		retryButton := buttons.Objects[0].(*widget.Button)
+		ignoreButton := buttons.Objects[1].(*widget.Button)
+
+		text := fmt.Sprintf("%s  [%s]  %s\n%s", e.OccurredAt.Format("2006-01-02 15:04"), e.Stage, e.Path, e.Error)
+		if e.Ignored {
+			text += "\n(ignored)"
+			ignoreButton.Disable()
+		} else {
+			ignoreButton.Enable()
+		}
+		label.SetText(text)
+		retryButton.OnTapped = func() {
+			if err := vm.RetryScanErrors([]string{e.Path}); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			reload()
+		}
+		ignoreButton.OnTapped = func() {
+			if err := vm.IgnoreScanError(e.Path); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			reload()
+		}
+		list.SetItemHeight(id, label.MinSize().Height)
+	}
+
+	refreshButton := widget.NewButton("Refresh", reload)
+	retryAllButton := widget.NewButton("Retry all", func() {
+		dialog.ShowConfirm("Retry all", "Scan every failed file again on the next search?", func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := vm.RetryScanErrors(nil); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			reload()
+		}, w)
+	})
+	ignoredCheck := widget.NewCheck("Show ignored", func(checked bool) {
+		showIgnored = checked
+		reload()
+	})
+	hint := widget.NewLabel("Failed files are skipped until they change or are retried.")
+	reload()
+
+	scroll := container.NewVScroll(list)
+	scroll.SetMinSize(fyne.NewSize(1000, 700))
+	return container.NewBorder(container.NewHBox(refreshButton, retryAllButton, ignoredCheck, hint), nil, nil, nil, scroll)
+}
@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"govdupes/internal/models"
+	"govdupes/internal/vm"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// buildReviewTab lists the stored review decisions and lets the user revoke them.
+func buildReviewTab(vm vm.ViewModel, w fyne.Window) fyne.CanvasObject {
+	var decisions []*models.ReviewDecision
+
+	list := widget.NewList(
+		func() int { return len(decisions) },
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("")
+			label.Wrapping = fyne.TextWrapWord
+			return container.NewBorder(nil, nil, nil, widget.NewButton("Revoke", nil), label)
+		},
+		nil,
+	)
+
+	reload := func() {
+		var err error
+		decisions, err = vm.GetReviewDecisions()
+		if err != nil {
+			slog.Error("Failed to load review decisions", "error", err)
+		}
+		list.Refresh()
+	}
+
+	list.UpdateItem = func(id widget.ListItemID, obj fyne.CanvasObject) {
+		if id >= len(decisions) {
+			return
+		}
+		d := decisions[id]
+		row := obj.(*fyne.Container)
+		label := row.Objects[0].(*widget.Label)
+		button := row.Objects[1].(*widget.Button)
+
+		label.SetText(fmt.Sprintf("%s  [%s]  %d videos\n%s",
+			d.CreatedAt.Format("2006-01-02 15:04"), d.Decision, len(d.VideohashIDs), strings.Join(d.Paths, "\n")))
+		button.OnTapped = func() {
+			if err := vm.RevokeReviewDecision(d.ID); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			reload()
+		}
+		list.SetItemHeight(id, label.MinSize().Height)
+	}
+
+	refreshButton := widget.NewButton("Refresh", reload)
+	hint := widget.NewLabel("Revoked decisions take effect on the next search.")
+	reload()
+
+	scroll := container.NewVScroll(list)
+	scroll.SetMinSize(fyne.NewSize(1000, 700))
+	return container.NewBorder(container.NewHBox(refreshButton, hint), nil, nil, nil, scroll)
+}
@@ -3,14 +3,16 @@ package ui
 import (
 	"log/slog"
 
+	"govdupes/internal/models"
 	"govdupes/internal/vm"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 )
 
-func buildSortSelectDeleteTab(duplicatesView *DuplicatesListView, vm vm.ViewModel) fyne.CanvasObject {
+func buildSortSelectDeleteTab(duplicatesView *DuplicatesListView, vm vm.ViewModel, w fyne.Window) fyne.CanvasObject {
 	// Delete
 	deleteOptions := []string{
 		"Delete from list",
@@ -53,7 +55,9 @@ func buildSortSelectDeleteTab(duplicatesView *DuplicatesListView, vm vm.ViewMode
 		"Select all but the newest",
 		"Select all but the oldest",
 		"Select all but the highest bitrate",
+		"Select all but the best quality",
 		"Select all symbolic links",
+		"Select all outside reference dirs",
 		"Select all",
 	}
 	selectLabel := widget.NewLabel("Select")
@@ -76,8 +80,12 @@ func buildSortSelectDeleteTab(duplicatesView *DuplicatesListView, vm vm.ViewMode
 			duplicatesView.vm.SelectAllButOldest()
 		case "Select all but the highest bitrate":
 			duplicatesView.vm.SelectAllButHighestBitrate()
+		case "Select all but the best quality":
+			duplicatesView.vm.SelectAllButBestQuality()
 		case "Select all symbolic links":
-			// duplicatesView.vm.SelectAllSymbolicLinks()
+			duplicatesView.vm.SelectAllSymbolicLinks()
+		case "Select all outside reference dirs":
+			duplicatesView.vm.SelectReferenceDuplicates()
 		case "Select all":
 			duplicatesView.vm.SelectAll()
 		}
@@ -125,6 +133,119 @@ func buildSortSelectDeleteTab(duplicatesView *DuplicatesListView, vm vm.ViewMode
 		deleteLabel, deleteDropdown, deleteButton,
 		hardlinkLabel, hardlinkButton,
 		selectLabel, selectDropdown, selectButton,
+		buildRuleSection(duplicatesView, vm, w),
+		buildMarkSection(duplicatesView, vm, w),
 	)
 	return content
 }
+
+// buildRuleSection lets the user write, test, apply and save selection rules.
+func buildRuleSection(duplicatesView *DuplicatesListView, vm vm.ViewModel, w fyne.Window) fyne.CanvasObject {
+	ruleLabel := widget.NewLabel("Rule (keeps the best members of each group, selects the rest)")
+	ruleEntry := widget.NewMultiLineEntry()
+	ruleEntry.SetPlaceHolder(`prefer resolution desc, bitrate desc, path contains "/keep/", codec = hevc; keep 1`)
+	ruleEntry.SetMinRowsVisible(2)
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Rule name")
+
+	savedRules := widget.NewSelect(nil, nil)
+	savedRules.PlaceHolder = "Saved rules"
+	expressions := make(map[string]string)
+	reloadRules := func() {
+		saved, err := vm.GetRules()
+		if err != nil {
+			slog.Error("Failed to load rules", "error", err)
+			return
+		}
+		names := make([]string, 0, len(saved))
+		clear(expressions)
+		for _, r := range saved {
+			names = append(names, r.Name)
+			expressions[r.Name] = r.Expression
+		}
+		savedRules.SetOptions(names)
+	}
+	savedRules.OnChanged = func(name string) {
+		if expr, ok := expressions[name]; ok {
+			ruleEntry.SetText(expr)
+			nameEntry.SetText(name)
+		}
+	}
+	reloadRules()
+
+	testButton := widget.NewButton("Test", func() {
+		preview, err := vm.PreviewRule(ruleEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		dialog.ShowInformation("Rule preview", preview, w)
+	})
+	applyButton := widget.NewButton("Apply", func() {
+		if err := vm.SelectByRule(ruleEntry.Text); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		duplicatesView.Refresh()
+	})
+	saveButton := widget.NewButton("Save", func() {
+		if err := vm.SaveRule(nameEntry.Text, ruleEntry.Text); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		reloadRules()
+		savedRules.SetSelected(nameEntry.Text)
+	})
+	deleteButton := widget.NewButton("Delete", func() {
+		if savedRules.Selected == "" {
+			return
+		}
+		if err := vm.DeleteRule(savedRules.Selected); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		savedRules.ClearSelected()
+		reloadRules()
+	})
+
+	return container.NewVBox(
+		ruleLabel,
+		ruleEntry,
+		container.NewGridWithColumns(2, savedRules, nameEntry),
+		container.NewGridWithColumns(4, testButton, applyButton, saveButton, deleteButton),
+	)
+}
+
+// buildMarkSection stores review decisions for the groups with a selection.
+func buildMarkSection(duplicatesView *DuplicatesListView, vm vm.ViewModel, w fyne.Window) fyne.CanvasObject {
+	markOptions := []string{
+		"Mark groups with a selection as not duplicates",
+		"Mark selected pair (per group) as not duplicates",
+		"Mark groups with a selection as reviewed (keep all)",
+	}
+	markLabel := widget.NewLabel("Review (remembered across scans, revoke in the Review tab)")
+	markDropdown := widget.NewSelect(markOptions, nil)
+	markDropdown.PlaceHolder = "Select an option"
+	markButton := widget.NewButton("Mark", func() {
+		var n int
+		var err error
+		switch markDropdown.Selected {
+		case "Mark groups with a selection as not duplicates":
+			n, err = vm.MarkSelectedGroups(models.ReviewNotDuplicate)
+		case "Mark selected pair (per group) as not duplicates":
+			n, err = vm.MarkSelectedPairs()
+		case "Mark groups with a selection as reviewed (keep all)":
+			n, err = vm.MarkSelectedGroups(models.ReviewKeepAll)
+		default:
+			return
+		}
+		if err != nil {
+			dialog.ShowError(err, w)
+		}
+		slog.Info("Marked groups", "count", n)
+		duplicatesView.Refresh()
+	})
+
+	return container.NewVBox(markLabel, markDropdown, markButton)
+}
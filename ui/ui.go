@@ -1,15 +1,20 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image/color"
 	"log/slog"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"govdupes/internal/application"
 	"govdupes/internal/models"
+	"govdupes/internal/progress"
+	"govdupes/internal/rules"
 	"govdupes/internal/vm"
 
 	"fyne.io/fyne/v2"
@@ -38,12 +43,14 @@ func CreateUI(appInstance *application.App, vm vm.ViewModel) {
 
 	// Tabs
 	themeTab := buildThemeTab(a)
-	sortSelectTab := buildSortSelectDeleteTab(duplicatesView, vm)
+	sortSelectTab := buildSortSelectDeleteTab(duplicatesView, vm, window)
 	filterForm, checkWidget := buildFilter(duplicatesView)
 
-	configTab := buildConfigTab(appInstance.Config, window, checkWidget, vm)
+	configTab := buildConfigTab(appInstance, window, checkWidget, vm)
 	searchTab := buildSearchTab(appInstance, window, vm)
 	statisticsTab := buildStatisticsTab(vm)
+	reviewTab := buildReviewTab(vm, window)
+	errorsTab := buildErrorsTab(vm, window)
 
 	// Tabs section
 	tabs := container.NewAppTabs(
@@ -52,6 +59,8 @@ func CreateUI(appInstance *application.App, vm vm.ViewModel) {
 		container.NewTabItem("Statistics", statisticsTab),
 		container.NewTabItem("Theme", themeTab),
 		container.NewTabItem("Sort/Select/Delete", sortSelectTab),
+		container.NewTabItem("Review", reviewTab),
+		container.NewTabItem("Errors", errorsTab),
 		container.NewTabItem("Settings", configTab),
 	)
 	tabs.SetTabLocation(container.TabLocationTop)
@@ -103,40 +112,117 @@ func buildSearchTab(appInstance *application.App, parent fyne.Window, vm vm.View
 	labelFileCount := widget.NewLabelWithData(vm.GetFileCountBind())
 	labelAcceptedFiles := widget.NewLabelWithData(vm.GetAcceptedFilesBind())
 
-	searchBtn := container.NewCenter(
-		widget.NewButtonWithIcon("Search", theme.Icon(theme.IconNameSearch), func() {
-			slog.Info("Search started!")
-
-			clockWidget := widget.NewLabel("")
-			d := dialog.NewCustomWithoutButtons(
-				"Searching...",
-				container.NewVBox(clockWidget, labelFileCount, labelAcceptedFiles,
-					getInfoLabelBar, genPHashesLabelBar),
-				parent,
-			)
-
-			c := clock{}
-			c.set()
-			d.Show()
-
-			stopChan := make(chan struct{})
-			go runClock(&c, clockWidget, stopChan)
-
-			err := appInstance.Search(vm)
-			if err != nil {
+	var resumeBtn *widget.Button
+	runSearch := func(resume bool) {
+		slog.Info("Search started!", slog.Bool("resume", resume))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		clockWidget := widget.NewLabel("")
+		stagesLabel := widget.NewLabel("")
+		var cancelBtn *widget.Button
+		cancelBtn = widget.NewButton("Cancel", func() {
+			cancelBtn.Disable()
+			cancel()
+		})
+		d := dialog.NewCustomWithoutButtons(
+			"Searching...",
+			container.NewVBox(clockWidget, labelFileCount, labelAcceptedFiles,
+				getInfoLabelBar, genPHashesLabelBar, stagesLabel, cancelBtn),
+			parent,
+		)
+
+		c := clock{}
+		c.set()
+		d.Show()
+
+		tracker := progress.NewTracker()
+		stopChan := make(chan struct{})
+		go runClock(&c, clockWidget, stopChan, func() {
+			showProgress(tracker, vm, stagesLabel)
+		})
+
+		// off the event loop so Cancel can be clicked
+		go func() {
+			defer cancel()
+			err := appInstance.Search(ctx, vm, resume, tracker)
+			if err != nil && !errors.Is(err, context.Canceled) {
 				slog.Error("Error calling search", "error", err)
 			}
 
 			close(stopChan)
 			d.Hide()
 			vm.ResetSearchBindings()
+			refreshResumeButton(appInstance, resumeBtn)
+		}()
+	}
+
+	resumeBtn = widget.NewButtonWithIcon("", theme.Icon(theme.IconNameMediaPlay), func() {
+		runSearch(true)
+	})
+	refreshResumeButton(appInstance, resumeBtn)
+
+	searchBtn := container.NewCenter(container.NewVBox(
+		widget.NewButtonWithIcon("Search", theme.Icon(theme.IconNameSearch), func() {
+			runSearch(false)
 		}),
-	)
+		resumeBtn,
+	))
 
 	searchTab := container.NewBorder(searchBtn, nil, nil, nil)
 	return searchTab
 }
 
+// refreshResumeButton shows btn while an interrupted search can be resumed.
+func refreshResumeButton(appInstance *application.App, btn *widget.Button) {
+	job, err := appInstance.PendingScan()
+	if err != nil {
+		slog.Error("Failed to look for an interrupted scan", slog.Any("error", err))
+	}
+	if job == nil {
+		btn.Hide()
+		return
+	}
+	btn.SetText(fmt.Sprintf("Resume previous scan (%s remaining)", formatCount(job.Remaining)))
+	btn.Show()
+}
+
+// formatCount formats n with thousands separators, e.g. 3,412.
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// maxActiveShown is how many of the files being worked on are listed.
+const maxActiveShown = 8
+
+// showProgress puts the walk count and the progress bars in vm and a line
+// per later stage, with the files the workers are on, in stagesLabel.
+func showProgress(tracker *progress.Tracker, vm vm.ViewModel, stagesLabel *widget.Label) {
+	vm.UpdateFileCount(tracker.Stage(progress.Walk).String())
+	vm.UpdateGetFileInfoProgress(tracker.Stage(progress.Probe).Fraction())
+	vm.UpdateGenPHashesProgress(tracker.Stage(progress.Hash).Fraction())
+
+	var lines, active []string
+	for _, st := range tracker.Snapshot() {
+		if st.Stage == progress.Walk {
+			continue
+		}
+		lines = append(lines, st.String())
+		active = append(active, st.Active...)
+	}
+	if len(active) > maxActiveShown {
+		active = append(active[:maxActiveShown], fmt.Sprintf("and %d more", len(active)-maxActiveShown))
+	}
+	if len(active) > 0 {
+		lines = append(lines, "", "Working on:")
+		lines = append(lines, active...)
+	}
+	stagesLabel.SetText(strings.Join(lines, "\n"))
+}
+
 // timer to show elapsed time in a label
 type clock struct {
 	t time.Time
@@ -152,13 +238,14 @@ func (c *clock) update(clockWidget *widget.Label) {
 	clockWidget.SetText(fmt.Sprintf("Time elapsed: %s", tStr))
 }
 
-func runClock(c *clock, clockWidget *widget.Label, stopChan chan struct{}) {
+func runClock(c *clock, clockWidget *widget.Label, stopChan chan struct{}, onTick func()) {
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
 			c.update(clockWidget)
+			onTick()
 		case <-stopChan:
 			return
 		}
@@ -175,13 +262,34 @@ func formatDuration(seconds float32) string {
 
 func buildFilter(duplicatesView *DuplicatesListView) (fyne.CanvasObject, *widget.Check) {
 	filterEntry := widget.NewEntry()
-	filterEntry.SetPlaceHolder("Enter path/file search...")
+	filterEntry.SetPlaceHolder(`Enter path/file search or predicates, e.g. codec:hevc size>1GB -path:"/tv/" | res>=1080`)
+
+	groupModes := map[string]models.GroupMatchMode{
+		"Show matching rows":          models.GroupMatchRows,
+		"Show groups with any match":  models.GroupMatchAny,
+		"Show groups where all match": models.GroupMatchAll,
+	}
+	groupModeSelect := widget.NewSelect([]string{
+		"Show matching rows",
+		"Show groups with any match",
+		"Show groups where all match",
+	}, nil)
+	groupModeSelect.SetSelected("Show matching rows")
+
+	errorLabel := widget.NewLabel("")
+	errorLabel.Hide()
 
 	filterButton := widget.NewButton("Apply", func() {
 		slog.Info("Filter applied", slog.String("filter", filterEntry.Text))
 
-		// parseSearchQuery: a helper that returns a viewmodel.SearchQuery
-		query := parseSearchQuery(filterEntry.Text)
+		query, err := rules.ParseSearchQuery(filterEntry.Text)
+		if err != nil {
+			errorLabel.SetText(err.Error())
+			errorLabel.Show()
+			return
+		}
+		errorLabel.Hide()
+		query.GroupMode = groupModes[groupModeSelect.Selected]
 
 		duplicatesView.ApplyFilter(query)
 	})
@@ -189,6 +297,8 @@ func buildFilter(duplicatesView *DuplicatesListView) (fyne.CanvasObject, *widget
 	filterForm := container.NewVBox(
 		widget.NewLabel("Filter:"),
 		filterEntry,
+		groupModeSelect,
+		errorLabel,
 		filterButton,
 	)
 
@@ -208,17 +318,6 @@ func buildFilter(duplicatesView *DuplicatesListView) (fyne.CanvasObject, *widget
 	return filterForm, checkWidget
 }
 
-// splits the user input into tokens, creating a single AND-group.
-func parseSearchQuery(text string) models.SearchQuery {
-	if text == "" {
-		return models.SearchQuery{}
-	}
-	tokens := strings.Fields(text) // split on spaces
-	return models.SearchQuery{
-		OrGroups: [][]string{tokens},
-	}
-}
-
 func buildStatisticsTab(vm vm.ViewModel) *fyne.Container {
 	totalGroupSizeLabel := widget.NewLabelWithData(vm.GetTotalGroupSizeBind())
 	spaceSavingsLabel := widget.NewLabelWithData(vm.GetPotentialSpaceSavingsBind())